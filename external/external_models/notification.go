@@ -8,3 +8,40 @@ type SendOtpModel struct {
 	AccountId int `json:"account_id"`
 	OtpToken  int `json:"otp_token"`
 }
+
+type AccountLockedModel struct {
+	AccountId   int    `json:"account_id"`
+	IpAddress   string `json:"ip_address"`
+	LockedUntil string `json:"locked_until"`
+}
+
+type MagicLinkModel struct {
+	AccountId int    `json:"account_id"`
+	Link      string `json:"link"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type DeviceAuthorizationModel struct {
+	AccountId int    `json:"account_id"`
+	Link      string `json:"link"`
+	IpAddress string `json:"ip_address"`
+	Device    string `json:"device"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type ContactChangeCodeModel struct {
+	AccountId int    `json:"account_id"`
+	Channel   string `json:"channel"`
+	Recipient string `json:"recipient"`
+	Code      string `json:"code"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type ContactChangeNoticeModel struct {
+	AccountId int    `json:"account_id"`
+	Channel   string `json:"channel"`
+	Recipient string `json:"recipient"`
+	NewValue  string `json:"new_value"`
+	UndoLink  string `json:"undo_link"`
+	ExpiresAt string `json:"expires_at"`
+}
@@ -5,6 +5,12 @@ type PhoneEmailVerificationModel struct {
 	Token     int `json:"token"`
 }
 
+type PasswordResetModel struct {
+	AccountId int    `json:"account_id"`
+	Token     int    `json:"token"`
+	Link      string `json:"link"`
+}
+
 type GetVerifications struct {
 	Status  string         `json:"status"`
 	Code    int            `json:"code"`
@@ -0,0 +1,86 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/vesicash/auth-ms/external"
+	"github.com/vesicash/auth-ms/external/external_models"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/utility"
+	"gorm.io/gorm"
+)
+
+// SendContactChangeCode sends the confirmation code to the new email address or phone number, which is not on
+// the account yet
+func SendContactChangeCode(logger *utility.Logger, authDb *gorm.DB, accountID int, channel, recipient, code string, expiresAt time.Time) error {
+	var (
+		accessToken      = models.AccessToken{}
+		outBoundResponse map[string]interface{}
+	)
+	err := accessToken.GetServiceKeys()
+	if err != nil {
+		logger.Error("contact change code", outBoundResponse, err)
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"v-private-key": accessToken.PrivateKey,
+		"v-public-key":  accessToken.PublicKey,
+	}
+
+	data := external_models.ContactChangeCodeModel{
+		AccountId: accountID,
+		Channel:   channel,
+		Recipient: recipient,
+		Code:      code,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+	logger.Info("contact change code", accountID, channel)
+	err = external.SendRequest(logger, "service", "contact_change_code_notification", headers, data, &outBoundResponse)
+	if err != nil {
+		logger.Error("contact change code", outBoundResponse, err)
+		return err
+	}
+	logger.Info("contact change code", outBoundResponse)
+
+	return nil
+}
+
+// SendContactChangeNotice warns the previous email address or phone number about the change, with a link to
+// undo it
+func SendContactChangeNotice(logger *utility.Logger, authDb *gorm.DB, accountID int, channel, recipient, newValue, undoLink string, expiresAt time.Time) error {
+	var (
+		accessToken      = models.AccessToken{}
+		outBoundResponse map[string]interface{}
+	)
+	err := accessToken.GetServiceKeys()
+	if err != nil {
+		logger.Error("contact change notice", outBoundResponse, err)
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"v-private-key": accessToken.PrivateKey,
+		"v-public-key":  accessToken.PublicKey,
+	}
+
+	data := external_models.ContactChangeNoticeModel{
+		AccountId: accountID,
+		Channel:   channel,
+		Recipient: recipient,
+		NewValue:  newValue,
+		UndoLink:  undoLink,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+	logger.Info("contact change notice", accountID, channel)
+	err = external.SendRequest(logger, "service", "contact_change_notice_notification", headers, data, &outBoundResponse)
+	if err != nil {
+		logger.Error("contact change notice", outBoundResponse, err)
+		return err
+	}
+	logger.Info("contact change notice", outBoundResponse)
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/vesicash/auth-ms/external"
+	"github.com/vesicash/auth-ms/external/external_models"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/utility"
+	"gorm.io/gorm"
+)
+
+func SendDeviceAuthorization(logger *utility.Logger, authDb *gorm.DB, accountID int, link, ipAddress, device string, expiresAt time.Time) error {
+	var (
+		accessToken      = models.AccessToken{}
+		outBoundResponse map[string]interface{}
+	)
+	err := accessToken.GetServiceKeys()
+	if err != nil {
+		logger.Error("device authorization", outBoundResponse, err)
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"v-private-key": accessToken.PrivateKey,
+		"v-public-key":  accessToken.PublicKey,
+	}
+
+	data := external_models.DeviceAuthorizationModel{
+		AccountId: accountID,
+		Link:      link,
+		IpAddress: ipAddress,
+		Device:    device,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+	logger.Info("device authorization", accountID)
+	err = external.SendRequest(logger, "service", "device_authorization_notification", headers, data, &outBoundResponse)
+	if err != nil {
+		logger.Error("device authorization", outBoundResponse, err)
+		return err
+	}
+	logger.Info("device authorization", outBoundResponse)
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/vesicash/auth-ms/external"
+	"github.com/vesicash/auth-ms/external/external_models"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/utility"
+	"gorm.io/gorm"
+)
+
+func SendAccountLockedNotification(logger *utility.Logger, authDb *gorm.DB, accountID int, ipAddress string, lockedUntil time.Time) error {
+	var (
+		accessToken      = models.AccessToken{}
+		outBoundResponse map[string]interface{}
+	)
+	err := accessToken.GetServiceKeys()
+	if err != nil {
+		logger.Error("account locked", outBoundResponse, err)
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"v-private-key": accessToken.PrivateKey,
+		"v-public-key":  accessToken.PublicKey,
+	}
+
+	data := external_models.AccountLockedModel{AccountId: accountID, IpAddress: ipAddress, LockedUntil: lockedUntil.Format(time.RFC3339)}
+	logger.Info("account locked", data)
+	err = external.SendRequest(logger, "service", "account_locked_notification", headers, data, &outBoundResponse)
+	if err != nil {
+		logger.Error("account locked", outBoundResponse, err)
+		return err
+	}
+	logger.Info("account locked", outBoundResponse)
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/vesicash/auth-ms/external"
+	"github.com/vesicash/auth-ms/external/external_models"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/utility"
+	"gorm.io/gorm"
+)
+
+func SendMagicLink(logger *utility.Logger, authDb *gorm.DB, accountID int, link string, expiresAt time.Time) error {
+	var (
+		accessToken      = models.AccessToken{}
+		outBoundResponse map[string]interface{}
+	)
+	err := accessToken.GetServiceKeys()
+	if err != nil {
+		logger.Error("magic link", outBoundResponse, err)
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"v-private-key": accessToken.PrivateKey,
+		"v-public-key":  accessToken.PublicKey,
+	}
+
+	data := external_models.MagicLinkModel{AccountId: accountID, Link: link, ExpiresAt: expiresAt.Format(time.RFC3339)}
+	logger.Info("magic link", accountID)
+	err = external.SendRequest(logger, "service", "magic_link_notification", headers, data, &outBoundResponse)
+	if err != nil {
+		logger.Error("magic link", outBoundResponse, err)
+		return err
+	}
+	logger.Info("magic link", outBoundResponse)
+
+	return nil
+}
@@ -13,7 +13,7 @@ func SendOtp(logger *utility.Logger, authDb *gorm.DB, accountID, token int) erro
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("send otp", outBoundResponse, err)
 		return err
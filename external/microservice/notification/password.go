@@ -8,12 +8,12 @@ import (
 	"gorm.io/gorm"
 )
 
-func SendEmailPasswordReset(logger *utility.Logger, authDb *gorm.DB, accountID, token int) error {
+func SendEmailPasswordReset(logger *utility.Logger, authDb *gorm.DB, accountID, token int, link string) error {
 	var (
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("email password reset", outBoundResponse, err)
 		return err
@@ -25,8 +25,8 @@ func SendEmailPasswordReset(logger *utility.Logger, authDb *gorm.DB, accountID,
 		"v-public-key":  accessToken.PublicKey,
 	}
 
-	data := external_models.PhoneEmailVerificationModel{AccountId: accountID, Token: token}
-	logger.Info("email password reset", data)
+	data := external_models.PasswordResetModel{AccountId: accountID, Token: token, Link: link}
+	logger.Info("email password reset", accountID)
 	err = external.SendRequest(logger, "service", "email_password_reset_notification", headers, data, &outBoundResponse)
 	if err != nil {
 		logger.Error("welcome password reset", outBoundResponse, err)
@@ -41,7 +41,7 @@ func SendPhonePasswordReset(logger *utility.Logger, authDb *gorm.DB, accountID,
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("phone password reset", outBoundResponse, err)
 		return err
@@ -54,7 +54,7 @@ func SendPhonePasswordReset(logger *utility.Logger, authDb *gorm.DB, accountID,
 	}
 
 	data := external_models.PhoneEmailVerificationModel{AccountId: accountID, Token: token}
-	logger.Info("phone password reset", data)
+	logger.Info("phone password reset", accountID)
 	err = external.SendRequest(logger, "service", "phone_password_reset_notification", headers, data, &outBoundResponse)
 	if err != nil {
 		logger.Error("welcome password reset", outBoundResponse, err)
@@ -70,7 +70,7 @@ func SendEmailPasswordDoneReset(logger *utility.Logger, authDb *gorm.DB, account
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("email password reset done", outBoundResponse, err)
 		return err
@@ -99,7 +99,7 @@ func SendPhonePasswordDoneReset(logger *utility.Logger, authDb *gorm.DB, account
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("phone password reset done", outBoundResponse, err)
 		return err
@@ -1,9 +1,6 @@
 package notification
 
 import (
-	"strconv"
-	"time"
-
 	"github.com/vesicash/auth-ms/external"
 	"github.com/vesicash/auth-ms/external/external_models"
 	"github.com/vesicash/auth-ms/internal/models"
@@ -16,7 +13,7 @@ func SendWelcomeNotification(logger *utility.Logger, authDb *gorm.DB, accountID
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("welcome email", outBoundResponse, err)
 		return err
@@ -43,7 +40,7 @@ func SendWelcomeSmsNotification(logger *utility.Logger, authDb *gorm.DB, account
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("welcome sms", outBoundResponse, err)
 		return err
@@ -66,24 +63,13 @@ func SendWelcomeSmsNotification(logger *utility.Logger, authDb *gorm.DB, account
 	return nil
 }
 
-func SendWelcomePasswordReset(logger *utility.Logger, authDb *gorm.DB, accountID, token int) error {
+func SendWelcomePasswordReset(logger *utility.Logger, authDb *gorm.DB, accountID, token int, link string) error {
 
 	var (
-		accessToken = models.AccessToken{}
-		resetTokens = models.PasswordResetToken{
-			AccountID: accountID,
-			Token:     strconv.Itoa(token),
-			ExpiresAt: strconv.Itoa(int(time.Now().Add(48 * time.Hour).Unix())),
-		}
+		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
-	if err != nil {
-		logger.Error("welcome password reset", outBoundResponse, err)
-		return err
-	}
-
-	err = resetTokens.CreatePasswordResetToken(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("welcome password reset", outBoundResponse, err)
 		return err
@@ -94,9 +80,8 @@ func SendWelcomePasswordReset(logger *utility.Logger, authDb *gorm.DB, accountID
 		"v-private-key": accessToken.PrivateKey,
 		"v-public-key":  accessToken.PublicKey,
 	}
-	rToken, _ := strconv.Atoi(resetTokens.Token)
-	data := external_models.PhoneEmailVerificationModel{AccountId: resetTokens.AccountID, Token: rToken}
-	logger.Info("welcome email", data)
+	data := external_models.PasswordResetModel{AccountId: accountID, Token: token, Link: link}
+	logger.Info("welcome email", accountID)
 	err = external.SendRequest(logger, "service", "welcome_password_reset_notification", headers, data, &outBoundResponse)
 	if err != nil {
 		logger.Error("welcome password reset", outBoundResponse, err)
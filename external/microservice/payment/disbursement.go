@@ -16,7 +16,7 @@ func GetDisbursement(logger *utility.Logger, authDb *gorm.DB, accountID int) ([]
 		accessToken      = models.AccessToken{}
 		outBoundResponse external_models.GetDisbursement
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("get disbursements", outBoundResponse, err)
 		return outBoundResponse.Data, err
@@ -14,7 +14,7 @@ func CreateReferralRequest(logger *utility.Logger, authDb *gorm.DB, accountID in
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("create_referral email", outBoundResponse, err)
 		return outBoundResponse, err
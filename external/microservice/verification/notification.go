@@ -13,7 +13,7 @@ func SendVerificationEmail(logger *utility.Logger, authDb *gorm.DB, accountID in
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("verification email", outBoundResponse, err)
 		return err
@@ -41,7 +41,7 @@ func SendVerificationSms(logger *utility.Logger, authDb *gorm.DB, accountID int)
 		accessToken      = models.AccessToken{}
 		outBoundResponse map[string]interface{}
 	)
-	err := accessToken.GetAccessTokens(authDb)
+	err := accessToken.GetServiceKeys()
 	if err != nil {
 		logger.Error("verification email", outBoundResponse, err)
 		return err
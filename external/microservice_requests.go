@@ -101,6 +101,51 @@ func FindMicroserviceRequest(name string, headers map[string]string, data interf
 			RequestData:  data,
 			DecodeMethod: JsonDecodeMethod,
 		}, nil
+	case "account_locked_notification":
+		return RequestObj{
+			Path:         fmt.Sprintf("%v/v2/send/send_account_locked_mail", config.Microservices.Notification),
+			Method:       "POST",
+			Headers:      headers,
+			SuccessCode:  200,
+			RequestData:  data,
+			DecodeMethod: JsonDecodeMethod,
+		}, nil
+	case "magic_link_notification":
+		return RequestObj{
+			Path:         fmt.Sprintf("%v/v2/send/send_magic_link_mail", config.Microservices.Notification),
+			Method:       "POST",
+			Headers:      headers,
+			SuccessCode:  200,
+			RequestData:  data,
+			DecodeMethod: JsonDecodeMethod,
+		}, nil
+	case "device_authorization_notification":
+		return RequestObj{
+			Path:         fmt.Sprintf("%v/v2/send/send_device_authorization_mail", config.Microservices.Notification),
+			Method:       "POST",
+			Headers:      headers,
+			SuccessCode:  200,
+			RequestData:  data,
+			DecodeMethod: JsonDecodeMethod,
+		}, nil
+	case "contact_change_code_notification":
+		return RequestObj{
+			Path:         fmt.Sprintf("%v/v2/send/send_contact_change_code", config.Microservices.Notification),
+			Method:       "POST",
+			Headers:      headers,
+			SuccessCode:  200,
+			RequestData:  data,
+			DecodeMethod: JsonDecodeMethod,
+		}, nil
+	case "contact_change_notice_notification":
+		return RequestObj{
+			Path:         fmt.Sprintf("%v/v2/send/send_contact_change_notice", config.Microservices.Notification),
+			Method:       "POST",
+			Headers:      headers,
+			SuccessCode:  200,
+			RequestData:  data,
+			DecodeMethod: JsonDecodeMethod,
+		}, nil
 	case "verification_email":
 		return RequestObj{
 			Path:         fmt.Sprintf("%v/v2/email", config.Microservices.Verification),
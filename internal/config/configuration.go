@@ -32,6 +32,7 @@ func Setup(logger *utility.Logger, name string) *Configuration {
 	}
 
 	configuration := baseConfiguration.SetupConfigurationn()
+	utility.SetPasswordHashParams(utility.PasswordHashParams(configuration.PasswordHash))
 
 	// Params = configuration.Params
 	Config = configuration
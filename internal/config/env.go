@@ -6,25 +6,118 @@ import (
 )
 
 type Configuration struct {
-	Server        ServerConfiguration
-	Databases     Databases
-	TestDatabases Databases
-	Microservices Microservices
-	App           App
+	Server              ServerConfiguration
+	Databases           Databases
+	TestDatabases       Databases
+	Microservices       Microservices
+	App                 App
+	WebAuthn            WebAuthn
+	Lockout             Lockout
+	Otp                 Otp
+	PasswordReset       PasswordReset
+	PasswordPolicy      PasswordPolicies
+	PasswordHash        PasswordHash
+	MagicLink           MagicLink
+	Denylist            Denylist
+	StepUp              StepUp
+	DeviceAuthorization DeviceAuthorization
+	Impersonation       Impersonation
+	Erasure             Erasure
+	ContactChange       ContactChange
+	ApiKey              ApiKey
+	Signing             Signing
+	RateLimit           RateLimit
 }
 type BaseConfig struct {
-	SERVER_PORT                      string  `mapstructure:"SERVER_PORT"`
-	SERVER_SECRET                    string  `mapstructure:"SERVER_SECRET"`
-	SERVER_ACCESSTOKENEXPIREDURATION int     `mapstructure:"SERVER_ACCESSTOKENEXPIREDURATION"`
-	REQUEST_PER_SECOND               float64 `mapstructure:"REQUEST_PER_SECOND"`
-	TRUSTED_PROXIES                  string  `mapstructure:"TRUSTED_PROXIES"`
-	EXEMPT_FROM_THROTTLE             string  `mapstructure:"EXEMPT_FROM_THROTTLE"`
-	METRICS_SERVER_PORT              string  `mapstructure:"METRICS_SERVER_PORT"`
+	SERVER_PORT                       string  `mapstructure:"SERVER_PORT"`
+	SERVER_SECRET                     string  `mapstructure:"SERVER_SECRET"`
+	SERVER_ACCESSTOKENEXPIREDURATION  int     `mapstructure:"SERVER_ACCESSTOKENEXPIREDURATION"`
+	SERVER_REFRESHTOKENEXPIREDURATION int     `mapstructure:"SERVER_REFRESHTOKENEXPIREDURATION"`
+	SERVER_ENCRYPTIONKEY              string  `mapstructure:"SERVER_ENCRYPTIONKEY"`
+	SERVER_JWTKEYDIRECTORY            string  `mapstructure:"SERVER_JWTKEYDIRECTORY"`
+	SERVER_JWTALGORITHM               string  `mapstructure:"SERVER_JWTALGORITHM"`
+	SERVER_JWTKEYROTATIONDURATION     int     `mapstructure:"SERVER_JWTKEYROTATIONDURATION"`
+	SERVER_HS256ACCEPTEDUNTIL         string  `mapstructure:"SERVER_HS256ACCEPTEDUNTIL"`
+	REQUEST_PER_SECOND                float64 `mapstructure:"REQUEST_PER_SECOND"`
+	TRUSTED_PROXIES                   string  `mapstructure:"TRUSTED_PROXIES"`
+	EXEMPT_FROM_THROTTLE              string  `mapstructure:"EXEMPT_FROM_THROTTLE"`
+	METRICS_SERVER_PORT               string  `mapstructure:"METRICS_SERVER_PORT"`
 
 	APP_NAME string `mapstructure:"APP_NAME"`
 	APP_KEY  string `mapstructure:"APP_KEY"`
 	APP_MODE string `mapstructure:"APP_MODE"`
 
+	WEBAUTHN_RPID          string `mapstructure:"WEBAUTHN_RPID"`
+	WEBAUTHN_RPDISPLAYNAME string `mapstructure:"WEBAUTHN_RPDISPLAYNAME"`
+	WEBAUTHN_RPORIGINS     string `mapstructure:"WEBAUTHN_RPORIGINS"`
+
+	LOCKOUT_MAXACCOUNTATTEMPTS int `mapstructure:"LOCKOUT_MAXACCOUNTATTEMPTS"`
+	LOCKOUT_MAXIPATTEMPTS      int `mapstructure:"LOCKOUT_MAXIPATTEMPTS"`
+	LOCKOUT_ATTEMPTWINDOW      int `mapstructure:"LOCKOUT_ATTEMPTWINDOW"`
+	LOCKOUT_DURATION           int `mapstructure:"LOCKOUT_DURATION"`
+	LOCKOUT_BASEDELAY          int `mapstructure:"LOCKOUT_BASEDELAY"`
+	LOCKOUT_MAXDELAY           int `mapstructure:"LOCKOUT_MAXDELAY"`
+
+	OTP_EXPIREDURATION  int `mapstructure:"OTP_EXPIREDURATION"`
+	OTP_MAXATTEMPTS     int `mapstructure:"OTP_MAXATTEMPTS"`
+	OTP_RESENDCOOLDOWN  int `mapstructure:"OTP_RESENDCOOLDOWN"`
+	OTP_MAXSENDSPERHOUR int `mapstructure:"OTP_MAXSENDSPERHOUR"`
+
+	PASSWORDRESET_EXPIREDURATION int    `mapstructure:"PASSWORDRESET_EXPIREDURATION"`
+	PASSWORDRESET_MAXATTEMPTS    int    `mapstructure:"PASSWORDRESET_MAXATTEMPTS"`
+	PASSWORDRESET_LINKURL        string `mapstructure:"PASSWORDRESET_LINKURL"`
+
+	PASSWORDPOLICY_MINLENGTH             int    `mapstructure:"PASSWORDPOLICY_MINLENGTH"`
+	PASSWORDPOLICY_REQUIREUPPER          bool   `mapstructure:"PASSWORDPOLICY_REQUIREUPPER"`
+	PASSWORDPOLICY_REQUIRELOWER          bool   `mapstructure:"PASSWORDPOLICY_REQUIRELOWER"`
+	PASSWORDPOLICY_REQUIREDIGIT          bool   `mapstructure:"PASSWORDPOLICY_REQUIREDIGIT"`
+	PASSWORDPOLICY_REQUIRESYMBOL         bool   `mapstructure:"PASSWORDPOLICY_REQUIRESYMBOL"`
+	PASSWORDPOLICY_HISTORYSIZE           int    `mapstructure:"PASSWORDPOLICY_HISTORYSIZE"`
+	PASSWORDPOLICY_ACCOUNTTYPES          string `mapstructure:"PASSWORDPOLICY_ACCOUNTTYPES"`
+	PASSWORDPOLICY_BREACHEDPASSWORDSFILE string `mapstructure:"PASSWORDPOLICY_BREACHEDPASSWORDSFILE"`
+
+	PASSWORDHASH_ALGORITHM         string `mapstructure:"PASSWORDHASH_ALGORITHM"`
+	PASSWORDHASH_ARGON2MEMORY      uint32 `mapstructure:"PASSWORDHASH_ARGON2MEMORY"`
+	PASSWORDHASH_ARGON2ITERATIONS  uint32 `mapstructure:"PASSWORDHASH_ARGON2ITERATIONS"`
+	PASSWORDHASH_ARGON2PARALLELISM uint8  `mapstructure:"PASSWORDHASH_ARGON2PARALLELISM"`
+	PASSWORDHASH_BCRYPTCOST        int    `mapstructure:"PASSWORDHASH_BCRYPTCOST"`
+
+	MAGICLINK_EXPIREDURATION  int    `mapstructure:"MAGICLINK_EXPIREDURATION"`
+	MAGICLINK_LINKURL         string `mapstructure:"MAGICLINK_LINKURL"`
+	MAGICLINK_MAXSENDSPERHOUR int    `mapstructure:"MAGICLINK_MAXSENDSPERHOUR"`
+
+	DENYLIST_CACHETTL        int `mapstructure:"DENYLIST_CACHETTL"`
+	DENYLIST_CLEANUPINTERVAL int `mapstructure:"DENYLIST_CLEANUPINTERVAL"`
+
+	STEPUP_MAXAGE int `mapstructure:"STEPUP_MAXAGE"`
+
+	DEVICEAUTHORIZATION_EXPIREDURATION int    `mapstructure:"DEVICEAUTHORIZATION_EXPIREDURATION"`
+	DEVICEAUTHORIZATION_LINKURL        string `mapstructure:"DEVICEAUTHORIZATION_LINKURL"`
+
+	IMPERSONATION_EXPIREDURATION int `mapstructure:"IMPERSONATION_EXPIREDURATION"`
+
+	ERASURE_COOLINGOFFDAYS int `mapstructure:"ERASURE_COOLINGOFFDAYS"`
+	ERASURE_INTERVAL       int `mapstructure:"ERASURE_INTERVAL"`
+
+	CONTACTCHANGE_UNDOWINDOW  int    `mapstructure:"CONTACTCHANGE_UNDOWINDOW"`
+	CONTACTCHANGE_UNDOLINKURL string `mapstructure:"CONTACTCHANGE_UNDOLINKURL"`
+
+	APIKEY_HASHSECRET        string `mapstructure:"APIKEY_HASHSECRET"`
+	APIKEY_SERVICEPUBLICKEY  string `mapstructure:"APIKEY_SERVICEPUBLICKEY"`
+	APIKEY_SERVICEPRIVATEKEY string `mapstructure:"APIKEY_SERVICEPRIVATEKEY"`
+	APIKEY_SIGNATUREMAXSKEW  int    `mapstructure:"APIKEY_SIGNATUREMAXSKEW"`
+
+	SIGNING_NONCESTORE string `mapstructure:"SIGNING_NONCESTORE"`
+
+	RATELIMIT_STORE               string `mapstructure:"RATELIMIT_STORE"`
+	RATELIMIT_ANONYMOUSLIMIT      int64  `mapstructure:"RATELIMIT_ANONYMOUSLIMIT"`
+	RATELIMIT_ANONYMOUSWINDOW     int    `mapstructure:"RATELIMIT_ANONYMOUSWINDOW"`
+	RATELIMIT_DEFAULTLIMIT        int64  `mapstructure:"RATELIMIT_DEFAULTLIMIT"`
+	RATELIMIT_DEFAULTWINDOW       int    `mapstructure:"RATELIMIT_DEFAULTWINDOW"`
+	RATELIMIT_DEFAULTMONTHLYQUOTA int64  `mapstructure:"RATELIMIT_DEFAULTMONTHLYQUOTA"`
+	RATELIMIT_PLANS               string `mapstructure:"RATELIMIT_PLANS"`
+	RATELIMIT_CLEANUPINTERVAL     int    `mapstructure:"RATELIMIT_CLEANUPINTERVAL"`
+
 	DB_HOST          string `mapstructure:"DB_HOST"`
 	DB_PORT          string `mapstructure:"DB_PORT"`
 	DB_CONNECTION    string `mapstructure:"DB_CONNECTION"`
@@ -85,25 +178,130 @@ func (config *BaseConfig) SetupConfigurationn() *Configuration {
 	exemptFromThrottle := []string{}
 	json.Unmarshal([]byte(config.TRUSTED_PROXIES), &trustedProxies)
 	json.Unmarshal([]byte(config.EXEMPT_FROM_THROTTLE), &exemptFromThrottle)
+	webAuthnOrigins := []string{}
+	json.Unmarshal([]byte(config.WEBAUTHN_RPORIGINS), &webAuthnOrigins)
+	accountTypePasswordPolicies := map[string]PasswordPolicy{}
+	json.Unmarshal([]byte(config.PASSWORDPOLICY_ACCOUNTTYPES), &accountTypePasswordPolicies)
+	rateLimitPlans := map[string]RateLimitPlan{}
+	json.Unmarshal([]byte(config.RATELIMIT_PLANS), &rateLimitPlans)
 
 	if config.SERVER_PORT == "" {
 		config.SERVER_PORT = os.Getenv("PORT")
 	}
 	return &Configuration{
 		Server: ServerConfiguration{
-			Port:                      config.SERVER_PORT,
-			Secret:                    config.SERVER_SECRET,
-			AccessTokenExpireDuration: config.SERVER_ACCESSTOKENEXPIREDURATION,
-			RequestPerSecond:          config.REQUEST_PER_SECOND,
-			TrustedProxies:            trustedProxies,
-			ExemptFromThrottle:        exemptFromThrottle,
-			MetricsPort:               config.METRICS_SERVER_PORT,
+			Port:                       config.SERVER_PORT,
+			Secret:                     config.SERVER_SECRET,
+			AccessTokenExpireDuration:  config.SERVER_ACCESSTOKENEXPIREDURATION,
+			RefreshTokenExpireDuration: config.SERVER_REFRESHTOKENEXPIREDURATION,
+			EncryptionKey:              config.SERVER_ENCRYPTIONKEY,
+			JwtKeyDirectory:            config.SERVER_JWTKEYDIRECTORY,
+			JwtAlgorithm:               config.SERVER_JWTALGORITHM,
+			JwtKeyRotationDuration:     config.SERVER_JWTKEYROTATIONDURATION,
+			HS256AcceptedUntil:         config.SERVER_HS256ACCEPTEDUNTIL,
+			RequestPerSecond:           config.REQUEST_PER_SECOND,
+			TrustedProxies:             trustedProxies,
+			ExemptFromThrottle:         exemptFromThrottle,
+			MetricsPort:                config.METRICS_SERVER_PORT,
 		},
 		App: App{
 			Name: config.APP_NAME,
 			Key:  config.APP_KEY,
 			Mode: config.APP_MODE,
 		},
+		WebAuthn: WebAuthn{
+			RPID:          config.WEBAUTHN_RPID,
+			RPDisplayName: config.WEBAUTHN_RPDISPLAYNAME,
+			RPOrigins:     webAuthnOrigins,
+		},
+		Lockout: Lockout{
+			MaxAccountAttempts: config.LOCKOUT_MAXACCOUNTATTEMPTS,
+			MaxIpAttempts:      config.LOCKOUT_MAXIPATTEMPTS,
+			AttemptWindow:      config.LOCKOUT_ATTEMPTWINDOW,
+			Duration:           config.LOCKOUT_DURATION,
+			BaseDelay:          config.LOCKOUT_BASEDELAY,
+			MaxDelay:           config.LOCKOUT_MAXDELAY,
+		},
+		Otp: Otp{
+			ExpireDuration:  config.OTP_EXPIREDURATION,
+			MaxAttempts:     config.OTP_MAXATTEMPTS,
+			ResendCooldown:  config.OTP_RESENDCOOLDOWN,
+			MaxSendsPerHour: config.OTP_MAXSENDSPERHOUR,
+		},
+		PasswordReset: PasswordReset{
+			ExpireDuration: config.PASSWORDRESET_EXPIREDURATION,
+			MaxAttempts:    config.PASSWORDRESET_MAXATTEMPTS,
+			LinkUrl:        config.PASSWORDRESET_LINKURL,
+		},
+		PasswordPolicy: PasswordPolicies{
+			Default: PasswordPolicy{
+				MinLength:     config.PASSWORDPOLICY_MINLENGTH,
+				RequireUpper:  config.PASSWORDPOLICY_REQUIREUPPER,
+				RequireLower:  config.PASSWORDPOLICY_REQUIRELOWER,
+				RequireDigit:  config.PASSWORDPOLICY_REQUIREDIGIT,
+				RequireSymbol: config.PASSWORDPOLICY_REQUIRESYMBOL,
+				HistorySize:   config.PASSWORDPOLICY_HISTORYSIZE,
+			},
+			AccountTypes:          accountTypePasswordPolicies,
+			BreachedPasswordsFile: config.PASSWORDPOLICY_BREACHEDPASSWORDSFILE,
+		},
+		PasswordHash: PasswordHash{
+			Algorithm:         config.PASSWORDHASH_ALGORITHM,
+			Argon2Memory:      config.PASSWORDHASH_ARGON2MEMORY,
+			Argon2Iterations:  config.PASSWORDHASH_ARGON2ITERATIONS,
+			Argon2Parallelism: config.PASSWORDHASH_ARGON2PARALLELISM,
+			BcryptCost:        config.PASSWORDHASH_BCRYPTCOST,
+		},
+		MagicLink: MagicLink{
+			ExpireDuration:  config.MAGICLINK_EXPIREDURATION,
+			LinkUrl:         config.MAGICLINK_LINKURL,
+			MaxSendsPerHour: config.MAGICLINK_MAXSENDSPERHOUR,
+		},
+		Denylist: Denylist{
+			CacheTtl:        config.DENYLIST_CACHETTL,
+			CleanupInterval: config.DENYLIST_CLEANUPINTERVAL,
+		},
+		StepUp: StepUp{
+			MaxAge: config.STEPUP_MAXAGE,
+		},
+		DeviceAuthorization: DeviceAuthorization{
+			ExpireDuration: config.DEVICEAUTHORIZATION_EXPIREDURATION,
+			LinkUrl:        config.DEVICEAUTHORIZATION_LINKURL,
+		},
+		Impersonation: Impersonation{
+			ExpireDuration: config.IMPERSONATION_EXPIREDURATION,
+		},
+		Erasure: Erasure{
+			CoolingOffDays: config.ERASURE_COOLINGOFFDAYS,
+			Interval:       config.ERASURE_INTERVAL,
+		},
+		ContactChange: ContactChange{
+			UndoWindow:  config.CONTACTCHANGE_UNDOWINDOW,
+			UndoLinkUrl: config.CONTACTCHANGE_UNDOLINKURL,
+		},
+		ApiKey: ApiKey{
+			HashSecret:        config.APIKEY_HASHSECRET,
+			ServicePublicKey:  config.APIKEY_SERVICEPUBLICKEY,
+			ServicePrivateKey: config.APIKEY_SERVICEPRIVATEKEY,
+			SignatureMaxSkew:  config.APIKEY_SIGNATUREMAXSKEW,
+		},
+		Signing: Signing{
+			NonceStore: config.SIGNING_NONCESTORE,
+		},
+		RateLimit: RateLimit{
+			Store: config.RATELIMIT_STORE,
+			Anonymous: RateLimitPlan{
+				Limit:  config.RATELIMIT_ANONYMOUSLIMIT,
+				Window: config.RATELIMIT_ANONYMOUSWINDOW,
+			},
+			Default: RateLimitPlan{
+				Limit:        config.RATELIMIT_DEFAULTLIMIT,
+				Window:       config.RATELIMIT_DEFAULTWINDOW,
+				MonthlyQuota: config.RATELIMIT_DEFAULTMONTHLYQUOTA,
+			},
+			Plans:           rateLimitPlans,
+			CleanupInterval: config.RATELIMIT_CLEANUPINTERVAL,
+		},
 		Databases: Databases{
 			DB_HOST:          config.DB_HOST,
 			DB_PORT:          config.DB_PORT,
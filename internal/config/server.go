@@ -1,13 +1,19 @@
 package config
 
 type ServerConfiguration struct {
-	Port                      string
-	Secret                    string
-	AccessTokenExpireDuration int
-	RequestPerSecond          float64
-	TrustedProxies            []string
-	ExemptFromThrottle        []string
-	MetricsPort               string
+	Port                       string
+	Secret                     string
+	AccessTokenExpireDuration  int
+	RefreshTokenExpireDuration int
+	EncryptionKey              string
+	JwtKeyDirectory            string
+	JwtAlgorithm               string
+	JwtKeyRotationDuration     int
+	HS256AcceptedUntil         string
+	RequestPerSecond           float64
+	TrustedProxies             []string
+	ExemptFromThrottle         []string
+	MetricsPort                string
 }
 type App struct {
 	Name string
@@ -15,6 +21,137 @@ type App struct {
 	Mode string
 }
 
+type WebAuthn struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+}
+
+type Otp struct {
+	ExpireDuration  int
+	MaxAttempts     int
+	ResendCooldown  int
+	MaxSendsPerHour int
+}
+
+type PasswordReset struct {
+	ExpireDuration int
+	MaxAttempts    int
+	LinkUrl        string
+}
+
+type Impersonation struct {
+	ExpireDuration int
+}
+
+type Erasure struct {
+	CoolingOffDays int
+	Interval       int
+}
+
+type ContactChange struct {
+	UndoWindow  int
+	UndoLinkUrl string
+}
+
+// ApiKey holds the secret api private keys are hashed with, the key pair this service calls the others with
+// and how many seconds the timestamp of a signed request may be off by
+type ApiKey struct {
+	HashSecret        string
+	ServicePublicKey  string
+	ServicePrivateKey string
+	SignatureMaxSkew  int
+}
+
+// Signing picks the store the nonces of signed requests are kept in, memory or postgres
+type Signing struct {
+	NonceStore string
+}
+
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	HistorySize   int  `json:"history_size"`
+}
+
+type PasswordPolicies struct {
+	Default               PasswordPolicy
+	AccountTypes          map[string]PasswordPolicy
+	BreachedPasswordsFile string
+}
+
+// ForAccountType returns the policy configured for the account type, falling back to the default policy
+func (p PasswordPolicies) ForAccountType(accountType string) PasswordPolicy {
+	if policy, ok := p.AccountTypes[accountType]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+type PasswordHash struct {
+	Algorithm         string
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+type MagicLink struct {
+	ExpireDuration  int
+	LinkUrl         string
+	MaxSendsPerHour int
+}
+
+type Denylist struct {
+	CacheTtl        int
+	CleanupInterval int
+}
+
+type StepUp struct {
+	MaxAge int
+}
+
+type DeviceAuthorization struct {
+	ExpireDuration int
+	LinkUrl        string
+}
+
+type Lockout struct {
+	MaxAccountAttempts int
+	MaxIpAttempts      int
+	AttemptWindow      int
+	Duration           int
+	BaseDelay          int
+	MaxDelay           int
+}
+
+// RateLimit picks the store the counters are kept in and the limits for anonymous requests and for each plan,
+// plans are named after the account type and accounts without one get the default plan. Windows are in seconds.
+type RateLimit struct {
+	Store           string
+	Anonymous       RateLimitPlan
+	Default         RateLimitPlan
+	Plans           map[string]RateLimitPlan
+	CleanupInterval int
+}
+
+type RateLimitPlan struct {
+	Limit        int64 `json:"limit"`
+	Window       int   `json:"window"`
+	MonthlyQuota int64 `json:"monthly_quota"`
+}
+
+// ForAccountType returns the plan configured for the account type, falling back to the default plan
+func (r RateLimit) ForAccountType(accountType string) RateLimitPlan {
+	if plan, ok := r.Plans[accountType]; ok {
+		return plan
+	}
+	return r.Default
+}
+
 type Microservices struct {
 	Admin        string
 	Auth         string
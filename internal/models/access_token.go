@@ -1,25 +1,68 @@
 package models
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/pkg/signing"
 	"github.com/vesicash/auth-ms/utility"
 	"gorm.io/gorm"
 )
 
+// AccessToken is an api key pair. An account can hold many keys told apart by their label, the key issued by
+// /user/security/get_access_token is the one without a label. Keys with a scope are limited to the routes that
+// declare one of those scopes, keys without one can call every api key route.
+//
+// Private keys are stored as a keyed hash along with a short prefix to tell them apart, PrivateKey is only set
+// on keys that were just generated and is never stored. SigningKey is the encrypted key signed requests are
+// checked with, it is derived from the private key so the private key cannot be recovered from it.
+//
+// Environment says whether the key works on live or on sandbox data and RevokedAt whether it was taken out of
+// use. IsLive is kept for the services that still read it, it is only true for live keys that are not revoked.
 type AccessToken struct {
-	ID            uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
-	AccountID     int       `gorm:"column:account_id; type:int; not null" json:"account_id"`
-	PublicKey     string    `gorm:"column:public_key; type:varchar(250); not null" json:"public_key"`
-	PrivateKey    string    `gorm:"column:private_key; type:varchar(250); not null" json:"private_key"`
-	IsLive        bool      `gorm:"column:is_live; type:bool; default:false; not null" json:"is_live"`
-	IsTermsAgreed bool      `gorm:"column:is_terms_agreed; type:bool;default:false" json:"is_terms_agreed"`
-	CreatedAt     time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
-	UpdatedAt     time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+	ID               uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID        int        `gorm:"column:account_id; type:int; not null" json:"account_id"`
+	Label            string     `gorm:"column:label; type:varchar(100); default:''; not null" json:"label"`
+	PublicKey        string     `gorm:"column:public_key; type:varchar(250); not null" json:"public_key"`
+	PrivateKeyHash   string     `gorm:"column:private_key; type:varchar(250); not null" json:"-"`
+	PrivateKeyPrefix string     `gorm:"column:private_key_prefix; type:varchar(250)" json:"private_key_prefix"`
+	PrivateKey       string     `gorm:"-" json:"private_key,omitempty"`
+	SigningKey       string     `gorm:"column:signing_key; type:text" json:"-"`
+	Scope            string     `gorm:"column:scope; type:text" json:"scope"`
+	Environment      string     `gorm:"column:environment; type:varchar(20); default:'live'; not null" json:"environment"`
+	IsLive           bool       `gorm:"column:is_live; type:bool; default:false; not null" json:"is_live"`
+	RevokedAt        *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	IsTermsAgreed    bool       `gorm:"column:is_terms_agreed; type:bool;default:false" json:"is_terms_agreed"`
+	ExpiresAt        *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	LastUsedAt       *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	LastUsedIp       string     `gorm:"column:last_used_ip; type:varchar(250)" json:"last_used_ip"`
+	CreatedAt        time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+const (
+	ApiKeyEnvironmentTest = "test"
+	ApiKeyEnvironmentLive = "live"
+
+	// apiKeyPrefixLength is how much of the random part of a private key is kept visible
+	apiKeyPrefixLength = 6
+)
+
+type CreateApiKeyReq struct {
+	Label       string     `json:"label" validate:"required,max=100"`
+	Environment string     `json:"environment" validate:"omitempty,oneof=test live"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type UpdateApiKeyReq struct {
+	Label  string    `json:"label" validate:"omitempty,max=100"`
+	Scopes *[]string `json:"scopes"`
 }
 
 func (a *AccessToken) GetAccessTokens(db *gorm.DB) error {
@@ -30,6 +73,17 @@ func (a *AccessToken) GetAccessTokens(db *gorm.DB) error {
 	return nil
 }
 
+// GetServiceKeys loads the key pair this service authenticates with when calling the other services
+func (a *AccessToken) GetServiceKeys() error {
+	apiKey := config.GetConfig().ApiKey
+	if apiKey.ServicePublicKey == "" || apiKey.ServicePrivateKey == "" {
+		return fmt.Errorf("service api keys are not configured")
+	}
+	a.PublicKey = apiKey.ServicePublicKey
+	a.PrivateKey = apiKey.ServicePrivateKey
+	return nil
+}
+
 func (a *AccessToken) GetByAccountID(db *gorm.DB) (int, error) {
 	err, nilErr := postgresql.SelectOneFromDb(db, &a, "account_id = ? ", a.AccountID)
 	if nilErr != nil {
@@ -42,6 +96,41 @@ func (a *AccessToken) GetByAccountID(db *gorm.DB) (int, error) {
 	return http.StatusOK, nil
 }
 
+// GetDefaultByAccountID returns the unlabelled key of the account
+func (a *AccessToken) GetDefaultByAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &a, "account_id = ? and label = ?", a.AccountID, "")
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (a *AccessToken) GetByIDAndAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &a, "id = ? and account_id = ?", a.ID, a.AccountID)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// GetAllActiveByAccountID returns the keys of the account that are not revoked, in both environments
+func (a *AccessToken) GetAllActiveByAccountID(db *gorm.DB) ([]AccessToken, error) {
+	details := []AccessToken{}
+	err := postgresql.SelectAllFromDb(db.Order("id desc"), "desc", &details, "account_id = ? and revoked_at is null", a.AccountID)
+	if err != nil {
+		return details, err
+	}
+	return details, nil
+}
+
 func (a *AccessToken) GetLatestByAccountIDAndIsLive(db *gorm.DB) (int, error) {
 	err, nilErr := postgresql.SelectLatestFromDb(db, &a, "account_id = ? and is_live = ? ", a.AccountID, a.IsLive)
 	if nilErr != nil {
@@ -55,14 +144,16 @@ func (a *AccessToken) GetLatestByAccountIDAndIsLive(db *gorm.DB) (int, error) {
 }
 
 func (a *AccessToken) CreateAccessToken(db *gorm.DB) error {
-	app := config.GetConfig().App
 	if a.AccountID == 0 {
 		return fmt.Errorf("account id not provided to create access token")
 	}
-	a.IsLive = true
-	a.PrivateKey = "v_" + app.Name + "_" + utility.RandomString(50)
-	a.PublicKey = "v_" + app.Name + "_" + utility.RandomString(50)
-	err := postgresql.CreateOneRecord(db, &a)
+	err := a.GenerateKeys()
+	if err != nil {
+		return err
+	}
+	a.IsLive = a.Environment == ApiKeyEnvironmentLive
+	a.RevokedAt = nil
+	err = postgresql.CreateOneRecord(db, &a)
 	if err != nil {
 		return fmt.Errorf("user creation failed: %v", err.Error())
 	}
@@ -73,13 +164,30 @@ func (a *AccessToken) RevokeAccessToken(db *gorm.DB) error {
 	if a.AccountID == 0 {
 		return fmt.Errorf("account id not provided to revoke access token")
 	}
+	now := time.Now()
 	a.IsLive = false
+	a.RevokedAt = &now
 	_, err := postgresql.SaveAllFields(db, &a)
 	return err
 }
 
-func (a *AccessToken) LiveTokensWithPublicOrPrivateKey(db *gorm.DB) (int, error) {
-	err, nilErr := postgresql.SelectOneFromDb(db, &a, "(public_key = ? or private_key = ?) and is_live = ?", a.PublicKey, a.PrivateKey, a.IsLive)
+// ActiveTokensWithPublicOrPrivateKey looks a key that is not revoked up by PublicKey or by the hash of PrivateKey
+func (a *AccessToken) ActiveTokensWithPublicOrPrivateKey(db *gorm.DB) (int, error) {
+	privateKeyHash := HashApiKey(a.PrivateKey)
+	a.PrivateKey = ""
+	err, nilErr := postgresql.SelectOneFromDb(db, &a, "(public_key = ? or private_key = ?) and revoked_at is null", a.PublicKey, privateKeyHash)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (a *AccessToken) GetActiveByPublicKey(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &a, "public_key = ? and revoked_at is null", a.PublicKey)
 	if nilErr != nil {
 		return http.StatusBadRequest, nilErr
 	}
@@ -94,3 +202,100 @@ func (a *AccessToken) Update(db *gorm.DB) error {
 	_, err := postgresql.SaveAllFields(db, &a)
 	return err
 }
+
+// RevokeAllByAccountID takes every api key of the account out of use
+func (a *AccessToken) RevokeAllByAccountID(db *gorm.DB) error {
+	_, err := postgresql.UpdateFieldsWhere(db, &AccessToken{}, map[string]interface{}{"is_live": false, "revoked_at": time.Now()}, "account_id = ? and revoked_at is null", a.AccountID)
+	return err
+}
+
+// BackfillRevokedAccessTokens marks the keys revoked before revoked_at existed, is_live was the only sign of it
+func BackfillRevokedAccessTokens(db *gorm.DB) error {
+	_, err := postgresql.UpdateFieldsWhere(db, &AccessToken{}, map[string]interface{}{"revoked_at": gorm.Expr("updated_at")}, "environment = ? and is_live = ? and revoked_at is null", ApiKeyEnvironmentLive, false)
+	return err
+}
+
+// TouchLastUsed records when and from where the key was last used
+func (a *AccessToken) TouchLastUsed(db *gorm.DB, ipAddress string) error {
+	now := time.Now()
+	a.LastUsedAt = &now
+	a.LastUsedIp = ipAddress
+	_, err := postgresql.UpdateFieldsWhere(db, &AccessToken{}, map[string]interface{}{"last_used_at": now, "last_used_ip": ipAddress}, "id = ?", a.ID)
+	return err
+}
+
+func (a *AccessToken) IsRevoked() bool {
+	return a.RevokedAt != nil
+}
+
+func (a *AccessToken) IsExpired() bool {
+	return a.ExpiresAt != nil && !a.ExpiresAt.After(time.Now())
+}
+
+// IsScoped reports whether the key is limited to the routes declaring one of its scopes
+func (a *AccessToken) IsScoped() bool {
+	return strings.TrimSpace(a.Scope) != ""
+}
+
+func (a *AccessToken) ScopeList() []string {
+	return strings.Fields(a.Scope)
+}
+
+// GenerateKeys sets a new key pair prefixed with the environment of the key, the private key is left in
+// PrivateKey to be shown once
+func (a *AccessToken) GenerateKeys() error {
+	if a.Environment == "" {
+		a.Environment = ApiKeyEnvironmentLive
+	}
+	privateKey := "v_" + a.Environment + "_" + utility.RandomString(50)
+	signingKey, err := EncryptSigningKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	a.PrivateKey = privateKey
+	a.PublicKey = "v_" + a.Environment + "_" + utility.RandomString(50)
+	a.PrivateKeyHash = HashApiKey(privateKey)
+	a.PrivateKeyPrefix = ApiKeyPrefix(privateKey)
+	a.SigningKey = signingKey
+	return nil
+}
+
+// DecryptSigningKey returns the key the signatures of requests made with this key pair are checked with
+func (a *AccessToken) DecryptSigningKey() (string, error) {
+	if a.SigningKey == "" {
+		return "", fmt.Errorf("this api key cannot sign requests, generate a new one")
+	}
+	return utility.Decrypt(a.SigningKey, config.GetConfig().Server.EncryptionKey)
+}
+
+// EncryptSigningKey derives the signing key of a private key and encrypts it for storage
+func EncryptSigningKey(privateKey string) (string, error) {
+	return utility.Encrypt(signing.SigningKey(privateKey), config.GetConfig().Server.EncryptionKey)
+}
+
+// VerifyPrivateKey compares the private key with the stored hash in constant time
+func (a *AccessToken) VerifyPrivateKey(privateKey string) bool {
+	if privateKey == "" || a.PrivateKeyHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(a.PrivateKeyHash), []byte(HashApiKey(privateKey))) == 1
+}
+
+// HashApiKey hashes private keys with the api key secret, the server secret is used when it is not set
+func HashApiKey(privateKey string) string {
+	secret := config.GetConfig().ApiKey.HashSecret
+	if secret == "" {
+		secret = config.GetConfig().Server.Secret
+	}
+	return utility.HmacToken(secret, privateKey)
+}
+
+// ApiKeyPrefix keeps the "v_<environment>_" part of a private key and the start of its random part
+func ApiKeyPrefix(privateKey string) string {
+	end := strings.LastIndex(privateKey, "_") + 1 + apiKeyPrefixLength
+	if end > len(privateKey) {
+		end = len(privateKey)
+	}
+	return privateKey[:end]
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionImpersonationStarted = "impersonation_started"
+	AuditActionImpersonationRequest = "impersonation_request"
+	AuditActionImpersonationRefused = "impersonation_refused"
+)
+
+// AuditLog records what was done on an account by someone other than its owner, the owner can list it
+type AuditLog struct {
+	ID             uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID      int       `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	ActorAccountID int       `gorm:"column:actor_account_id; type:int; not null" json:"actor_account_id"`
+	Action         string    `gorm:"column:action; type:varchar(250); not null" json:"action"`
+	Detail         string    `gorm:"column:detail; type:text" json:"detail"`
+	Jti            string    `gorm:"column:jti; type:varchar(250)" json:"-"`
+	IpAddress      string    `gorm:"column:ip_address; type:varchar(250)" json:"ip_address"`
+	CreatedAt      time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+}
+
+type ImpersonateReq struct {
+	AccountID int    `json:"account_id" validate:"required"`
+	Reason    string `json:"reason" validate:"required"`
+}
+
+func (a *AuditLog) CreateAuditLog(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &a)
+	if err != nil {
+		return fmt.Errorf("audit log creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (a *AuditLog) GetAllByAccountID(db *gorm.DB) ([]AuditLog, error) {
+	logs := []AuditLog{}
+	err := postgresql.SelectAllFromDb(db.Order("id desc"), "desc", &logs, "account_id = ?", a.AccountID)
+	if err != nil {
+		return logs, err
+	}
+	return logs, nil
+}
+
+// AnonymiseIpAddresses blanks the ip addresses on the entries about or by the account, the entries themselves
+// are kept as the audit trail
+func (a *AuditLog) AnonymiseIpAddresses(db *gorm.DB) error {
+	_, err := postgresql.UpdateFieldsWhere(db, &AuditLog{}, map[string]interface{}{"ip_address": ""}, "account_id = ? or actor_account_id = ?", a.AccountID, a.AccountID)
+	return err
+}
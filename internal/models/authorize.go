@@ -10,19 +10,24 @@ import (
 )
 
 type Authorize struct {
-	ID           uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
-	AccountID    int       `gorm:"column:account_id; type:int" json:"account_id"`
-	Authorized   bool      `gorm:"column:authorized; type:bool" json:"authorized"`
-	Token        string    `gorm:"column:token; type:varchar(250); not null" json:"token"`
-	IpAddress    string    `gorm:"column:ip_address; type:varchar(250); not null" json:"ip_address"`
-	Browser      string    `gorm:"column:browser; type:varchar(250); not null" json:"browser"`
-	Os           string    `gorm:"column:os; type:varchar(250)" json:"os"`
-	Location     string    `gorm:"column:location; type:varchar(250); not null" json:"location"`
-	Attempt      int       `gorm:"column:attempt; type:int; default: 0" json:"attempt"`
-	AuthorizedAt time.Time `gorm:"column:authorized_at" json:"authorized_at"`
-	CreatedAt    time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
-	DeletedAt    time.Time `gorm:"column:deleted_at" json:"deleted_at"`
+	ID           uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID    int        `gorm:"column:account_id; type:int" json:"account_id"`
+	Authorized   bool       `gorm:"column:authorized; type:bool" json:"authorized"`
+	Token        string     `gorm:"column:token; type:varchar(250); not null" json:"token"`
+	IpAddress    string     `gorm:"column:ip_address; type:varchar(250); not null" json:"ip_address"`
+	Browser      string     `gorm:"column:browser; type:varchar(250); not null" json:"browser"`
+	Os           string     `gorm:"column:os; type:varchar(250)" json:"os"`
+	Location     string     `gorm:"column:location; type:varchar(250); not null" json:"location"`
+	Attempt      int        `gorm:"column:attempt; type:int; default: 0" json:"attempt"`
+	AuthorizedAt time.Time  `gorm:"column:authorized_at" json:"authorized_at"`
+	ExpiresAt    *time.Time `gorm:"column:expires_at" json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+	DeletedAt    time.Time  `gorm:"column:deleted_at" json:"deleted_at"`
+}
+
+type AuthorizeDeviceReq struct {
+	Token string `json:"token" validate:"required"`
 }
 
 type GetAuthorizeModel struct {
@@ -114,6 +119,70 @@ func (a *Authorize) GetAuthorizeByAccountIDAndOneOrAll2(db *gorm.DB) (int, error
 	return http.StatusOK, nil
 }
 
+func (a *Authorize) GetAuthorizedDevice(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &a, "account_id = ? and authorized = ? and ip_address = ? and browser = ?", a.AccountID, true, a.IpAddress, a.Browser)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// GetPendingDevice returns the latest authorization request for the account's ip address and browser
+func (a *Authorize) GetPendingDevice(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &a, "account_id = ? and authorized = ? and ip_address = ? and browser = ?", a.AccountID, false, a.IpAddress, a.Browser)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// GetPendingByToken looks up an authorization request by the hash of the token emailed to the user
+func (a *Authorize) GetPendingByToken(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &a, "token = ? and authorized = ?", a.Token, false)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (a *Authorize) GetAuthorizedByIDAndAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &a, "id = ? and account_id = ? and authorized = ?", a.ID, a.AccountID, true)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (a *Authorize) GetAllAuthorizedByAccountID(db *gorm.DB) ([]Authorize, error) {
+	devices := []Authorize{}
+	err := postgresql.SelectAllFromDb(db.Order("authorized_at desc"), "desc", &devices, "account_id = ? and authorized = ?", a.AccountID, true)
+	if err != nil {
+		return devices, err
+	}
+	return devices, nil
+}
+
+// IsPending reports whether the authorization request can still be approved
+func (a *Authorize) IsPending() bool {
+	return !a.Authorized && a.ExpiresAt != nil && time.Now().Before(*a.ExpiresAt)
+}
+
 func (a *Authorize) CreateAuthorize(db *gorm.DB) error {
 	err := postgresql.CreateOneRecord(db, &a)
 	if err != nil {
@@ -126,3 +195,7 @@ func (a *Authorize) Update(db *gorm.DB) error {
 	_, err := postgresql.SaveAllFields(db, &a)
 	return err
 }
+
+func (a *Authorize) Delete(db *gorm.DB) error {
+	return postgresql.DeleteRecordFromDb(db, &a)
+}
@@ -1,21 +1,54 @@
 package models
 
 import (
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
 	"gorm.io/gorm"
 )
 
+const (
+	BanReasonFraud              = "fraud"
+	BanReasonChargebacks        = "chargebacks"
+	BanReasonTermsViolation     = "terms_violation"
+	BanReasonSuspiciousActivity = "suspicious_activity"
+	BanReasonOther              = "other"
+)
+
+// BannedAccount is one ban of an account. Lifted bans are kept as the ban history of the account, a ban is in
+// force until it is lifted or its expires_at passes.
 type BannedAccount struct {
-	ID        uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
-	AccountID int       `gorm:"column:account_id; type:int; not null" json:"account_id"`
-	CreatedAt time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+	ID         uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID  int        `gorm:"column:account_id; type:int; not null" json:"account_id"`
+	ReasonCode string     `gorm:"column:reason_code; type:varchar(250)" json:"reason_code"`
+	Note       string     `gorm:"column:note; type:text" json:"note"`
+	BannedBy   int        `gorm:"column:banned_by; type:int" json:"banned_by"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	LiftedAt   *time.Time `gorm:"column:lifted_at" json:"lifted_at"`
+	LiftedBy   int        `gorm:"column:lifted_by; type:int" json:"lifted_by"`
+	LiftNote   string     `gorm:"column:lift_note; type:text" json:"lift_note"`
+	Active     bool       `gorm:"-" json:"active"`
+	CreatedAt  time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type BanAccountReq struct {
+	AccountID  int        `json:"account_id" validate:"required"`
+	ReasonCode string     `json:"reason_code" validate:"required,oneof=fraud chargebacks terms_violation suspicious_activity other"`
+	Note       string     `json:"note"`
+	ExpiresAt  *time.Time `json:"expires_at"`
 }
 
+type UnbanAccountReq struct {
+	AccountID int    `json:"account_id" validate:"required"`
+	Note      string `json:"note"`
+}
+
+// CheckByAccountID reports whether a ban is in force for the account
 func (b *BannedAccount) CheckByAccountID(db *gorm.DB) (bool, error) {
-	err, nilErr := postgresql.SelectOneFromDb(db, &b, "account_id = ? ", b.AccountID)
+	err, nilErr := postgresql.SelectLatestFromDb(db, &b, "account_id = ? and lifted_at is null and (expires_at is null or expires_at > ?)", b.AccountID, time.Now())
 	if nilErr != nil {
 		return false, nil
 	}
@@ -25,3 +58,48 @@ func (b *BannedAccount) CheckByAccountID(db *gorm.DB) (bool, error) {
 	}
 	return true, nil
 }
+
+func (b *BannedAccount) CreateBannedAccount(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &b)
+	if err != nil {
+		return fmt.Errorf("banned account creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (b *BannedAccount) GetActiveByAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &b, "account_id = ? and lifted_at is null and (expires_at is null or expires_at > ?)", b.AccountID, time.Now())
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// GetAll returns the bans of the account, or of every account when no account id is set, newest first
+func (b *BannedAccount) GetAll(db *gorm.DB) ([]BannedAccount, error) {
+	bans := []BannedAccount{}
+	query, args := "1 = 1", []interface{}{}
+	if b.AccountID != 0 {
+		query, args = "account_id = ?", append(args, b.AccountID)
+	}
+
+	err := postgresql.SelectAllFromDb(db.Order("id desc"), "desc", &bans, query, args...)
+	if err != nil {
+		return bans, err
+	}
+	return bans, nil
+}
+
+// IsActive reports whether the ban is still in force
+func (b *BannedAccount) IsActive() bool {
+	return b.LiftedAt == nil && (b.ExpiresAt == nil || time.Now().Before(*b.ExpiresAt))
+}
+
+func (b *BannedAccount) Update(db *gorm.DB) error {
+	_, err := postgresql.SaveAllFields(db, &b)
+	return err
+}
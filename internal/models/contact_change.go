@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+const (
+	ContactChannelEmail = "email"
+	ContactChannelPhone = "phone"
+)
+
+// ContactChange is a request to change the email address or phone number of an account. The new value is only
+// put on the user once the code sent to it is confirmed, the previous value can undo the change for a while
+// after that with the link sent to it, only the hash of the undo token is stored.
+type ContactChange struct {
+	ID            uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID     int        `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	Channel       string     `gorm:"column:channel; type:varchar(50); not null" json:"channel"`
+	OldValue      string     `gorm:"column:old_value; type:varchar(250)" json:"old_value"`
+	NewValue      string     `gorm:"column:new_value; type:varchar(250); not null" json:"new_value"`
+	ConfirmedAt   *time.Time `gorm:"column:confirmed_at" json:"confirmed_at"`
+	UndoTokenHash string     `gorm:"column:undo_token_hash; type:varchar(250); index" json:"-"`
+	UndoExpiresAt *time.Time `gorm:"column:undo_expires_at" json:"undo_expires_at"`
+	UndoneAt      *time.Time `gorm:"column:undone_at" json:"undone_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type RequestContactChangeReq struct {
+	Channel string `json:"channel" validate:"required,oneof=email phone"`
+	Value   string `json:"value" validate:"required"`
+}
+
+type ConfirmContactChangeReq struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type UndoContactChangeReq struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (c *ContactChange) CreateContactChange(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &c)
+	if err != nil {
+		return fmt.Errorf("contact change creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+// GetPendingByAccountID returns the latest change of the account that was not confirmed yet
+func (c *ContactChange) GetPendingByAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &c, "account_id = ? and confirmed_at is null", c.AccountID)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (c *ContactChange) GetAllByAccountID(db *gorm.DB) ([]ContactChange, error) {
+	changes := []ContactChange{}
+	err := postgresql.SelectAllFromDb(db.Order("id desc"), "desc", &changes, "account_id = ?", c.AccountID)
+	if err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
+
+func (c *ContactChange) GetByUndoTokenHash(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &c, "undo_token_hash = ?", c.UndoTokenHash)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// CanUndo reports whether the change was confirmed and is still within its undo window
+func (c *ContactChange) CanUndo() bool {
+	return c.ConfirmedAt != nil && c.UndoneAt == nil && c.UndoExpiresAt != nil && time.Now().Before(*c.UndoExpiresAt)
+}
+
+func (c *ContactChange) Update(db *gorm.DB) error {
+	_, err := postgresql.SaveAllFields(db, &c)
+	return err
+}
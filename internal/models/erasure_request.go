@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+const (
+	ErasureStatusPending   = "pending"
+	ErasureStatusCancelled = "cancelled"
+	ErasureStatusCompleted = "completed"
+)
+
+// ErasureRequest is an account owner's request to have their personal data erased. It is carried out once
+// ScheduledFor passes, the owner can cancel it until then.
+type ErasureRequest struct {
+	ID           uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID    int        `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	Status       string     `gorm:"column:status; type:varchar(250); not null; index" json:"status"`
+	ScheduledFor time.Time  `gorm:"column:scheduled_for" json:"scheduled_for"`
+	CancelledAt  *time.Time `gorm:"column:cancelled_at" json:"cancelled_at"`
+	CompletedAt  *time.Time `gorm:"column:completed_at" json:"completed_at"`
+	CreatedAt    time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+func (e *ErasureRequest) CreateErasureRequest(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &e)
+	if err != nil {
+		return fmt.Errorf("erasure request creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (e *ErasureRequest) GetPendingByAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &e, "account_id = ? and status = ?", e.AccountID, ErasureStatusPending)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (e *ErasureRequest) GetLatestByAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &e, "account_id = ?", e.AccountID)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// GetDue returns the pending requests whose cooling-off period is over
+func (e *ErasureRequest) GetDue(db *gorm.DB) ([]ErasureRequest, error) {
+	requests := []ErasureRequest{}
+	err := postgresql.SelectAllFromDb(db.Order("id asc"), "asc", &requests, "status = ? and scheduled_for <= ?", ErasureStatusPending, time.Now())
+	if err != nil {
+		return requests, err
+	}
+	return requests, nil
+}
+
+func (e *ErasureRequest) Update(db *gorm.DB) error {
+	_, err := postgresql.SaveAllFields(db, &e)
+	return err
+}
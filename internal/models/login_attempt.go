@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+// LoginAttempt counts consecutive failed logins for an account or an ip address, Key is built with
+// LoginAttemptAccountKey or LoginAttemptIpKey
+type LoginAttempt struct {
+	ID           uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	Key          string     `gorm:"column:key; type:varchar(250); not null; unique" json:"key"`
+	Failures     int        `gorm:"column:failures; type:int; default:0; not null" json:"failures"`
+	LastFailedAt time.Time  `gorm:"column:last_failed_at" json:"last_failed_at"`
+	LockedUntil  *time.Time `gorm:"column:locked_until" json:"locked_until"`
+	CreatedAt    time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type UnlockAccountReq struct {
+	AccountID int    `json:"account_id" validate:"required"`
+	IpAddress string `json:"ip_address"`
+}
+
+func LoginAttemptAccountKey(accountID int) string {
+	return fmt.Sprintf("account:%v", accountID)
+}
+
+func LoginAttemptIpKey(ipAddress string) string {
+	return fmt.Sprintf("ip:%v", ipAddress)
+}
+
+func (l *LoginAttempt) GetByKey(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &l, "key = ? ", l.Key)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (l *LoginAttempt) CreateLoginAttempt(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &l)
+	if err != nil {
+		return fmt.Errorf("login attempt creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+// RegisterFailure counts a failure for the key in a single statement, creating the counter when missing and
+// starting over when the last failure is older than windowStart, and loads the counter after the change
+func (l *LoginAttempt) RegisterFailure(db *gorm.DB, windowStart time.Time) error {
+	return db.Raw(`INSERT INTO login_attempts (key, failures, last_failed_at, created_at, updated_at)
+		VALUES (?, 1, ?, now(), now())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failed_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failed_at = excluded.last_failed_at, updated_at = now()
+		RETURNING id, key, failures, last_failed_at, locked_until, created_at, updated_at`, l.Key, l.LastFailedAt, windowStart).Scan(l).Error
+}
+
+// Lock locks the key until lockedUntil once it reached maxAttempts and is not locked already, it reports whether
+// this call took the lock so concurrent failures only lock the key once
+func (l *LoginAttempt) Lock(db *gorm.DB, maxAttempts int, lockedUntil time.Time) (bool, error) {
+	rows, err := postgresql.UpdateFieldsWhere(db, &LoginAttempt{}, map[string]interface{}{"locked_until": lockedUntil, "failures": 0},
+		"id = ? and failures >= ? and (locked_until is null or locked_until <= ?)", l.ID, maxAttempts, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if rows != 1 {
+		return false, nil
+	}
+	l.LockedUntil = &lockedUntil
+	l.Failures = 0
+	return true, nil
+}
+
+func (l *LoginAttempt) Update(db *gorm.DB) error {
+	_, err := postgresql.SaveAllFields(db, &l)
+	return err
+}
+
+func (l *LoginAttempt) DeleteByKey(db *gorm.DB) error {
+	return postgresql.DeleteRecordFromDb(db.Where("key = ?", l.Key), &LoginAttempt{})
+}
+
+func (l *LoginAttempt) DeleteByKeys(db *gorm.DB, keys []string) error {
+	return postgresql.DeleteRecordFromDb(db.Where("key in ?", keys), &LoginAttempt{})
+}
+
+// IsLocked reports whether the account or ip address is currently locked out
+func (l *LoginAttempt) IsLocked() bool {
+	return l.LockedUntil != nil && time.Now().Before(*l.LockedUntil)
+}
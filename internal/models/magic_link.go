@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+// MagicLink is a single use passwordless login link sent by email, only the hash of its token is stored
+type MagicLink struct {
+	ID        uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID int        `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	TokenHash string     `gorm:"column:token_hash; type:varchar(250); not null; unique" json:"-"`
+	IpAddress string     `gorm:"column:ip_address; type:varchar(250)" json:"ip_address"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	ExpiresAt time.Time  `gorm:"column:expires_at; not null" json:"expires_at"`
+	CreatedAt time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type RequestMagicLinkReq struct {
+	EmailAddress string `json:"email_address" validate:"required,email"`
+}
+
+type ConsumeMagicLinkReq struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (m *MagicLink) CreateMagicLink(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &m)
+	if err != nil {
+		return fmt.Errorf("magic link creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (m *MagicLink) GetByTokenHash(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &m, "token_hash = ?", m.TokenHash)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// MarkUsed consumes the link, it returns false when a concurrent request already used it
+func (m *MagicLink) MarkUsed(db *gorm.DB) (bool, error) {
+	now := time.Now()
+	rows, err := postgresql.UpdateFieldsWhere(db, &MagicLink{}, map[string]interface{}{"used_at": now}, "id = ? and used_at is null", m.ID)
+	if err != nil {
+		return false, err
+	}
+	m.UsedAt = &now
+	return rows == 1, nil
+}
+
+// InvalidateAllByAccountID stops every link issued to the account from working, the links are kept so they
+// still count towards the hourly send limit
+func (m *MagicLink) InvalidateAllByAccountID(db *gorm.DB) error {
+	_, err := postgresql.UpdateFieldsWhere(db, &MagicLink{}, map[string]interface{}{"used_at": time.Now()}, "account_id = ? and used_at is null", m.AccountID)
+	return err
+}
+
+func (m *MagicLink) CountSentSince(db *gorm.DB, since time.Time) (int64, error) {
+	return postgresql.CountRecords(db, &MagicLink{}, "account_id = ? and created_at > ?", m.AccountID, since)
+}
+
+// DeleteSpentBefore drops links of the account sent before the hourly send limit window that can no longer be used
+func (m *MagicLink) DeleteSpentBefore(db *gorm.DB, before time.Time) error {
+	return postgresql.DeleteRecordFromDb(db.Where("account_id = ? and created_at < ? and (used_at is not null or expires_at < ?)", m.AccountID, before, time.Now()), &MagicLink{})
+}
+
+func (m *MagicLink) IsUsable() bool {
+	return m.UsedAt == nil && time.Now().Before(m.ExpiresAt)
+}
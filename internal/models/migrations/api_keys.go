@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+)
+
+// HashApiKeys replaces the private keys that were stored in plaintext with their hash and stores their signing
+// key. Hashes never contain an underscore so running it again only picks up keys it has not hashed yet.
+func HashApiKeys(db postgresql.Databases) (int, error) {
+	err := db.Auth.AutoMigrate(&models.AccessToken{})
+	if err != nil {
+		return 0, err
+	}
+
+	tokens := []models.AccessToken{}
+	err = postgresql.SelectAllFromDb(db.Auth.Order("id asc"), "asc", &tokens, "position('_' in private_key) > 0")
+	if err != nil {
+		return 0, err
+	}
+
+	hashed := 0
+	for _, token := range tokens {
+		privateKey := token.PrivateKeyHash
+		signingKey, err := models.EncryptSigningKey(privateKey)
+		if err != nil {
+			return hashed, err
+		}
+
+		rows, err := postgresql.UpdateFieldsWhere(db.Auth, &models.AccessToken{}, map[string]interface{}{
+			"private_key":        models.HashApiKey(privateKey),
+			"private_key_prefix": models.ApiKeyPrefix(privateKey),
+			"signing_key":        signingKey,
+		}, "id = ? and private_key = ?", token.ID, privateKey)
+		if err != nil {
+			return hashed, err
+		}
+		hashed += int(rows)
+	}
+	return hashed, nil
+}
@@ -8,6 +8,7 @@ import (
 func AuthMigrationModels() []interface{} {
 	return []interface{}{
 		models.AccessToken{},
+		models.AuditLog{},
 		models.Authorize{},
 		models.BankDetail{},
 		models.Bank{},
@@ -15,19 +16,35 @@ func AuthMigrationModels() []interface{} {
 		models.BusinessCharge{},
 		models.BusinessProfile{},
 		models.BusinessType{},
+		models.ContactChange{},
 		models.ContactUs{},
 		models.Country{},
+		models.ErasureRequest{},
 		models.EscrowCharge{},
+		models.LoginAttempt{},
+		models.MagicLink{},
+		models.OauthAuthorizationCode{},
+		models.OauthClient{},
+		models.OauthConsent{},
 		models.OtpVerification{},
+		models.PasswordHistory{},
 		models.PasswordResetToken{},
+		models.RateLimitCounter{},
 		models.ReferralPromo{},
+		models.RefreshToken{},
+		models.RevokedToken{},
+		models.Session{},
+		models.SignatureNonce{},
 		models.UserAccountUpgrade{},
 		models.UserProfile{},
+		models.UserTotp{},
 		models.UserTracking{},
 		models.User{},
 		models.UsersCredential{},
 		models.WalletBalance{},
 		models.WalletHistory{},
 		models.WalletTransaction{},
+		models.WebauthnChallenge{},
+		models.WebauthnCredential{},
 	}
 }
@@ -14,6 +14,15 @@ func RunAllMigrations(db postgresql.Databases) {
 	// add countries
 	models.AddCountriesIfNotExist(db.Auth)
 
+	// revoked_at of api keys revoked before it existed
+	_ = models.BackfillRevokedAccessTokens(db.Auth)
+
+	// reset tokens moved to password_reset_requests and are only stored hashed there
+	_ = models.DropLegacyPasswordResetTokens(db.Auth)
+
+	// access tokens are no longer kept on the user row
+	_ = models.ClearLoginAccessTokens(db.Auth)
+
 }
 
 func MigrateModels(db *gorm.DB, models []interface{}) {
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+type OauthClient struct {
+	ID               uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID        int       `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	ClientID         string    `gorm:"column:client_id; type:varchar(250); not null; unique" json:"client_id"`
+	ClientSecretHash string    `gorm:"column:client_secret_hash; type:varchar(250)" json:"-"`
+	Name             string    `gorm:"column:name; type:varchar(250); not null" json:"name"`
+	RedirectUris     string    `gorm:"column:redirect_uris; type:text" json:"-"`
+	Scope            string    `gorm:"column:scope; type:text" json:"scope"`
+	Public           bool      `gorm:"column:public; type:bool; default:false; not null" json:"public"`
+	Revoked          bool      `gorm:"column:revoked; type:bool; default:false; not null" json:"-"`
+	CreatedAt        time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type OauthAuthorizationCode struct {
+	ID                  uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	CodeHash            string    `gorm:"column:code_hash; type:varchar(250); not null; unique" json:"-"`
+	ClientID            string    `gorm:"column:client_id; type:varchar(250); not null; index" json:"client_id"`
+	AccountID           int       `gorm:"column:account_id; type:int; not null" json:"account_id"`
+	RedirectUri         string    `gorm:"column:redirect_uri; type:text; not null" json:"redirect_uri"`
+	RedirectUriSupplied bool      `gorm:"column:redirect_uri_supplied; type:bool; default:false; not null" json:"redirect_uri_supplied"`
+	Scope               string    `gorm:"column:scope; type:text" json:"scope"`
+	CodeChallenge       string    `gorm:"column:code_challenge; type:varchar(250)" json:"-"`
+	CodeChallengeMethod string    `gorm:"column:code_challenge_method; type:varchar(20)" json:"-"`
+	Used                bool      `gorm:"column:used; type:bool; default:false; not null" json:"used"`
+	ExpiresAt           time.Time `gorm:"column:expires_at" json:"expires_at"`
+	CreatedAt           time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type OauthConsent struct {
+	ID        uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID int       `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	ClientID  string    `gorm:"column:client_id; type:varchar(250); not null; index" json:"client_id"`
+	Scope     string    `gorm:"column:scope; type:text" json:"scope"`
+	CreatedAt time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type CreateOauthClientReq struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectUris []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+}
+
+// OauthAuthorizeReq holds the RFC 6749 authorization request parameters, they arrive as query parameters or a form
+type OauthAuthorizeReq struct {
+	ResponseType        string `json:"response_type" form:"response_type"`
+	ClientID            string `json:"client_id" form:"client_id"`
+	RedirectUri         string `json:"redirect_uri" form:"redirect_uri"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"`
+}
+
+type OauthConsentReq struct {
+	OauthAuthorizeReq
+	Approve bool `json:"approve" form:"approve"`
+}
+
+type OauthTokenReq struct {
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	Code         string `json:"code" form:"code"`
+	RedirectUri  string `json:"redirect_uri" form:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	Scope        string `json:"scope" form:"scope"`
+}
+
+// OauthIntrospectReq holds the RFC 7662 introspection parameters, RFC 7009 revocation takes the same ones
+type OauthIntrospectReq struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+	ClientID      string `json:"client_id" form:"client_id"`
+	ClientSecret  string `json:"client_secret" form:"client_secret"`
+}
+
+func (o *OauthClient) CreateOauthClient(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &o)
+	if err != nil {
+		return fmt.Errorf("oauth client creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (o *OauthClient) GetByClientID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &o, "client_id = ? and revoked = ?", o.ClientID, false)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (o *OauthClient) GetByClientIDAndAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &o, "client_id = ? and account_id = ? and revoked = ?", o.ClientID, o.AccountID, false)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (o *OauthClient) GetAllByAccountID(db *gorm.DB) ([]OauthClient, error) {
+	clients := []OauthClient{}
+	err := postgresql.SelectAllFromDb(db, "desc", &clients, "account_id = ? and revoked = ?", o.AccountID, false)
+	if err != nil {
+		return clients, err
+	}
+	return clients, nil
+}
+
+func (o *OauthClient) Revoke(db *gorm.DB) error {
+	o.Revoked = true
+	_, err := postgresql.SaveAllFields(db, &o)
+	return err
+}
+
+// RevokeAllByAccountID takes every client of the account out of use
+func (o *OauthClient) RevokeAllByAccountID(db *gorm.DB) error {
+	_, err := postgresql.UpdateFieldsWhere(db, &OauthClient{}, map[string]interface{}{"revoked": true}, "account_id = ? and revoked = ?", o.AccountID, false)
+	return err
+}
+
+func (o *OauthClient) RedirectUriList() []string {
+	return strings.Fields(o.RedirectUris)
+}
+
+func (o *OauthClient) ScopeList() []string {
+	return strings.Fields(o.Scope)
+}
+
+// AllowsRedirectUri compares redirect uris exactly, as required for clients with registered uris
+func (o *OauthClient) AllowsRedirectUri(redirectUri string) bool {
+	for _, uri := range o.RedirectUriList() {
+		if uri == redirectUri {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *OauthAuthorizationCode) CreateOauthAuthorizationCode(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &o)
+	if err != nil {
+		return fmt.Errorf("authorization code creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (o *OauthAuthorizationCode) GetByCodeHash(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &o, "code_hash = ? ", o.CodeHash)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// MarkUsed consumes the code, it returns false when a concurrent exchange already used it
+func (o *OauthAuthorizationCode) MarkUsed(db *gorm.DB) (bool, error) {
+	rows, err := postgresql.UpdateFieldsWhere(db, &OauthAuthorizationCode{}, map[string]interface{}{"used": true}, "id = ? and used = ?", o.ID, false)
+	if err != nil {
+		return false, err
+	}
+	o.Used = true
+	return rows == 1, nil
+}
+
+func (o *OauthConsent) GetByAccountIDAndClientID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &o, "account_id = ? and client_id = ?", o.AccountID, o.ClientID)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (o *OauthConsent) CreateOauthConsent(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &o)
+	if err != nil {
+		return fmt.Errorf("oauth consent creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (o *OauthConsent) Update(db *gorm.DB) error {
+	_, err := postgresql.SaveAllFields(db, &o)
+	return err
+}
+
+// Covers reports whether every scope was already granted
+func (o *OauthConsent) Covers(scopes []string) bool {
+	granted := map[string]bool{}
+	for _, scope := range strings.Fields(o.Scope) {
+		granted[scope] = true
+	}
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return false
+		}
+	}
+	return true
+}
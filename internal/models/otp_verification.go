@@ -1,29 +1,63 @@
 package models
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
 	"gorm.io/gorm"
 )
 
+const (
+	OtpPurposeLogin         = "login"
+	OtpPurposePasswordReset = "password_reset"
+	OtpPurposeStepUp        = "step_up"
+	OtpPurposeContactChange = "contact_change"
+)
+
 func (OtpVerification) TableName() string {
 	return "otp_verification"
 }
 
+// OtpVerification keeps every otp sent to an account so resends can be rate limited, only the hash of the
+// code is stored in the otp_token column. Authenticated is set for otps the logged in user asked for, they have
+// their own send limit so anonymous requests cannot use it up.
 type OtpVerification struct {
-	ID        uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
-	AccountID int       `gorm:"column:account_id; type:int; not null; comment: account id of the user" json:"account_id"`
-	OtpToken  string    `gorm:"column:otp_token; type:varchar(250); not null" json:"otp_token"`
-	ExpiresAt time.Time `gorm:"column:expires_at;" json:"expires_at"`
-	CreatedAt time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+	ID            uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID     int        `gorm:"column:account_id; type:int; not null; comment: account id of the user" json:"account_id"`
+	TokenHash     string     `gorm:"column:otp_token; type:varchar(250); not null" json:"-"`
+	Purpose       string     `gorm:"column:purpose; type:varchar(50); default:'login'; not null" json:"purpose"`
+	Authenticated bool       `gorm:"column:authenticated; type:bool; default:false; not null" json:"authenticated"`
+	Attempts      int        `gorm:"column:attempts; type:int; default:0; not null" json:"attempts"`
+	UsedAt        *time.Time `gorm:"column:used_at" json:"used_at"`
+	ExpiresAt     time.Time  `gorm:"column:expires_at;" json:"expires_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
 }
 
 type SendOtpTokenReq struct {
-	AccountID int `json:"account_id" validate:"required" pgvalidate:"exists=auth$users$account_id"`
+	AccountID     int    `json:"account_id" validate:"required" pgvalidate:"exists=auth$users$account_id"`
+	Purpose       string `json:"purpose" validate:"omitempty,oneof=login password_reset step_up"`
+	Authenticated bool   `json:"-"`
+}
+
+// SendOwnOtpReq is the optional body of the authenticated send otp route, logged in users can only ask for a
+// login or step up code
+type SendOwnOtpReq struct {
+	Purpose string `json:"purpose" validate:"omitempty,oneof=login step_up"`
+}
+
+// HashOtp binds the code to the account and purpose it was issued for, so a code sent for one purpose
+// never matches another
+func HashOtp(accountID int, purpose, code string) string {
+	mac := hmac.New(sha256.New, []byte(config.GetConfig().Server.Secret))
+	mac.Write([]byte(fmt.Sprintf("%v:%v:%v", accountID, purpose, code)))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 func (o *OtpVerification) GetLatestByAccountID(db *gorm.DB) (int, error) {
@@ -38,16 +72,54 @@ func (o *OtpVerification) GetLatestByAccountID(db *gorm.DB) (int, error) {
 	return http.StatusOK, nil
 }
 
+func (o *OtpVerification) GetLatestByAccountIDAndPurpose(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &o, "account_id = ? and purpose = ?", o.AccountID, o.Purpose)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// CountSentSince counts the otps sent to the account for the purpose, anonymous and authenticated sends are
+// counted apart
+func (o *OtpVerification) CountSentSince(db *gorm.DB, since time.Time) (int64, error) {
+	return postgresql.CountRecords(db, &OtpVerification{}, "account_id = ? and purpose = ? and authenticated = ? and created_at > ?", o.AccountID, o.Purpose, o.Authenticated, since)
+}
+
 func (u *OtpVerification) Create(db *gorm.DB) error {
-	expiry := time.Now().Add(30 * time.Minute)
-	expiry.Format("2006-01-02 15:04:05")
-	u.ExpiresAt = expiry
+	expireDuration := config.GetConfig().Otp.ExpireDuration
+	if expireDuration <= 0 {
+		expireDuration = 30
+	}
+	u.ExpiresAt = time.Now().Add(time.Duration(expireDuration) * time.Minute)
 	err := postgresql.CreateOneRecord(db, &u)
 	if err != nil {
 		return fmt.Errorf("otp creation failed: %v", err.Error())
 	}
 	return nil
 }
+
+func (u *OtpVerification) IncrementAttempts(db *gorm.DB) error {
+	u.Attempts++
+	_, err := postgresql.UpdateFieldsWhere(db, &OtpVerification{}, map[string]interface{}{"attempts": gorm.Expr("attempts + 1")}, "id = ?", u.ID)
+	return err
+}
+
+// MarkUsed consumes the otp, it returns false when a concurrent request already used it
+func (u *OtpVerification) MarkUsed(db *gorm.DB) (bool, error) {
+	now := time.Now()
+	rows, err := postgresql.UpdateFieldsWhere(db, &OtpVerification{}, map[string]interface{}{"used_at": now}, "id = ? and used_at is null", u.ID)
+	if err != nil {
+		return false, err
+	}
+	u.UsedAt = &now
+	return rows == 1, nil
+}
+
 func (u *OtpVerification) Delete(db *gorm.DB) error {
 	err := postgresql.DeleteRecordFromDb(db, &u)
 	if err != nil {
@@ -55,3 +127,16 @@ func (u *OtpVerification) Delete(db *gorm.DB) error {
 	}
 	return nil
 }
+
+// DeleteSpentBefore drops otps of the account sent before the hourly send limit window that can no longer be used
+func (u *OtpVerification) DeleteSpentBefore(db *gorm.DB, before time.Time) error {
+	return postgresql.DeleteRecordFromDb(db.Where("account_id = ? and created_at < ? and (used_at is not null or expires_at < ?)", u.AccountID, before, time.Now()), &OtpVerification{})
+}
+
+// IsUsable reports whether the otp can still be checked, it is invalidated once used or after too many misses
+func (u *OtpVerification) IsUsable(maxAttempts int) bool {
+	if u.UsedAt != nil {
+		return false
+	}
+	return maxAttempts <= 0 || u.Attempts < maxAttempts
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+// PasswordHistory keeps the hashes of passwords an account used before, so recent ones can't be reused
+type PasswordHistory struct {
+	ID           uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID    int       `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	PasswordHash string    `gorm:"column:password_hash; type:varchar(250); not null" json:"-"`
+	CreatedAt    time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+}
+
+func (p *PasswordHistory) CreatePasswordHistory(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &p)
+	if err != nil {
+		return fmt.Errorf("password history creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (p *PasswordHistory) GetRecentByAccountID(db *gorm.DB, limit int) ([]PasswordHistory, error) {
+	histories := []PasswordHistory{}
+	err := postgresql.SelectAllFromDb(db.Order("id desc").Limit(limit), "desc", &histories, "account_id = ?", p.AccountID)
+	return histories, err
+}
+
+// DeleteAllButRecent keeps the keep most recent passwords of the account
+func (p *PasswordHistory) DeleteAllButRecent(db *gorm.DB, keep int) error {
+	recent := db.Model(&PasswordHistory{}).Select("id").Where("account_id = ?", p.AccountID).Order("id desc").Limit(keep)
+	return postgresql.DeleteRecordFromDb(db.Where("account_id = ? and id not in (?)", p.AccountID, recent), &PasswordHistory{})
+}
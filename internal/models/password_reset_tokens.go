@@ -9,13 +9,29 @@ import (
 	"gorm.io/gorm"
 )
 
+// PasswordResetToken lives in its own table since the plaintext tokens of the former password_reset_tokens
+// table must not be honoured anymore. Each token can be redeemed either with the numeric code, hashed with
+// HashOtp, or with the url-safe link token, hashed with utility.HashToken.
+func (PasswordResetToken) TableName() string {
+	return "password_reset_requests"
+}
+
 type PasswordResetToken struct {
-	ID        uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
-	AccountID int       `gorm:"column:account_id; type:int; not null" json:"account_id"`
-	Token     string    `gorm:"column:token; type:varchar(250); not null" json:"token"`
-	ExpiresAt string    `gorm:"column:expires_at; type:varchar(250); not null" json:"expires_at"`
-	CreatedAt time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+	ID            uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID     int        `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	CodeHash      string     `gorm:"column:code_hash; type:varchar(250); not null" json:"-"`
+	LinkTokenHash string     `gorm:"column:link_token_hash; type:varchar(250); not null; unique" json:"-"`
+	Attempts      int        `gorm:"column:attempts; type:int; default:0; not null" json:"attempts"`
+	UsedAt        *time.Time `gorm:"column:used_at" json:"used_at"`
+	ExpiresAt     time.Time  `gorm:"column:expires_at; not null" json:"expires_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+// DropLegacyPasswordResetTokens drops the former password_reset_tokens table, the tokens in it were stored in
+// plaintext and are never honoured anymore
+func DropLegacyPasswordResetTokens(db *gorm.DB) error {
+	return db.Migrator().DropTable("password_reset_tokens")
 }
 
 func (p *PasswordResetToken) CreatePasswordResetToken(db *gorm.DB) error {
@@ -26,8 +42,8 @@ func (p *PasswordResetToken) CreatePasswordResetToken(db *gorm.DB) error {
 	return nil
 }
 
-func (p *PasswordResetToken) GetLatestByAccountIDAndToken(db *gorm.DB) (int, error) {
-	err, nilErr := postgresql.SelectLatestFromDb(db, &p, "account_id = ? and token = ?", p.AccountID, p.Token)
+func (p *PasswordResetToken) GetLatestByAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &p, "account_id = ?", p.AccountID)
 	if nilErr != nil {
 		return http.StatusBadRequest, nilErr
 	}
@@ -38,8 +54,8 @@ func (p *PasswordResetToken) GetLatestByAccountIDAndToken(db *gorm.DB) (int, err
 	return http.StatusOK, nil
 }
 
-func (p *PasswordResetToken) GetLatestByAccountID(db *gorm.DB) (int, error) {
-	err, nilErr := postgresql.SelectLatestFromDb(db, &p, "account_id = ?", p.AccountID)
+func (p *PasswordResetToken) GetByLinkTokenHash(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &p, "link_token_hash = ?", p.LinkTokenHash)
 	if nilErr != nil {
 		return http.StatusBadRequest, nilErr
 	}
@@ -50,10 +66,36 @@ func (p *PasswordResetToken) GetLatestByAccountID(db *gorm.DB) (int, error) {
 	return http.StatusOK, nil
 }
 
-func (p *PasswordResetToken) DeletePasswordResetToken(db *gorm.DB) error {
-	err := postgresql.DeleteRecordFromDb(db, &p)
+func (p *PasswordResetToken) IncrementAttempts(db *gorm.DB) error {
+	p.Attempts++
+	_, err := postgresql.UpdateFieldsWhere(db, &PasswordResetToken{}, map[string]interface{}{"attempts": gorm.Expr("attempts + 1")}, "id = ?", p.ID)
+	return err
+}
+
+// MarkUsed consumes the token, it returns false when a concurrent request already used it
+func (p *PasswordResetToken) MarkUsed(db *gorm.DB) (bool, error) {
+	now := time.Now()
+	rows, err := postgresql.UpdateFieldsWhere(db, &PasswordResetToken{}, map[string]interface{}{"used_at": now}, "id = ? and used_at is null", p.ID)
+	if err != nil {
+		return false, err
+	}
+	p.UsedAt = &now
+	return rows == 1, nil
+}
+
+// DeleteAllByAccountID invalidates every reset token issued to the account
+func (p *PasswordResetToken) DeleteAllByAccountID(db *gorm.DB) error {
+	err := postgresql.DeleteRecordFromDb(db.Where("account_id = ?", p.AccountID), &PasswordResetToken{})
 	if err != nil {
 		return fmt.Errorf("password reset token delete failed: %v", err.Error())
 	}
 	return nil
 }
+
+// IsUsable reports whether the token can still be redeemed, it is invalidated once used or after too many misses
+func (p *PasswordResetToken) IsUsable(maxAttempts int) bool {
+	if p.UsedAt != nil {
+		return false
+	}
+	return maxAttempts <= 0 || p.Attempts < maxAttempts
+}
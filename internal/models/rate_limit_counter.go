@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+// RateLimitCounter counts the requests a principal made in one rate limit window, instances running as a cluster
+// share the counters through this table
+type RateLimitCounter struct {
+	ID          uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	Key         string    `gorm:"column:key; type:varchar(250); not null; uniqueIndex:idx_rate_limit_counters_key_window" json:"key"`
+	WindowStart time.Time `gorm:"column:window_start; not null; uniqueIndex:idx_rate_limit_counters_key_window" json:"window_start"`
+	Count       int64     `gorm:"column:count; type:bigint; not null; default:0" json:"count"`
+	ExpiresAt   time.Time `gorm:"column:expires_at; index" json:"expires_at"`
+	CreatedAt   time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+// Increment adds n to the counter of the key and window in a single statement, creating it when missing, and
+// loads the count after the change
+func (r *RateLimitCounter) Increment(db *gorm.DB, n int64) error {
+	return db.Raw(`INSERT INTO rate_limit_counters (key, window_start, count, expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, now(), now())
+		ON CONFLICT (key, window_start) DO UPDATE SET count = rate_limit_counters.count + excluded.count, updated_at = now()
+		RETURNING count`, r.Key, r.WindowStart, n, r.ExpiresAt).Scan(&r.Count).Error
+}
+
+// DeleteExpired drops the counters of windows that have ended
+func (r *RateLimitCounter) DeleteExpired(db *gorm.DB) error {
+	return postgresql.DeleteRecordFromDb(db.Where("expires_at < ?", time.Now()), &RateLimitCounter{})
+}
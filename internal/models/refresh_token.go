@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+type RefreshToken struct {
+	ID         uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID  int       `gorm:"column:account_id; type:int; not null" json:"account_id"`
+	FamilyID   string    `gorm:"column:family_id; type:varchar(250); not null; index" json:"family_id"`
+	TokenHash  string    `gorm:"column:token_hash; type:varchar(250); not null; unique" json:"-"`
+	AccessUuid string    `gorm:"column:access_uuid; type:varchar(250); index" json:"access_uuid"`
+	Used       bool      `gorm:"column:used; type:bool; default:false; not null" json:"used"`
+	Revoked    bool      `gorm:"column:revoked; type:bool; default:false; not null" json:"revoked"`
+	ExpiresAt  time.Time `gorm:"column:expires_at" json:"expires_at"`
+	CreatedAt  time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+func (r *RefreshToken) CreateRefreshToken(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &r)
+	if err != nil {
+		return fmt.Errorf("refresh token creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (r *RefreshToken) GetByTokenHash(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &r, "token_hash = ? ", r.TokenHash)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (r *RefreshToken) GetLatestByAccessUuid(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &r, "access_uuid = ? ", r.AccessUuid)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// MarkAsUsed flags the token as used, it returns false when another request already used it
+func (r *RefreshToken) MarkAsUsed(db *gorm.DB) (bool, error) {
+	rows, err := postgresql.UpdateFieldsWhere(db, &RefreshToken{}, map[string]interface{}{"used": true}, "id = ? and used = ?", r.ID, false)
+	if err != nil {
+		return false, err
+	}
+	r.Used = true
+	return rows == 1, nil
+}
+
+func (r *RefreshToken) RevokeFamily(db *gorm.DB) error {
+	if r.FamilyID == "" {
+		return fmt.Errorf("family id not provided to revoke refresh tokens")
+	}
+	_, err := postgresql.UpdateFieldsWhere(db, &RefreshToken{}, map[string]interface{}{"revoked": true}, "family_id = ? and revoked = ?", r.FamilyID, false)
+	return err
+}
+
+func (r *RefreshToken) RevokeAllByAccountID(db *gorm.DB) error {
+	if r.AccountID == 0 {
+		return fmt.Errorf("account id not provided to revoke refresh tokens")
+	}
+	_, err := postgresql.UpdateFieldsWhere(db, &RefreshToken{}, map[string]interface{}{"revoked": true}, "account_id = ? and revoked = ?", r.AccountID, false)
+	return err
+}
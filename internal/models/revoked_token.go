@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+// RevokedToken denies an access token by its jti until the token would have expired anyway
+type RevokedToken struct {
+	ID        uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	Jti       string    `gorm:"column:jti; type:varchar(250); not null; unique" json:"jti"`
+	AccountID int       `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	ExpiresAt time.Time `gorm:"column:expires_at; index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+}
+
+type ForceLogoutReq struct {
+	AccountID int `json:"account_id" validate:"required"`
+}
+
+func (r *RevokedToken) CreateRevokedToken(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &r)
+	if err != nil {
+		return fmt.Errorf("revoked token creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+// Deny records the jti in a single statement, a jti that is already denied is left as it is
+func (r *RevokedToken) Deny(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO revoked_tokens (jti, account_id, expires_at, created_at)
+		VALUES (?, ?, ?, now())
+		ON CONFLICT (jti) DO NOTHING`, r.Jti, r.AccountID, r.ExpiresAt).Error
+}
+
+func (r *RevokedToken) GetByJti(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &r, "jti = ? ", r.Jti)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// DeleteExpired drops denylist entries whose tokens can no longer be used
+func (r *RevokedToken) DeleteExpired(db *gorm.DB) error {
+	return postgresql.DeleteRecordFromDb(db.Where("expires_at < ?", time.Now()), &RevokedToken{})
+}
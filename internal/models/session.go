@@ -0,0 +1,174 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+type Session struct {
+	ID         uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID  int        `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	AccessUuid string     `gorm:"column:access_uuid; type:varchar(250); not null; unique" json:"-"`
+	FamilyID   string     `gorm:"column:family_id; type:varchar(250); index" json:"-"`
+	ClientID   string     `gorm:"column:client_id; type:varchar(250); index" json:"client_id,omitempty"`
+	Device     string     `gorm:"column:device; type:varchar(250)" json:"device"`
+	IpAddress  string     `gorm:"column:ip_address; type:varchar(250)" json:"ip_address"`
+	UserAgent  string     `gorm:"column:user_agent; type:text" json:"user_agent"`
+	Revoked    bool       `gorm:"column:revoked; type:bool; default:false; not null" json:"-"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"-"`
+	LastSeenAt time.Time  `gorm:"column:last_seen_at" json:"last_seen_at"`
+	ElevatedAt *time.Time `gorm:"column:elevated_at" json:"elevated_at"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+	Current    bool       `gorm:"-" json:"current"`
+}
+
+type StepUpReq struct {
+	Method   string `json:"method" validate:"required,oneof=password otp totp"`
+	Password string `json:"password" validate:"required_if=Method password"`
+	Code     string `json:"code" validate:"required_unless=Method password"`
+}
+
+func (s *Session) CreateSession(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &s)
+	if err != nil {
+		return fmt.Errorf("session creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (s *Session) GetByAccessUuid(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &s, "access_uuid = ? ", s.AccessUuid)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Session) GetByFamilyID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectLatestFromDb(db, &s, "family_id = ? ", s.FamilyID)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Session) GetByIDAndAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &s, "id = ? and account_id = ? ", s.ID, s.AccountID)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (s *Session) GetActiveByAccountID(db *gorm.DB) ([]Session, error) {
+	sessions := []Session{}
+	err := postgresql.SelectAllFromDb(db.Order("last_seen_at desc"), "desc", &sessions, "account_id = ? and revoked = ? and expires_at > ?", s.AccountID, false, time.Now())
+	if err != nil {
+		return sessions, err
+	}
+	return sessions, nil
+}
+
+func (s *Session) GetAllByAccountID(db *gorm.DB) ([]Session, error) {
+	sessions := []Session{}
+	err := postgresql.SelectAllFromDb(db.Order("id desc"), "desc", &sessions, "account_id = ?", s.AccountID)
+	if err != nil {
+		return sessions, err
+	}
+	return sessions, nil
+}
+
+func (s *Session) GetActiveByClientID(db *gorm.DB) ([]Session, error) {
+	sessions := []Session{}
+	err := postgresql.SelectAllFromDb(db, "desc", &sessions, "client_id = ? and revoked = ? and expires_at > ?", s.ClientID, false, time.Now())
+	if err != nil {
+		return sessions, err
+	}
+	return sessions, nil
+}
+
+// IsActive reports whether the session can still be used to authenticate requests
+func (s *Session) IsActive() bool {
+	return !s.Revoked && time.Now().Before(s.ExpiresAt)
+}
+
+func (s *Session) Update(db *gorm.DB) error {
+	_, err := postgresql.SaveAllFields(db, &s)
+	return err
+}
+
+// Touch records activity on the session without rewriting the whole row
+func (s *Session) Touch(db *gorm.DB) error {
+	s.LastSeenAt = time.Now()
+	_, err := postgresql.UpdateFieldsWhere(db, &Session{}, map[string]interface{}{"last_seen_at": s.LastSeenAt}, "id = ?", s.ID)
+	return err
+}
+
+// TouchByAccessUuid records activity on the session of an access token without loading it first
+func (s *Session) TouchByAccessUuid(db *gorm.DB) error {
+	_, err := postgresql.UpdateFieldsWhere(db, &Session{}, map[string]interface{}{"last_seen_at": time.Now()}, "access_uuid = ? and revoked = ?", s.AccessUuid, false)
+	return err
+}
+
+// Elevate records a step-up re-authentication on the session
+func (s *Session) Elevate(db *gorm.DB) error {
+	now := time.Now()
+	_, err := postgresql.UpdateFieldsWhere(db, &Session{}, map[string]interface{}{"elevated_at": now}, "id = ?", s.ID)
+	if err != nil {
+		return err
+	}
+	s.ElevatedAt = &now
+	return nil
+}
+
+// IsElevated reports whether the session stepped up within maxAge
+func (s *Session) IsElevated(maxAge time.Duration) bool {
+	return s.ElevatedAt != nil && time.Since(*s.ElevatedAt) <= maxAge
+}
+
+func (s *Session) Revoke(db *gorm.DB) error {
+	now := time.Now()
+	_, err := postgresql.UpdateFieldsWhere(db, &Session{}, map[string]interface{}{"revoked": true, "revoked_at": now}, "id = ? and revoked = ?", s.ID, false)
+	if err != nil {
+		return err
+	}
+	s.Revoked = true
+	s.RevokedAt = &now
+	return nil
+}
+
+// RevokeAllByAccountID revokes every session of the account except the one with exceptAccessUuid, pass an empty string to revoke all
+func (s *Session) RevokeAllByAccountID(db *gorm.DB, exceptAccessUuid string) error {
+	if s.AccountID == 0 {
+		return fmt.Errorf("account id not provided to revoke sessions")
+	}
+	_, err := postgresql.UpdateFieldsWhere(db, &Session{}, map[string]interface{}{"revoked": true, "revoked_at": time.Now()}, "account_id = ? and revoked = ? and access_uuid <> ?", s.AccountID, false, exceptAccessUuid)
+	return err
+}
+
+// RevokeAllByClientID revokes every session opened with tokens issued to an oauth client
+func (s *Session) RevokeAllByClientID(db *gorm.DB) error {
+	if s.ClientID == "" {
+		return fmt.Errorf("client id not provided to revoke sessions")
+	}
+	_, err := postgresql.UpdateFieldsWhere(db, &Session{}, map[string]interface{}{"revoked": true, "revoked_at": time.Now()}, "client_id = ? and revoked = ?", s.ClientID, false)
+	return err
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+// SignatureNonce remembers the nonce of a signed request until its timestamp leaves the skew window, instances
+// running as a cluster share the nonces through this table so a replay sent to another instance is refused too
+type SignatureNonce struct {
+	ID        uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	Nonce     string    `gorm:"column:nonce; type:text; not null; unique" json:"nonce"`
+	ExpiresAt time.Time `gorm:"column:expires_at; index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+}
+
+// Use records the nonce in a single statement and reports whether it was not in use yet, the nonce of a window
+// that has ended can be used again
+func (s *SignatureNonce) Use(db *gorm.DB) (bool, error) {
+	result := db.Exec(`INSERT INTO signature_nonces (nonce, expires_at, created_at)
+		VALUES (?, ?, now())
+		ON CONFLICT (nonce) DO UPDATE SET expires_at = excluded.expires_at, created_at = now()
+		WHERE signature_nonces.expires_at < now()`, s.Nonce, s.ExpiresAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteExpired drops the nonces whose requests the timestamp check refuses on its own
+func (s *SignatureNonce) DeleteExpired(db *gorm.DB) error {
+	return postgresql.DeleteRecordFromDb(db.Where("expires_at < ?", time.Now()), &SignatureNonce{})
+}
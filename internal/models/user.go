@@ -12,8 +12,10 @@ import (
 )
 
 type UserIdentity struct {
-	AccountID int    `json:"account_id"`
-	Type      string `json:"type"`
+	AccountID  int    `json:"account_id"`
+	Type       string `json:"type"`
+	AccessUuid string `json:"access_uuid"`
+	ActorID    int    `json:"actor_id"`
 }
 
 var (
@@ -121,6 +123,18 @@ func (u *User) GetUserByID(db *gorm.DB) (int, error) {
 	return http.StatusOK, nil
 }
 
+func (u *User) GetUserByEmailAddress(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &u, "LOWER(email_address) = ?", strings.ToLower(u.EmailAddress))
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
 func (u *User) Update(db *gorm.DB) error {
 	_, err := postgresql.SaveAllFields(db, &u)
 	return err
@@ -173,6 +187,12 @@ func (u *User) SelectByBusinessID(db *gorm.DB) ([]User, error) {
 	return users, nil
 }
 
+// ClearLoginAccessTokens blanks the access tokens earlier versions stored in plaintext on the user row
+func ClearLoginAccessTokens(db *gorm.DB) error {
+	_, err := postgresql.UpdateFieldsWhere(db, &User{}, map[string]interface{}{"login_access_token": "", "login_access_token_expires_in": ""}, "login_access_token <> ?", "")
+	return err
+}
+
 func (u *User) UpdateAllFields(db *gorm.DB) error {
 	_, err := postgresql.SaveAllFields(db, &u)
 	return err
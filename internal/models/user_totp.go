@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+type UserTotp struct {
+	ID           uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID    int        `gorm:"column:account_id; type:int; not null; unique" json:"account_id"`
+	Secret       string     `gorm:"column:secret; type:text; not null" json:"-"`
+	Confirmed    bool       `gorm:"column:confirmed; type:bool; default:false; not null" json:"confirmed"`
+	ConfirmedAt  *time.Time `gorm:"column:confirmed_at" json:"confirmed_at"`
+	LastUsedStep int64      `gorm:"column:last_used_step; type:bigint; default:0; not null" json:"-"`
+	CreatedAt    time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type ConfirmTotpReq struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableTotpReq struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type LoginMfaReq struct {
+	MfaToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+func (u *UserTotp) CreateUserTotp(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &u)
+	if err != nil {
+		return fmt.Errorf("user totp creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (u *UserTotp) GetByAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &u, "account_id = ? ", u.AccountID)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (u *UserTotp) Update(db *gorm.DB) error {
+	_, err := postgresql.SaveAllFields(db, &u)
+	return err
+}
+
+func (u *UserTotp) Delete(db *gorm.DB) error {
+	err := postgresql.DeleteRecordFromDb(db, &u)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsEnabledForAccount reports whether the account has a confirmed authenticator app
+func (u *UserTotp) IsEnabledForAccount(db *gorm.DB) (bool, error) {
+	code, err := u.GetByAccountID(db)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return false, err
+		}
+		return false, nil
+	}
+	return u.Confirmed, nil
+}
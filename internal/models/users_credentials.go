@@ -87,3 +87,12 @@ func (u *UsersCredential) Update(db *gorm.DB) error {
 	_, err := postgresql.SaveAllFields(db, &u)
 	return err
 }
+
+func (u *UsersCredential) GetAllByAccountID(db *gorm.DB) ([]UsersCredential, error) {
+	credentials := []UsersCredential{}
+	err := postgresql.SelectAllFromDb(db, "asc", &credentials, "account_id = ? ", u.AccountID)
+	if err != nil {
+		return credentials, err
+	}
+	return credentials, nil
+}
@@ -8,9 +8,13 @@ type ValidateOnDBReq struct {
 }
 
 type ValidateAuthorizationReq struct {
-	Type               string `validate:"required" json:"type"`
-	AuthorizationToken string `json:"authorization-token"`
-	VApp               string `json:"v-app"`
-	VPrivateKey        string `json:"v-private-key"`
-	VPublicKey         string `json:"v-public-key"`
+	Type               string   `validate:"required" json:"type"`
+	AuthorizationToken string   `json:"authorization-token"`
+	VApp               string   `json:"v-app"`
+	VPrivateKey        string   `json:"v-private-key"`
+	VPublicKey         string   `json:"v-public-key"`
+	Scopes             []string `json:"scopes"`
+	Method             string   `json:"method"`
+	Path               string   `json:"path"`
+	IpAddress          string   `json:"ip_address"`
 }
@@ -43,3 +43,12 @@ func (w *WalletHistory) CreateWalletHistory(db *gorm.DB) error {
 	}
 	return nil
 }
+
+func (w *WalletHistory) GetAllByAccountID(db *gorm.DB) ([]WalletHistory, error) {
+	histories := []WalletHistory{}
+	err := postgresql.SelectAllFromDb(db.Order("id asc"), "asc", &histories, "account_id = ? ", w.AccountID)
+	if err != nil {
+		return histories, err
+	}
+	return histories, nil
+}
@@ -46,3 +46,13 @@ func (w *WalletTransaction) CreateWalletTransaction(db *gorm.DB) error {
 	}
 	return nil
 }
+
+// GetAllByAccountID returns the transactions the account sent or received, SenderAccountID holds the account id
+func (w *WalletTransaction) GetAllByAccountID(db *gorm.DB) ([]WalletTransaction, error) {
+	transactions := []WalletTransaction{}
+	err := postgresql.SelectAllFromDb(db.Order("id asc"), "asc", &transactions, "sender_account_id = ? or receiver_account_id = ?", w.SenderAccountID, w.SenderAccountID)
+	if err != nil {
+		return transactions, err
+	}
+	return transactions, nil
+}
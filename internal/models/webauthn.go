@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"gorm.io/gorm"
+)
+
+const (
+	WebauthnCeremonyRegistration = "registration"
+	WebauthnCeremonyLogin        = "login"
+)
+
+type WebauthnCredential struct {
+	ID              uint       `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	AccountID       int        `gorm:"column:account_id; type:int; not null; index" json:"account_id"`
+	Name            string     `gorm:"column:name; type:varchar(250)" json:"name"`
+	CredentialID    string     `gorm:"column:credential_id; type:varchar(1024); not null; unique" json:"credential_id"`
+	PublicKey       []byte     `gorm:"column:public_key; type:bytea; not null" json:"-"`
+	AttestationType string     `gorm:"column:attestation_type; type:varchar(250)" json:"attestation_type"`
+	Transports      string     `gorm:"column:transports; type:varchar(250)" json:"transports"`
+	Aaguid          []byte     `gorm:"column:aaguid; type:bytea" json:"-"`
+	SignCount       int64      `gorm:"column:sign_count; type:bigint; default:0; not null" json:"-"`
+	LastUsedAt      *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	CreatedAt       time.Time  `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+// WebauthnChallenge keeps the server side state of a ceremony between its begin and finish calls
+type WebauthnChallenge struct {
+	ID          uint      `gorm:"column:id; type:uint; not null; primaryKey; unique; autoIncrement" json:"id"`
+	Challenge   string    `gorm:"column:challenge; type:varchar(250); not null; unique" json:"challenge"`
+	AccountID   int       `gorm:"column:account_id; type:int" json:"account_id"`
+	Ceremony    string    `gorm:"column:ceremony; type:varchar(250); not null" json:"ceremony"`
+	SessionData string    `gorm:"column:session_data; type:text; not null" json:"-"`
+	ExpiresAt   time.Time `gorm:"column:expires_at; index" json:"expires_at"`
+	CreatedAt   time.Time `gorm:"column:created_at; autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at; autoUpdateTime" json:"updated_at"`
+}
+
+type WebauthnLoginBeginReq struct {
+	Username     string `json:"username"`
+	EmailAddress string `json:"email_address"`
+	PhoneNumber  string `json:"phone_number"`
+}
+
+func (w *WebauthnCredential) CreateWebauthnCredential(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &w)
+	if err != nil {
+		return fmt.Errorf("webauthn credential creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (w *WebauthnCredential) GetAllByAccountID(db *gorm.DB) ([]WebauthnCredential, error) {
+	credentials := []WebauthnCredential{}
+	err := postgresql.SelectAllFromDb(db, "asc", &credentials, "account_id = ? ", w.AccountID)
+	if err != nil {
+		return credentials, err
+	}
+	return credentials, nil
+}
+
+func (w *WebauthnCredential) GetByIDAndAccountID(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &w, "id = ? and account_id = ? ", w.ID, w.AccountID)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (w *WebauthnCredential) Update(db *gorm.DB) error {
+	_, err := postgresql.SaveAllFields(db, &w)
+	return err
+}
+
+func (w *WebauthnCredential) Delete(db *gorm.DB) error {
+	err := postgresql.DeleteRecordFromDb(db, &w)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *WebauthnChallenge) CreateWebauthnChallenge(db *gorm.DB) error {
+	err := postgresql.CreateOneRecord(db, &w)
+	if err != nil {
+		return fmt.Errorf("webauthn challenge creation failed: %v", err.Error())
+	}
+	return nil
+}
+
+func (w *WebauthnChallenge) GetByChallengeAndCeremony(db *gorm.DB) (int, error) {
+	err, nilErr := postgresql.SelectOneFromDb(db, &w, "challenge = ? and ceremony = ? ", w.Challenge, w.Ceremony)
+	if nilErr != nil {
+		return http.StatusBadRequest, nilErr
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// Consume deletes the challenge, it returns false when a concurrent request already answered it
+func (w *WebauthnChallenge) Consume(db *gorm.DB) (bool, error) {
+	result := db.Where("challenge = ? and ceremony = ?", w.Challenge, w.Ceremony).Delete(&WebauthnChallenge{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteExpired drops the challenges of ceremonies that were started and never finished
+func (w *WebauthnChallenge) DeleteExpired(db *gorm.DB) error {
+	return postgresql.DeleteRecordFromDb(db.Where("expires_at < ?", time.Now()), &WebauthnChallenge{})
+}
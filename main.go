@@ -1,13 +1,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"strconv"
 
 	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/internal/models/migrations"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/passwordpolicy"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/services/auth"
 
 	"github.com/vesicash/auth-ms/utility"
 
@@ -16,6 +20,9 @@ import (
 )
 
 func main() {
+	hashApiKeys := flag.Bool("hash-api-keys", false, "hash the api private keys stored in plaintext and exit")
+	flag.Parse()
+
 	logger := utility.NewLogger() //Warning !!!!! Do not recreate this action anywhere on the app
 
 	configuration := config.Setup(logger, "./app")
@@ -29,6 +36,29 @@ func main() {
 		migrations.RunAllMigrations(db)
 	}
 
+	if *hashApiKeys {
+		hashed, err := migrations.HashApiKeys(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		utility.LogAndPrint(logger, fmt.Sprintf("hashed %v api keys", hashed))
+		return
+	}
+
+	err := middleware.LoadSigningKeys(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go middleware.StartSigningKeyRotation(logger)
+	go middleware.StartDenylistCleanup(logger, db)
+	go middleware.StartRateLimitCleanup(logger, db)
+	go auth.StartErasureWorker(logger, db)
+
+	err = passwordpolicy.LoadBreachedPasswords(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	r := router.Setup(logger, validatorRef, db, &configuration.App)
 	rM := router.SetupMetrics(&configuration.App)
 
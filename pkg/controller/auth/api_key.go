@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/auth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func (base *Controller) CreateApiKey(c *gin.Context) {
+	var (
+		req models.CreateApiKeyReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	accessToken, code, err := auth.CreateApiKeyService(base.Db, models.MyIdentity.AccountID, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "api key created", accessToken)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) GetApiKeys(c *gin.Context) {
+	accessTokens, code, err := auth.ListApiKeysService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "api keys retrieved", accessTokens)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) UpdateApiKey(c *gin.Context) {
+	var (
+		req      models.UpdateApiKeyReq
+		keyIDStr = c.Param("key_id")
+	)
+
+	keyID, err := strconv.Atoi(keyIDStr)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "invalid key id type", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	accessToken, code, err := auth.UpdateApiKeyService(base.Db, models.MyIdentity.AccountID, uint(keyID), req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "api key updated", accessToken)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) DeleteApiKey(c *gin.Context) {
+	var (
+		keyIDStr = c.Param("key_id")
+	)
+
+	keyID, err := strconv.Atoi(keyIDStr)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "invalid key id type", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	code, err := auth.DeleteApiKeyService(base.Db, models.MyIdentity.AccountID, uint(keyID))
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "api key revoked", nil)
+	c.JSON(http.StatusOK, rd)
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/auth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func (base *Controller) BanAccount(c *gin.Context) {
+	var (
+		req models.BanAccountReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	ban, code, err := auth.BanAccountService(base.Db, models.MyIdentity.AccountID, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "account banned", ban)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) UnbanAccount(c *gin.Context) {
+	var (
+		req models.UnbanAccountReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	ban, code, err := auth.UnbanAccountService(base.Db, models.MyIdentity.AccountID, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "ban lifted", ban)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) GetBans(c *gin.Context) {
+	var (
+		accountIDParam = c.Query("account_id")
+	)
+
+	bans, code, err := auth.ListBansService(base.Db, accountIDParam)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "bans retrieved", bans)
+	c.JSON(http.StatusOK, rd)
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/auth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func (base *Controller) RequestContactChange(c *gin.Context) {
+	var (
+		req models.RequestContactChangeReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	contactChange, code, err := auth.RequestContactChangeService(base.Logger, base.Db, models.MyIdentity.AccountID, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "a confirmation code has been sent to the new "+req.Channel, contactChange)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) ConfirmContactChange(c *gin.Context) {
+	var (
+		req models.ConfirmContactChangeReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	contactChange, code, err := auth.ConfirmContactChangeService(base.Logger, base.Db, models.MyIdentity.AccountID, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "contact details changed", contactChange)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) UndoContactChange(c *gin.Context) {
+	var (
+		req models.UndoContactChangeReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	code, err := auth.UndoContactChangeService(base.Db, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "contact change undone, log in again to continue", nil)
+	c.JSON(http.StatusOK, rd)
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/auth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func (base *Controller) ExportData(c *gin.Context) {
+	data, code, err := auth.ExportDataService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="vesicash-data-export-%v.json"`, models.MyIdentity.AccountID))
+	rd := utility.BuildSuccessResponse(http.StatusOK, "data exported", data)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) RequestErasure(c *gin.Context) {
+	erasureRequest, code, err := auth.RequestErasureService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "account erasure scheduled", erasureRequest)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) GetErasure(c *gin.Context) {
+	erasureRequest, code, err := auth.GetErasureService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "erasure request retrieved", erasureRequest)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) CancelErasure(c *gin.Context) {
+	erasureRequest, code, err := auth.CancelErasureService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "account erasure cancelled", erasureRequest)
+	c.JSON(http.StatusOK, rd)
+}
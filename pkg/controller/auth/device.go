@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/auth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func (base *Controller) AuthorizeDevice(c *gin.Context) {
+	var (
+		req models.AuthorizeDeviceReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	code, err := auth.AuthorizeDeviceService(base.Db, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "device authorized, you can now log in", nil)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) GetDevices(c *gin.Context) {
+	devices, code, err := auth.ListDevicesService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "devices retrieved", devices)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) ForgetDevice(c *gin.Context) {
+	var (
+		deviceIDStr = c.Param("device_id")
+	)
+
+	deviceID, err := strconv.Atoi(deviceIDStr)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "invalid device id type", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	code, err := auth.ForgetDeviceService(base.Db, models.MyIdentity.AccountID, uint(deviceID))
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "device removed", nil)
+	c.JSON(http.StatusOK, rd)
+}
@@ -5,13 +5,24 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
 	"github.com/vesicash/auth-ms/services/auth"
 	"github.com/vesicash/auth-ms/utility"
 )
 
 func (base *Controller) GetDisbursements(c *gin.Context) {
 
-	data, code, err := auth.GetDisbursementsService(base.Logger, base.Db, models.MyIdentity.AccountID)
+	var (
+		data []map[string]interface{}
+		code int
+		err  error
+	)
+
+	if middleware.IsSandbox(c) {
+		data, code, err = auth.GetSandboxDisbursementsService()
+	} else {
+		data, code, err = auth.GetDisbursementsService(base.Logger, base.Db, models.MyIdentity.AccountID)
+	}
 	if err != nil {
 		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
 		c.JSON(code, rd)
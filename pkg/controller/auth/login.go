@@ -3,11 +3,10 @@ package auth
 import (
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
 	"net/http"
-	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
 	"github.com/vesicash/auth-ms/services/auth"
 	"github.com/vesicash/auth-ms/utility"
 )
@@ -38,29 +37,24 @@ func (base *Controller) Login(c *gin.Context) {
 		return
 	}
 
-	rd := utility.BuildSuccessResponse(http.StatusOK, "login successful", data)
+	message := "login successful"
+	if data["mfa_required"] == true {
+		message = "mfa required"
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, message, data)
 	c.JSON(http.StatusOK, rd)
 
 }
 
 func (base *Controller) Logout(c *gin.Context) {
-	user := models.User{AccountID: uint(models.MyIdentity.AccountID)}
-	code, err := user.GetUserByAccountID(base.Db.Auth)
+	code, err := auth.RevokeSessionByAccessUuid(base.Db, models.MyIdentity.AccessUuid)
 	if err != nil {
 		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
 		c.JSON(code, rd)
 		return
 	}
 
-	user.LoginAccessToken = ""
-	user.LoginAccessTokenExpiresIn = strconv.Itoa(int(time.Now().Unix()))
-	err = user.Update(base.Db.Auth)
-	if err != nil {
-		rd := utility.BuildErrorResponse(http.StatusInternalServerError, "error", err.Error(), err, nil)
-		c.JSON(http.StatusInternalServerError, rd)
-		return
-	}
-
 	rd := utility.BuildSuccessResponse(http.StatusOK, "logout successful", nil)
 	c.JSON(http.StatusOK, rd)
 }
@@ -168,7 +162,17 @@ func (base *Controller) RevokeTokenHandler(c *gin.Context) {
 }
 
 func (base *Controller) GetUserWalletBalance(c *gin.Context) {
-	data, code, err := auth.GetUserWalletBalanceService(base.Db, models.MyIdentity.AccountID)
+	var (
+		data interface{}
+		code int
+		err  error
+	)
+
+	if middleware.IsSandbox(c) {
+		data, code, err = auth.GetSandboxWalletBalanceService(base.Db, models.MyIdentity.AccountID)
+	} else {
+		data, code, err = auth.GetUserWalletBalanceService(base.Db, models.MyIdentity.AccountID)
+	}
 	if err != nil {
 		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
 		c.JSON(code, rd)
@@ -12,9 +12,27 @@ import (
 
 func (base *Controller) SendOTP(c *gin.Context) {
 	var (
-		req = models.SendOtpTokenReq{AccountID: models.MyIdentity.AccountID}
+		ownReq = models.SendOwnOtpReq{}
 	)
 
+	// the body is optional, requests without one get a login otp
+	if c.Request.ContentLength > 0 {
+		err := c.ShouldBind(&ownReq)
+		if err != nil {
+			rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+			c.JSON(http.StatusBadRequest, rd)
+			return
+		}
+	}
+
+	err := base.Validator.Struct(&ownReq)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	req := models.SendOtpTokenReq{AccountID: models.MyIdentity.AccountID, Purpose: ownReq.Purpose, Authenticated: true}
 	code, err := auth.SendOtpService(base.Logger, req, base.Db)
 	if err != nil {
 		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
@@ -1,10 +1,12 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/passwordpolicy"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
 	"github.com/vesicash/auth-ms/services/auth"
 	"github.com/vesicash/auth-ms/utility"
@@ -54,9 +56,10 @@ func (base *Controller) RequestPasswordReset(c *gin.Context) {
 func (base *Controller) UpdatePasswordWithToken(c *gin.Context) {
 	var (
 		req struct {
-			AccountID int    `json:"account_id" validate:"required" pgvalidate:"exists=auth$users$account_id"`
-			Token     int    `json:"token" validate:"required"`
-			Password  string `json:"password" validate:"required"`
+			AccountID  int    `json:"account_id" validate:"required_without=ResetToken" pgvalidate:"exists=auth$users$account_id"`
+			Token      int    `json:"token" validate:"required_without=ResetToken"`
+			ResetToken string `json:"reset_token"`
+			Password   string `json:"password" validate:"required"`
 		}
 	)
 
@@ -81,9 +84,9 @@ func (base *Controller) UpdatePasswordWithToken(c *gin.Context) {
 		return
 	}
 
-	code, err := auth.UpdatePasswordWithTokenService(base.Logger, base.Db, req.AccountID, req.Token, req.Password)
+	code, err := auth.UpdatePasswordWithTokenService(base.Logger, base.Db, req.AccountID, req.Token, req.ResetToken, req.Password)
 	if err != nil {
-		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		rd := passwordErrorResponse(code, err)
 		c.JSON(code, rd)
 		return
 	}
@@ -124,7 +127,7 @@ func (base *Controller) UpdatePassword(c *gin.Context) {
 
 	code, err := auth.UpdatePassword(base.Db, models.MyIdentity.AccountID, req.OldPassword, req.NewPassword)
 	if err != nil {
-		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		rd := passwordErrorResponse(code, err)
 		c.JSON(code, rd)
 		return
 	}
@@ -133,3 +136,12 @@ func (base *Controller) UpdatePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, rd)
 
 }
+
+// passwordErrorResponse returns the rules broken by a rejected password as validation errors
+func passwordErrorResponse(code int, err error) utility.Response {
+	var policyErr *passwordpolicy.Error
+	if errors.As(err, &policyErr) {
+		return utility.BuildErrorResponse(code, "error", err.Error(), gin.H{"password": policyErr.Violations}, nil)
+	}
+	return utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+}
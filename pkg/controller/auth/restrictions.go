@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
 	"github.com/vesicash/auth-ms/services/auth"
 	"github.com/vesicash/auth-ms/utility"
 )
@@ -44,7 +45,7 @@ func (base *Controller) UpgradeUserTier(c *gin.Context) {
 
 func (base *Controller) GetUserRestrictions(c *gin.Context) {
 
-	data, code, err := auth.GetUserRestrictionsService(base.Logger, base.Db, models.MyIdentity.AccountID)
+	data, code, err := auth.GetUserRestrictionsService(base.Logger, base.Db, models.MyIdentity.AccountID, middleware.BearerToken(c))
 	if err != nil {
 		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
 		c.JSON(code, rd)
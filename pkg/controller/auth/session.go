@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/auth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func (base *Controller) GetSessions(c *gin.Context) {
+	sessions, code, err := auth.ListSessionsService(base.Db, models.MyIdentity.AccountID, models.MyIdentity.AccessUuid)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "sessions retrieved", sessions)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) RevokeSession(c *gin.Context) {
+	var (
+		sessionIDStr = c.Param("session_id")
+	)
+
+	sessionID, err := strconv.Atoi(sessionIDStr)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "invalid session id type", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	code, err := auth.RevokeSessionService(base.Db, models.MyIdentity.AccountID, uint(sessionID))
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "session revoked", nil)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) RevokeAllSessions(c *gin.Context) {
+	code, err := auth.RevokeAllSessionsService(base.Db, models.MyIdentity.AccountID, models.MyIdentity.AccessUuid)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "other sessions revoked", nil)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) ForceLogout(c *gin.Context) {
+	var (
+		req models.ForceLogoutReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	code, err := auth.ForceLogoutService(base.Db, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "user logged out of every session", nil)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) StepUp(c *gin.Context) {
+	var (
+		req models.StepUpReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	data, code, err := auth.StepUpService(c, base.Logger, base.Db, models.MyIdentity.AccountID, models.MyIdentity.AccessUuid, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "session elevated", data)
+	c.JSON(http.StatusOK, rd)
+}
@@ -46,7 +46,7 @@ func (base *Controller) Signup(c *gin.Context) {
 
 	data, code, err := auth.SignupService(base.Logger, reqData, base.Db)
 	if err != nil {
-		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		rd := passwordErrorResponse(code, err)
 		c.JSON(code, rd)
 		return
 	}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/auth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func (base *Controller) BeginWebauthnRegistration(c *gin.Context) {
+	options, code, err := auth.BeginWebauthnRegistrationService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "registration options", options)
+	c.JSON(http.StatusOK, rd)
+}
+
+// FinishWebauthnRegistration expects the PublicKeyCredential returned by navigator.credentials.create as the request body
+func (base *Controller) FinishWebauthnRegistration(c *gin.Context) {
+	var (
+		name = c.Query("name")
+	)
+
+	credential, code, err := auth.FinishWebauthnRegistrationService(base.Db, models.MyIdentity.AccountID, name, c.Request.Body)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "passkey registered", credential)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) GetWebauthnCredentials(c *gin.Context) {
+	credentials, code, err := auth.ListWebauthnCredentialsService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "passkeys retrieved", credentials)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) DeleteWebauthnCredential(c *gin.Context) {
+	var (
+		credentialIDStr = c.Param("credential_id")
+	)
+
+	credentialID, err := strconv.Atoi(credentialIDStr)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "invalid credential id type", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	code, err := auth.DeleteWebauthnCredentialService(base.Db, models.MyIdentity.AccountID, uint(credentialID))
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "passkey deleted", nil)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) BeginWebauthnLogin(c *gin.Context) {
+	var (
+		req models.WebauthnLoginBeginReq
+	)
+
+	if c.Request.ContentLength > 0 {
+		err := c.ShouldBind(&req)
+		if err != nil {
+			rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+			c.JSON(http.StatusBadRequest, rd)
+			return
+		}
+	}
+
+	options, code, err := auth.BeginWebauthnLoginService(base.Db, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "login options", options)
+	c.JSON(http.StatusOK, rd)
+}
+
+// FinishWebauthnLogin expects the PublicKeyCredential returned by navigator.credentials.get as the request body
+func (base *Controller) FinishWebauthnLogin(c *gin.Context) {
+	data, code, err := auth.FinishWebauthnLoginService(c, base.Logger, c.Request.Body, base.Db)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "login successful", data)
+	c.JSON(http.StatusOK, rd)
+}
@@ -27,10 +27,10 @@ func (base *Controller) GetAccessToken(c *gin.Context) {
 func (base *Controller) GetAccessTokenByKey(c *gin.Context) {
 	var (
 		key         = c.Param("key")
-		accessToken = models.AccessToken{PrivateKey: key, PublicKey: key, IsLive: true}
+		accessToken = models.AccessToken{PrivateKey: key, PublicKey: key}
 	)
 
-	code, err := accessToken.LiveTokensWithPublicOrPrivateKey(base.Db.Auth)
+	code, err := accessToken.ActiveTokensWithPublicOrPrivateKey(base.Db.Auth)
 	if err != nil {
 		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
 		c.JSON(code, rd)
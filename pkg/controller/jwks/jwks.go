@@ -0,0 +1,19 @@
+package jwks
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+type Controller struct {
+	Logger *utility.Logger
+}
+
+// Get serves the raw RFC 7517 key set, other services cache it and verify tokens offline
+func (base *Controller) Get(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, middleware.JWKS())
+}
@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/oauth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// Authorize describes the consent screen for an authorization request, the frontend passes the query
+// parameters it received from the client through unchanged
+func (base *Controller) Authorize(c *gin.Context) {
+	var (
+		req models.OauthAuthorizeReq
+	)
+
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request query", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	consent, code, err := oauth.AuthorizeService(base.Db, models.MyIdentity.AccountID, req)
+	if err != nil {
+		authorizeError(c, code, err)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "consent required", consent)
+	c.JSON(http.StatusOK, rd)
+}
+
+// Consent records the user's answer on the consent screen, the response tells the frontend where to redirect
+func (base *Controller) Consent(c *gin.Context) {
+	var (
+		req models.OauthConsentReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	data, code, err := oauth.ConsentService(base.Db, models.MyIdentity.AccountID, req)
+	if err != nil {
+		authorizeError(c, code, err)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "authorization granted", data)
+	c.JSON(http.StatusOK, rd)
+}
+
+func authorizeError(c *gin.Context, code int, err error) {
+	var (
+		oauthErr *oauth.Error
+		data     interface{}
+	)
+
+	if errors.As(err, &oauthErr) {
+		if redirect := oauthErr.Redirect(); redirect != "" {
+			data = gin.H{"redirect_to": redirect}
+		}
+	}
+
+	rd := utility.BuildErrorResponse(code, "error", err.Error(), err, data)
+	c.JSON(code, rd)
+}
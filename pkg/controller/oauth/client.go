@@ -0,0 +1,68 @@
+package oauth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/oauth"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func (base *Controller) CreateClient(c *gin.Context) {
+	var (
+		req models.CreateOauthClientReq
+	)
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Failed to parse request body", err, nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	err = base.Validator.Struct(&req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(http.StatusBadRequest, "error", "Validation failed", utility.ValidationResponse(err, base.Validator), nil)
+		c.JSON(http.StatusBadRequest, rd)
+		return
+	}
+
+	client, code, err := oauth.CreateClientService(base.Db, models.MyIdentity.AccountID, req)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusCreated, "oauth client created", client)
+	c.JSON(http.StatusCreated, rd)
+}
+
+func (base *Controller) GetClients(c *gin.Context) {
+	clients, code, err := oauth.ListClientsService(base.Db, models.MyIdentity.AccountID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "oauth clients retrieved", clients)
+	c.JSON(http.StatusOK, rd)
+}
+
+func (base *Controller) DeleteClient(c *gin.Context) {
+	var (
+		clientID = c.Param("client_id")
+	)
+
+	code, err := oauth.DeleteClientService(base.Db, models.MyIdentity.AccountID, clientID)
+	if err != nil {
+		rd := utility.BuildErrorResponse(code, "error", err.Error(), err, nil)
+		c.JSON(code, rd)
+		return
+	}
+
+	rd := utility.BuildSuccessResponse(http.StatusOK, "oauth client deleted", nil)
+	c.JSON(http.StatusOK, rd)
+}
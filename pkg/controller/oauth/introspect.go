@@ -0,0 +1,86 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/services/oauth"
+)
+
+// Introspect is the RFC 7662 introspection endpoint, other Vesicash services authenticate with the app key and
+// oauth clients with their credentials
+func (base *Controller) Introspect(c *gin.Context) {
+	req, trusted, ok := base.bindTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	introspection, code, err := oauth.IntrospectService(base.Db, req, trusted)
+	if err != nil {
+		base.oauthError(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, introspection)
+}
+
+// Revoke is the RFC 7009 revocation endpoint, it answers 200 with an empty body even for unknown tokens
+func (base *Controller) Revoke(c *gin.Context) {
+	req, trusted, ok := base.bindTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	code, err := oauth.RevokeService(base.Db, req, trusted)
+	if err != nil {
+		base.oauthError(c, code, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (base *Controller) bindTokenRequest(c *gin.Context) (models.OauthIntrospectReq, bool, bool) {
+	var (
+		req models.OauthIntrospectReq
+	)
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &oauth.Error{Code: oauth.ErrInvalidRequest, Description: "failed to parse request body"})
+		return req, false, false
+	}
+
+	if middleware.GetHeader(c, "v-app") != "" {
+		msg, ok := middleware.AppType.ValidateAppType(c, base.Db)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, &oauth.Error{Code: oauth.ErrInvalidClient, Description: msg})
+			return req, false, false
+		}
+		return req, true, true
+	}
+
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID, _ = url.QueryUnescape(clientID)
+		req.ClientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+	return req, false, true
+}
+
+func (base *Controller) oauthError(c *gin.Context, code int, err error) {
+	var oauthErr *oauth.Error
+	if !errors.As(err, &oauthErr) {
+		oauthErr = &oauth.Error{Code: oauth.ErrServerError}
+	}
+	if code == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(code, oauthErr)
+}
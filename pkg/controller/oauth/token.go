@@ -0,0 +1,40 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/services/oauth"
+)
+
+// Token is the RFC 6749 token endpoint, unlike the rest of the api it answers in the format oauth client
+// libraries expect rather than the usual response envelope
+func (base *Controller) Token(c *gin.Context) {
+	var (
+		req models.OauthTokenReq
+	)
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &oauth.Error{Code: oauth.ErrInvalidRequest, Description: "failed to parse request body"})
+		return
+	}
+
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID, _ = url.QueryUnescape(clientID)
+		req.ClientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+
+	token, code, err := oauth.TokenService(c, base.Db, req)
+	if err != nil {
+		base.oauthError(c, code, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
@@ -4,12 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
 	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/internal/models"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
@@ -31,6 +28,10 @@ type (
 )
 
 func Authorize(db postgresql.Databases, authTypes ...AuthorizationType) gin.HandlerFunc {
+	return authorize(db, nil, authTypes)
+}
+
+func authorize(db postgresql.Databases, scopes []string, authTypes AuthorizationTypes) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		if len(authTypes) > 0 {
@@ -39,12 +40,25 @@ func Authorize(db postgresql.Databases, authTypes ...AuthorizationType) gin.Hand
 			for _, v := range authTypes {
 				ms, status := v.ValidateAuthorizationRequest(c, db)
 				if status {
+					if granted, ok := TokenScopes(c); ok && !HasScopes(granted, scopes) {
+						c.AbortWithStatusJSON(http.StatusForbidden, insufficientScopeResponse())
+						return
+					}
+					if !limitPrincipal(c, db) {
+						return
+					}
+					auditImpersonation(c, db)
 					return
 				}
 				msg = ms
 			}
+			if !limitIP(c) {
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusUnauthorized, utility.UnauthorisedResponse(http.StatusUnauthorized, fmt.Sprint(http.StatusUnauthorized), "Unauthorized", msg))
+			return
 		}
+		limitIP(c)
 	}
 }
 
@@ -92,64 +106,30 @@ func (at AuthorizationType) ValidateAuthType(c *gin.Context, db postgresql.Datab
 		return invalidToken, false
 	}
 
-	token, err := TokenValid(bearerToken)
-	if err != nil {
-		return invalidToken, false
-	}
-
-	claims := token.Claims.(jwt.MapClaims)
-	activeUserType, ok := claims["type"].(string) //convert the interface to string
-	if !ok {
-		return invalidToken, false
-	}
-
-	activeUserAccountID, ok := claims["account_id"].(float64) //convert the interface to float
-	if !ok {
-		return invalidToken, false
-	}
-
-	authoriseStatus, ok := claims["authorised"].(bool) //check if token is authorised for middleware
-	if !ok && !authoriseStatus {
-		return invalidToken, false
+	introspection := IntrospectAccessToken(db, bearerToken)
+	if !introspection.Active {
+		return introspection.Message, false
 	}
 
 	myIdentity := models.UserIdentity{
-		AccountID: int(activeUserAccountID),
-		Type:      activeUserType,
-	}
-
-	user := models.User{AccountID: uint(myIdentity.AccountID)}
-	code, err := user.GetUserByAccountID(db.Auth)
-	if err != nil {
-		if code == http.StatusInternalServerError {
-			return err.Error(), false
-		}
-		return "user does not exist", false
-	}
-
-	if user.LoginAccessToken != bearerToken {
-		return invalidToken, false
-	}
-
-	if user.LoginAccessTokenExpiresIn == "" {
-		return invalidToken, false
-	}
-
-	parseInt, err := strconv.Atoi(user.LoginAccessTokenExpiresIn)
-	if err != nil {
-		return invalidToken, false
-	}
-
-	unixTimeUTC := time.Unix(int64(parseInt), 0)
-	if time.Now().After(unixTimeUTC) {
-		return "expired token", false
+		AccountID:  introspection.AccountID,
+		Type:       introspection.AccountType,
+		AccessUuid: introspection.Jti,
+		ActorID:    introspection.ActorAccountID(),
 	}
 
+	TouchSession(db, introspection.Jti)
+	setTokenScopes(c, introspection)
+	setImpersonation(c, introspection)
+	setRateLimitPrincipal(c, fmt.Sprintf("account:%v", introspection.AccountID), introspection.AccountID, introspection.AccountType, false)
 	models.MyIdentity = &myIdentity
 	return "authorized", true
 }
 
 func (at AuthorizationType) ValidateBusinessType(c *gin.Context, db postgresql.Databases) (string, bool) {
+	if at.usesClientToken(c) {
+		return at.validateClientToken(c, db)
+	}
 	_, msg, status := at.CheckAccessTokens(c, db)
 	return msg, status
 }
@@ -173,6 +153,10 @@ func (at AuthorizationType) ValidateBusinessAdminType(c *gin.Context, db postgre
 	if !status {
 		return msg, status
 	}
+	// admin routes act on live accounts, sandbox keys never reach them
+	if token.Environment == models.ApiKeyEnvironmentTest {
+		return "test keys cannot be used on admin routes", false
+	}
 
 	user := models.User{AccountID: uint(token.AccountID)}
 	_, err := user.GetUserByAccountID(db.Auth)
@@ -186,10 +170,22 @@ func (at AuthorizationType) ValidateBusinessAdminType(c *gin.Context, db postgre
 	if user.AccountType != "admin" {
 		return "access denied", false
 	}
+
+	models.MyIdentity = &models.UserIdentity{
+		AccountID: int(user.AccountID),
+		Type:      user.AccountType,
+	}
 	return "authorized", true
 }
 
 func (at AuthorizationType) ValidateApiType(c *gin.Context, db postgresql.Databases) (string, bool) {
+	if at.usesSignature(c) {
+		_, msg, status := at.checkSignedRequest(c, db)
+		return msg, status
+	}
+	if at.usesClientToken(c) {
+		return at.validateClientToken(c, db)
+	}
 	_, msg, status := at.CheckAccessTokens(c, db)
 	return msg, status
 }
@@ -206,16 +202,49 @@ func (at AuthorizationType) CheckAccessTokens(c *gin.Context, db postgresql.Data
 		return models.AccessToken{}, "either public or private key is missing", false
 	}
 
-	token := models.AccessToken{PublicKey: publicKey, PrivateKey: privateKey, IsLive: true}
-	_, err := token.LiveTokensWithPublicOrPrivateKey(db.Auth)
+	return at.acceptApiKey(c, db, IntrospectApiKey(db, privateKey, publicKey))
+}
+
+// TouchApiKey records the use of the api key at most once a minute per key, the throttle is shared with the
+// session touches of access tokens
+func TouchApiKey(db postgresql.Databases, accessToken *models.AccessToken, ipAddress string) error {
+	if !denylist.shouldTouch(fmt.Sprintf("api_key:%v", accessToken.ID)) {
+		return nil
+	}
+	return accessToken.TouchLastUsed(db.Auth, ipAddress)
+}
+
+// acceptApiKey sets up the request for a key whose credentials were checked
+func (at AuthorizationType) acceptApiKey(c *gin.Context, db postgresql.Databases, introspection Introspection) (models.AccessToken, string, bool) {
+	if !introspection.Active {
+		return introspection.AccessToken, introspection.Message, false
+	}
+
+	// scoped keys are checked against the scopes of the route by authorize
+	if introspection.AccessToken.IsScoped() {
+		c.Set(tokenScopesKey, introspection.AccessToken.ScopeList())
+	}
+	err := TouchApiKey(db, &introspection.AccessToken, c.ClientIP())
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return token, "invalid keys", false
-		} else {
-			return token, "server error", false
-		}
+		return introspection.AccessToken, "server error", false
+	}
+
+	setEnvironment(c, introspection.AccessToken)
+	setRateLimitPrincipal(c, fmt.Sprintf("api_key:%v", introspection.AccessToken.ID), introspection.AccountID, introspection.AccountType, introspection.AccessToken.Environment == models.ApiKeyEnvironmentLive)
+	models.MyIdentity = &models.UserIdentity{
+		AccountID: introspection.AccountID,
+		Type:      introspection.AccountType,
+	}
+	return introspection.AccessToken, introspection.Message, true
+}
+
+// BearerToken returns the token of the Authorization header the request was made with
+func BearerToken(c *gin.Context) string {
+	bearerTokenArr := strings.Split(GetHeader(c, "Authorization"), " ")
+	if len(bearerTokenArr) != 2 {
+		return ""
 	}
-	return token, "authorized", true
+	return bearerTokenArr[1]
 }
 
 func GetHeader(c *gin.Context, key string) string {
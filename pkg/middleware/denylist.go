@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// denylistCache sits in front of the revoked_tokens table. Denied jtis are cached until the token expires,
+// lookups that found nothing are trusted for the configured cache ttl so other instances pick up revocations
+// within that window.
+type denylistCache struct {
+	mu          sync.RWMutex
+	denied      map[string]time.Time
+	allowed     map[string]time.Time
+	lastTouched map[string]time.Time
+}
+
+var denylist = &denylistCache{
+	denied:      map[string]time.Time{},
+	allowed:     map[string]time.Time{},
+	lastTouched: map[string]time.Time{},
+}
+
+// DenyAccessToken revokes the access token with the jti, the entry is kept for the longest an access token lives
+func DenyAccessToken(db postgresql.Databases, accountID int, jti string) error {
+	if jti == "" {
+		return nil
+	}
+
+	revokedToken := models.RevokedToken{Jti: jti, AccountID: accountID, ExpiresAt: time.Now().Add(tokenLifetime())}
+	err := revokedToken.Deny(db.Auth)
+	if err != nil {
+		return err
+	}
+
+	denylist.deny(jti, revokedToken.ExpiresAt)
+	return nil
+}
+
+// DenySessions revokes the access tokens currently issued for the sessions
+func DenySessions(db postgresql.Databases, sessions ...models.Session) error {
+	for _, session := range sessions {
+		err := DenyAccessToken(db, session.AccountID, session.AccessUuid)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeSession ends the session along with the refresh tokens issued for it
+func RevokeSession(db postgresql.Databases, session models.Session) error {
+	err := DenySessions(db, session)
+	if err != nil {
+		return err
+	}
+
+	err = session.Revoke(db.Auth)
+	if err != nil {
+		return err
+	}
+
+	if session.FamilyID == "" {
+		return nil
+	}
+	refreshToken := models.RefreshToken{FamilyID: session.FamilyID}
+	return refreshToken.RevokeFamily(db.Auth)
+}
+
+// IsAccessTokenDenied reports whether the access token with the jti has been revoked
+func IsAccessTokenDenied(db postgresql.Databases, jti string) (bool, error) {
+	if denied, known := denylist.lookup(jti); known {
+		return denied, nil
+	}
+
+	revokedToken := models.RevokedToken{Jti: jti}
+	code, err := revokedToken.GetByJti(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return false, err
+		}
+		denylist.allow(jti, time.Now().Add(time.Duration(config.GetConfig().Denylist.CacheTtl)*time.Second))
+		return false, nil
+	}
+
+	denylist.deny(jti, revokedToken.ExpiresAt)
+	return true, nil
+}
+
+// TouchSession records activity on the session of an access token at most once a minute, off the request path.
+// It is called where the token is used to make a request, introspection stays read-only.
+func TouchSession(db postgresql.Databases, jti string) {
+	if !denylist.shouldTouch(jti) {
+		return
+	}
+	go func() {
+		session := models.Session{AccessUuid: jti}
+		session.TouchByAccessUuid(db.Auth)
+	}()
+}
+
+// StartDenylistCleanup drops expired denylist entries from the database and the cache
+func StartDenylistCleanup(logger *utility.Logger, db postgresql.Databases) {
+	interval := time.Duration(config.GetConfig().Denylist.CleanupInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		revokedToken := models.RevokedToken{}
+		err := revokedToken.DeleteExpired(db.Auth)
+		if err != nil {
+			logger.Error("denylist cleanup", err.Error())
+		}
+		denylist.purge()
+	}
+}
+
+func (d *denylistCache) lookup(jti string) (bool, bool) {
+	now := time.Now()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if expiresAt, ok := d.denied[jti]; ok && now.Before(expiresAt) {
+		return true, true
+	}
+	if until, ok := d.allowed[jti]; ok && now.Before(until) {
+		return false, true
+	}
+	return false, false
+}
+
+func (d *denylistCache) deny(jti string, expiresAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.denied[jti] = expiresAt
+	delete(d.allowed, jti)
+	delete(d.lastTouched, jti)
+}
+
+func (d *denylistCache) allow(jti string, until time.Time) {
+	if !time.Now().Before(until) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.allowed[jti] = until
+}
+
+func (d *denylistCache) shouldTouch(jti string) bool {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if lastTouched, ok := d.lastTouched[jti]; ok && now.Sub(lastTouched) < time.Minute {
+		return false
+	}
+	d.lastTouched[jti] = now
+	return true
+}
+
+func (d *denylistCache) purge() {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for jti, expiresAt := range d.denied {
+		if now.After(expiresAt) {
+			delete(d.denied, jti)
+		}
+	}
+	for jti, until := range d.allowed {
+		if now.After(until) {
+			delete(d.allowed, jti)
+		}
+	}
+	for jti, lastTouched := range d.lastTouched {
+		if now.Sub(lastTouched) > tokenLifetime() {
+			delete(d.lastTouched, jti)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+)
+
+const environmentKey = "environment"
+
+// Environment returns the environment of the api key the request was made with, requests authorized any other
+// way work on live data
+func Environment(c *gin.Context) string {
+	value, ok := c.Get(environmentKey)
+	if !ok {
+		return models.ApiKeyEnvironmentLive
+	}
+	environment, ok := value.(string)
+	if !ok || environment == "" {
+		return models.ApiKeyEnvironmentLive
+	}
+	return environment
+}
+
+// IsSandbox reports whether the request was made with a test key and has to be kept away from live data
+func IsSandbox(c *gin.Context) bool {
+	return Environment(c) == models.ApiKeyEnvironmentTest
+}
+
+func setEnvironment(c *gin.Context, accessToken models.AccessToken) {
+	c.Set(environmentKey, accessToken.Environment)
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+const (
+	impersonationKey = "impersonation"
+
+	ImpersonationReadOnly = "impersonation tokens are read-only"
+)
+
+// ImpersonationExpireDuration is how long impersonation tokens stay valid, they are not refreshable
+func ImpersonationExpireDuration() time.Duration {
+	expireDuration := time.Duration(config.GetConfig().Impersonation.ExpireDuration) * time.Minute
+	if expireDuration <= 0 {
+		expireDuration = 15 * time.Minute
+	}
+	return expireDuration
+}
+
+// Impersonation returns the introspection of the impersonation token the request was made with
+func Impersonation(c *gin.Context) (Introspection, bool) {
+	value, ok := c.Get(impersonationKey)
+	if !ok {
+		return Introspection{}, false
+	}
+	introspection, ok := value.(Introspection)
+	return introspection, ok
+}
+
+// RefuseImpersonation keeps impersonation tokens away from routes that expose secrets even on reads. Mutating
+// routes do not need it, Authorize already refuses them for impersonation tokens.
+func RefuseImpersonation(db postgresql.Databases) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		introspection, ok := Impersonation(c)
+		if !ok {
+			return
+		}
+
+		err := RecordImpersonation(db, introspection, models.AuditActionImpersonationRefused, requestDetail(c), c.ClientIP())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, utility.BuildErrorResponse(http.StatusInternalServerError, "error", err.Error(), err, nil))
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, utility.BuildErrorResponse(http.StatusForbidden, "error", ImpersonationReadOnly, fmt.Errorf("this action is not available while impersonating an account"), nil))
+	}
+}
+
+func setImpersonation(c *gin.Context, introspection Introspection) {
+	if introspection.Act != nil {
+		c.Set(impersonationKey, introspection)
+	}
+}
+
+// auditImpersonation writes every request made with an impersonation token to the audit log of the account and
+// refuses the ones that could change anything, validating the token is the only write allowed. Requests are not served when the audit log cannot be written.
+func auditImpersonation(c *gin.Context, db postgresql.Databases) {
+	introspection, ok := Impersonation(c)
+	if !ok {
+		return
+	}
+
+	action := models.AuditActionImpersonationRequest
+	if !IsReadOnlyMethod(c.Request.Method) && !strings.HasSuffix(c.FullPath(), "/validate-token") {
+		action = models.AuditActionImpersonationRefused
+	}
+
+	err := RecordImpersonation(db, introspection, action, requestDetail(c), c.ClientIP())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, utility.BuildErrorResponse(http.StatusInternalServerError, "error", err.Error(), err, nil))
+		return
+	}
+
+	if action == models.AuditActionImpersonationRefused {
+		c.AbortWithStatusJSON(http.StatusForbidden, utility.BuildErrorResponse(http.StatusForbidden, "error", ImpersonationReadOnly, fmt.Errorf("this action is not available while impersonating an account"), nil))
+	}
+}
+
+// RecordImpersonation adds a request made with an impersonation token to the audit log of the account
+func RecordImpersonation(db postgresql.Databases, introspection Introspection, action, detail, ipAddress string) error {
+	auditLog := models.AuditLog{
+		AccountID:      introspection.AccountID,
+		ActorAccountID: introspection.ActorAccountID(),
+		Action:         action,
+		Detail:         detail,
+		Jti:            introspection.Jti,
+		IpAddress:      ipAddress,
+	}
+	return auditLog.CreateAuditLog(db.Auth)
+}
+
+func requestDetail(c *gin.Context) string {
+	return fmt.Sprintf("%v %v", c.Request.Method, c.Request.URL.Path)
+}
+
+// IsReadOnlyMethod reports whether requests with method are safe for impersonation tokens
+func IsReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// token_type_hint values accepted by the introspection and revocation endpoints, api_key is our own extension
+const (
+	TokenTypeAccessToken  = "access_token"
+	TokenTypeRefreshToken = "refresh_token"
+	TokenTypeApiKey       = "api_key"
+)
+
+// Introspection is the RFC 7662 introspection response. Inactive tokens only report active false, Message says
+// why so callers that predate introspection can keep their error messages.
+type Introspection struct {
+	Active          bool   `json:"active"`
+	Scope           string `json:"scope,omitempty"`
+	ClientID        string `json:"client_id,omitempty"`
+	TokenType       string `json:"token_type,omitempty"`
+	Exp             int64  `json:"exp,omitempty"`
+	Sub             string `json:"sub,omitempty"`
+	Jti             string `json:"jti,omitempty"`
+	TokenUse        string `json:"token_use,omitempty"`
+	AccountID       int    `json:"account_id,omitempty"`
+	AccountType     string `json:"account_type,omitempty"`
+	UniversalAccess bool   `json:"universal_access,omitempty"`
+	Grant           string `json:"grant,omitempty"`
+	Act             *Actor `json:"act,omitempty"`
+	Environment     string `json:"environment,omitempty"`
+
+	Message     string             `json:"-"`
+	User        models.User        `json:"-"`
+	AccessToken models.AccessToken `json:"-"`
+}
+
+// Actor is the RFC 8693 act claim of impersonation tokens, Sub is the account id of the admin
+type Actor struct {
+	Sub string `json:"sub"`
+}
+
+// ActorAccountID returns the account id of the admin impersonating the account, 0 for ordinary tokens
+func (i Introspection) ActorAccountID() int {
+	if i.Act == nil {
+		return 0
+	}
+	actorAccountID, _ := strconv.Atoi(i.Act.Sub)
+	return actorAccountID
+}
+
+func inactive(msg string) Introspection {
+	return Introspection{Message: msg}
+}
+
+// IntrospectToken looks the token up as the hinted type first and falls back to the other types, as RFC 7662 asks
+func IntrospectToken(db postgresql.Databases, token, tokenTypeHint string) Introspection {
+	var (
+		invalidToken = "Your request was made with invalid credentials."
+		lookups      = map[string]func() Introspection{
+			TokenTypeAccessToken:  func() Introspection { return IntrospectAccessToken(db, token) },
+			TokenTypeRefreshToken: func() Introspection { return IntrospectRefreshToken(db, token) },
+			TokenTypeApiKey:       func() Introspection { return IntrospectApiKey(db, token, "") },
+		}
+		order = []string{TokenTypeAccessToken, TokenTypeRefreshToken, TokenTypeApiKey}
+	)
+
+	if token == "" {
+		return inactive(invalidToken)
+	}
+
+	if lookup, ok := lookups[tokenTypeHint]; ok {
+		if introspection := lookup(); introspection.Active {
+			return introspection
+		}
+	}
+	for _, tokenType := range order {
+		if tokenType == tokenTypeHint {
+			continue
+		}
+		if introspection := lookups[tokenType](); introspection.Active {
+			return introspection
+		}
+	}
+	return inactive(invalidToken)
+}
+
+// IntrospectAccessToken checks a login or oauth access token. Only the signature, exp and the denylist are
+// consulted, so revoking a token means adding its jti to the denylist.
+func IntrospectAccessToken(db postgresql.Databases, bearerToken string) Introspection {
+	var invalidToken = "Your request was made with invalid credentials."
+	if bearerToken == "" {
+		return inactive(invalidToken)
+	}
+
+	token, err := TokenValid(bearerToken)
+	if err != nil {
+		return inactive(invalidToken)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	accountType, ok := claims["type"].(string)
+	if !ok {
+		return inactive(invalidToken)
+	}
+
+	accountID, ok := claims["account_id"].(float64)
+	if !ok {
+		return inactive(invalidToken)
+	}
+
+	authoriseStatus, ok := claims["authorised"].(bool)
+	if !ok || !authoriseStatus {
+		return inactive(invalidToken)
+	}
+
+	jti := tokenJti(claims)
+	if jti == "" {
+		return inactive(invalidToken)
+	}
+
+	denied, err := IsAccessTokenDenied(db, jti)
+	if err != nil {
+		return inactive(err.Error())
+	}
+	if denied {
+		return inactive(invalidToken)
+	}
+
+	introspection := Introspection{
+		Active:      true,
+		TokenType:   "Bearer",
+		Sub:         strconv.Itoa(int(accountID)),
+		Jti:         jti,
+		TokenUse:    TokenTypeAccessToken,
+		AccountID:   int(accountID),
+		AccountType: accountType,
+		Message:     "authorized",
+	}
+	introspection.Scope, _ = claims["scope"].(string)
+	introspection.ClientID, _ = claims["client_id"].(string)
+	introspection.Grant, _ = claims["grant"].(string)
+	introspection.UniversalAccess, _ = claims["universal_access"].(bool)
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		sub, _ := act["sub"].(string)
+		if sub == "" {
+			return inactive(invalidToken)
+		}
+		introspection.Act = &Actor{Sub: sub}
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		introspection.Exp = int64(exp)
+	}
+	return introspection
+}
+
+// tokenJti reads the jti claim, tokens issued before it was added only carry access_uuid
+func tokenJti(claims jwt.MapClaims) string {
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		return jti
+	}
+	accessUuid, _ := claims["access_uuid"].(string)
+	return accessUuid
+}
+
+// IntrospectRefreshToken checks a refresh token, used and revoked tokens are inactive
+func IntrospectRefreshToken(db postgresql.Databases, plainToken string) Introspection {
+	var invalidToken = "Your request was made with invalid credentials."
+	if plainToken == "" {
+		return inactive(invalidToken)
+	}
+
+	refreshToken := models.RefreshToken{TokenHash: utility.HashToken(plainToken)}
+	code, err := refreshToken.GetByTokenHash(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return inactive(err.Error())
+		}
+		return inactive(invalidToken)
+	}
+
+	if refreshToken.Used || refreshToken.Revoked || time.Now().After(refreshToken.ExpiresAt) {
+		return inactive(invalidToken)
+	}
+
+	user := models.User{AccountID: uint(refreshToken.AccountID)}
+	code, err = user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return inactive(err.Error())
+		}
+		return inactive("user does not exist")
+	}
+
+	return Introspection{
+		Active:      true,
+		Exp:         refreshToken.ExpiresAt.Unix(),
+		Sub:         strconv.Itoa(refreshToken.AccountID),
+		TokenUse:    TokenTypeRefreshToken,
+		AccountID:   refreshToken.AccountID,
+		AccountType: user.AccountType,
+		Message:     "authorized",
+		User:        user,
+	}
+}
+
+// IntrospectApiKey checks an api key pair that is not revoked, the public key can be left out when introspecting a private key.
+// The private key is only ever compared with the stored hash.
+func IntrospectApiKey(db postgresql.Databases, privateKey, publicKey string) Introspection {
+	if privateKey == "" && publicKey == "" {
+		return inactive("missing api keys")
+	}
+
+	var (
+		accessToken = models.AccessToken{PublicKey: publicKey, PrivateKey: privateKey}
+		code        int
+		err         error
+	)
+	if publicKey != "" {
+		code, err = accessToken.GetActiveByPublicKey(db.Auth)
+	} else {
+		code, err = accessToken.ActiveTokensWithPublicOrPrivateKey(db.Auth)
+	}
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return inactive("server error")
+		}
+		return inactive("invalid keys")
+	}
+	if !accessToken.VerifyPrivateKey(privateKey) {
+		return inactive("invalid keys")
+	}
+	accessToken.PrivateKey = ""
+	return introspectActiveApiKey(db, accessToken)
+}
+
+// introspectActiveApiKey finishes the introspection of a key whose credentials were checked
+func introspectActiveApiKey(db postgresql.Databases, accessToken models.AccessToken) Introspection {
+	if accessToken.IsExpired() {
+		return inactive("api key has expired")
+	}
+
+	user := models.User{AccountID: uint(accessToken.AccountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return inactive("server error")
+		}
+		return inactive("invalid keys")
+	}
+
+	exp := int64(0)
+	if accessToken.ExpiresAt != nil {
+		exp = accessToken.ExpiresAt.Unix()
+	}
+
+	return Introspection{
+		Active:      true,
+		Scope:       accessToken.Scope,
+		Environment: accessToken.Environment,
+		Exp:         exp,
+		Sub:         strconv.Itoa(accessToken.AccountID),
+		TokenUse:    TokenTypeApiKey,
+		AccountID:   accessToken.AccountID,
+		AccountType: user.AccountType,
+		Message:     "authorized",
+		User:        user,
+		AccessToken: accessToken,
+	}
+}
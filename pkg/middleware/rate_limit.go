@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/pkg/ratelimit"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+
+	rateLimitIpKey        = "rate_limit_ip"
+	rateLimitPrincipalKey = "rate_limit_principal"
+	rateLimitExemptKey    = "rate_limit_exempt"
+)
+
+// rateLimitPrincipal is who an authorized request is counted against, quota is set for machine traffic that also
+// counts against the monthly quota of the account
+type rateLimitPrincipal struct {
+	key         string
+	accountID   int
+	accountType string
+	quota       bool
+}
+
+// rateLimitHit is the request RateLimit counted against the client ip, it is given back once the request turns
+// out to be made by a principal and enforced otherwise
+type rateLimitHit struct {
+	key    string
+	policy ratelimit.Policy
+	at     time.Time
+	result ratelimit.Result
+}
+
+var (
+	rateLimiterOnce sync.Once
+	rateLimiter     *ratelimit.Limiter
+)
+
+// RateLimiter returns the limiter of the service, its counters are kept in postgres when RATELIMIT_STORE is
+// postgres and in memory otherwise
+func RateLimiter(db postgresql.Databases) *ratelimit.Limiter {
+	rateLimiterOnce.Do(func() {
+		var store ratelimit.Store = ratelimit.NewMemoryStore()
+		if config.GetConfig().RateLimit.Store == RateLimitStorePostgres {
+			store = ratelimit.NewPostgresStore(db.Auth)
+		}
+		rateLimiter = ratelimit.NewLimiter(store)
+	})
+	return rateLimiter
+}
+
+// RateLimit counts every request against the client ip with the anonymous limits and leaves holding it to them
+// to the route. Route groups without Authorize are held to them by LimitAnonymous. Authorize only holds the
+// requests it finds no principal for to them and moves the others over to the api key or account they were made
+// by, so anonymous traffic sharing the ip cannot lock principals out. Requests from ips exempt from throttling
+// are not counted at all.
+func RateLimit(db postgresql.Databases) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isExemptIP(c.ClientIP(), config.GetConfig().Server.ExemptFromThrottle) {
+			c.Set(rateLimitExemptKey, true)
+			c.Next()
+			return
+		}
+
+		var (
+			now    = time.Now()
+			key    = fmt.Sprintf("ip:%v", c.ClientIP())
+			policy = anonymousRateLimitPolicy()
+		)
+		result, err := RateLimiter(db).Take(key, policy, now)
+		if err != nil {
+			// an unavailable store should not take the service down with it
+			c.Next()
+			return
+		}
+		c.Set(rateLimitIpKey, rateLimitHit{key: key, policy: policy, at: now, result: result})
+		c.Next()
+	}
+}
+
+// LimitAnonymous holds the routes of a group without Authorize to the anonymous limits of the client ip
+func LimitAnonymous() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limitIP(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// StartRateLimitCleanup drops the counters of windows that have ended
+func StartRateLimitCleanup(logger *utility.Logger, db postgresql.Databases) {
+	interval := time.Duration(config.GetConfig().RateLimit.CleanupInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := RateLimiter(db).Store.DeleteExpired()
+		if err != nil {
+			logger.Error("rate limit cleanup", err.Error())
+		}
+	}
+}
+
+func isExemptIP(ip string, exemptIPs []string) bool {
+	for _, exemptIP := range exemptIPs {
+		if ip == exemptIP {
+			return true
+		}
+	}
+	return false
+}
+
+func setRateLimitPrincipal(c *gin.Context, key string, accountID int, accountType string, quota bool) {
+	c.Set(rateLimitPrincipalKey, rateLimitPrincipal{key: key, accountID: accountID, accountType: accountType, quota: quota})
+}
+
+// limitPrincipal counts an authorized request against the plan of its principal instead of the client ip and
+// answers with too many requests once the plan limit or monthly quota is used up. Requests authorized without a
+// principal stay on the client ip.
+func limitPrincipal(c *gin.Context, db postgresql.Databases) bool {
+	if c.GetBool(rateLimitExemptKey) {
+		return true
+	}
+	value, _ := c.Get(rateLimitPrincipalKey)
+	principal, ok := value.(rateLimitPrincipal)
+	if !ok {
+		return limitIP(c)
+	}
+
+	var (
+		limiter = RateLimiter(db)
+		now     = time.Now()
+		plan    = config.GetConfig().RateLimit.ForAccountType(principal.accountType)
+	)
+	if value, ok := c.Get(rateLimitIpKey); ok {
+		if hit, ok := value.(rateLimitHit); ok && hit.key != "" {
+			limiter.Refund(hit.key, hit.policy, hit.at)
+			c.Set(rateLimitIpKey, rateLimitHit{})
+		}
+	}
+
+	policy := rateLimitPolicy(plan)
+	if policy.IsUnlimited() {
+		return true
+	}
+	result, err := limiter.Take(principal.key, policy, now)
+	if err != nil {
+		return true
+	}
+
+	if result.Allowed && principal.quota && plan.MonthlyQuota > 0 {
+		quota, err := limiter.Take(fmt.Sprintf("quota:account:%v", principal.accountID), ratelimit.Monthly(plan.MonthlyQuota), now)
+		if err == nil && (!quota.Allowed || quota.Remaining < result.Remaining) {
+			result = quota
+		}
+	}
+	return allowRateLimited(c, result, now)
+}
+
+// limitIP holds a request Authorize found no principal for to the anonymous limits of the client ip
+func limitIP(c *gin.Context) bool {
+	value, _ := c.Get(rateLimitIpKey)
+	hit, ok := value.(rateLimitHit)
+	if !ok || hit.key == "" {
+		return true
+	}
+	return allowRateLimited(c, hit.result, hit.at)
+}
+
+// allowRateLimited sets the RateLimit headers of the result and aborts the request when it is over the limit
+func allowRateLimited(c *gin.Context, result ratelimit.Result, now time.Time) bool {
+	resetAfter := strconv.FormatInt(result.ResetAfter(now), 10)
+	c.Header("RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+	c.Header("RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+	c.Header("RateLimit-Reset", resetAfter)
+	if result.Allowed {
+		return true
+	}
+
+	c.Header("Retry-After", resetAfter)
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, utility.BuildErrorResponse(http.StatusTooManyRequests, "error", "too many requests", fmt.Errorf("rate limit exceeded, retry in %v seconds", resetAfter), nil))
+	return false
+}
+
+func rateLimitPolicy(plan config.RateLimitPlan) ratelimit.Policy {
+	window := time.Duration(plan.Window) * time.Second
+	if window <= 0 {
+		window = time.Minute
+	}
+	return ratelimit.Policy{Limit: plan.Limit, Window: window}
+}
+
+// anonymousRateLimitPolicy falls back to REQUEST_PER_SECOND for deployments configured before the rate limit plans
+func anonymousRateLimitPolicy() ratelimit.Policy {
+	plan := config.GetConfig().RateLimit.Anonymous
+	if plan.Limit > 0 {
+		return rateLimitPolicy(plan)
+	}
+
+	requestPerSecond := config.GetConfig().Server.RequestPerSecond
+	if requestPerSecond <= 0 {
+		requestPerSecond = 7
+	}
+	return ratelimit.Policy{Limit: int64(math.Ceil(requestPerSecond)), Window: time.Second}
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+const (
+	ScopeProfile           = "profile"
+	ScopeWalletRead        = "wallet:read"
+	ScopeDisbursementsRead = "disbursements:read"
+	ScopeOtpSend           = "otp:send"
+	ScopeUsersRead         = "users:read"
+
+	tokenScopesKey = "token_scopes"
+)
+
+// OauthScopes lists the scopes oauth clients can request with the description shown on the consent screen
+var OauthScopes = map[string]string{
+	ScopeProfile:           "View your profile and account restrictions",
+	ScopeWalletRead:        "View your wallet balances",
+	ScopeDisbursementsRead: "View your disbursements",
+	ScopeOtpSend:           "Send one-time passwords to your customers",
+}
+
+// ApiKeyScopes lists the scopes api keys can be limited to
+var ApiKeyScopes = map[string]string{
+	ScopeOtpSend:           "Send one-time passwords to your customers",
+	ScopeUsersRead:         "View users",
+	ScopeWalletRead:        "View wallet balances",
+	ScopeDisbursementsRead: "View disbursements",
+}
+
+// Scoped authorizes like Authorize and also admits oauth access tokens and scoped api keys that were granted
+// every listed scope. Routes registered with Authorize alone reject both.
+func Scoped(db postgresql.Databases, scopes []string, authTypes ...AuthorizationType) gin.HandlerFunc {
+	return authorize(db, scopes, authTypes)
+}
+
+// ParseScopes validates a space separated scope parameter against OauthScopes
+func ParseScopes(scope string) ([]string, error) {
+	return parseScopes(strings.Fields(scope), OauthScopes)
+}
+
+// ParseApiKeyScopes validates the scopes an api key is limited to against ApiKeyScopes
+func ParseApiKeyScopes(scopes []string) ([]string, error) {
+	return parseScopes(scopes, ApiKeyScopes)
+}
+
+func parseScopes(requested []string, known map[string]string) ([]string, error) {
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, s := range requested {
+		if _, ok := known[s]; !ok {
+			return nil, fmt.Errorf("unknown scope: %v", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+// HasScopes reports whether granted contains every required scope, an empty requirement is never satisfied
+func HasScopes(granted, required []string) bool {
+	if len(required) == 0 {
+		return false
+	}
+
+	grantedSet := map[string]bool{}
+	for _, s := range granted {
+		grantedSet[s] = true
+	}
+	for _, s := range required {
+		if !grantedSet[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// TokenScopes returns the scopes of the oauth access token or api key that authorized the request, ok is false
+// for first party tokens and api keys which are not limited by scope
+func TokenScopes(c *gin.Context) ([]string, bool) {
+	value, ok := c.Get(tokenScopesKey)
+	if !ok {
+		return nil, false
+	}
+	scopes, ok := value.([]string)
+	return scopes, ok
+}
+
+// setTokenScopes records the scopes of oauth access tokens, every oauth token names the client it was issued to
+func setTokenScopes(c *gin.Context, introspection Introspection) {
+	if introspection.ClientID != "" {
+		c.Set(tokenScopesKey, strings.Fields(introspection.Scope))
+	}
+}
+
+// ValidateOauthClientToken checks an access token issued with the client credentials grant and returns the
+// account of the business owning the client along with the granted scopes
+func ValidateOauthClientToken(db postgresql.Databases, bearerToken string) (int, []string, string, bool) {
+	var invalidToken = "Your request was made with invalid credentials."
+
+	introspection := IntrospectAccessToken(db, bearerToken)
+	if !introspection.Active {
+		return 0, nil, introspection.Message, false
+	}
+	if introspection.Grant != OauthGrantClientCredentials || introspection.ClientID == "" {
+		return 0, nil, invalidToken, false
+	}
+
+	return introspection.AccountID, strings.Fields(introspection.Scope), "authorized", true
+}
+
+// usesClientToken reports whether the request authenticates with an oauth client credentials token instead of api keys
+func (at AuthorizationType) usesClientToken(c *gin.Context) bool {
+	return GetHeader(c, "v-private-key") == "" && GetHeader(c, "v-public-key") == "" && GetHeader(c, "Authorization") != ""
+}
+
+func (at AuthorizationType) validateClientToken(c *gin.Context, db postgresql.Databases) (string, bool) {
+	bearerTokenArr := strings.Split(GetHeader(c, "Authorization"), " ")
+	if len(bearerTokenArr) != 2 || bearerTokenArr[1] == "" {
+		return "missing api keys", false
+	}
+
+	accountID, scopes, msg, ok := ValidateOauthClientToken(db, bearerTokenArr[1])
+	if !ok {
+		return msg, false
+	}
+
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return err.Error(), false
+		}
+		return "user does not exist", false
+	}
+
+	banned, err := (&models.BannedAccount{AccountID: accountID}).CheckByAccountID(db.Auth)
+	if err != nil {
+		return "server error", false
+	}
+	if banned {
+		return "this account has been banned", false
+	}
+
+	c.Set(tokenScopesKey, scopes)
+	setRateLimitPrincipal(c, fmt.Sprintf("account:%v", user.AccountID), int(user.AccountID), user.AccountType, true)
+	models.MyIdentity = &models.UserIdentity{
+		AccountID: int(user.AccountID),
+		Type:      user.AccountType,
+	}
+	return "authorized", true
+}
+
+func insufficientScopeResponse() utility.Response {
+	return utility.BuildErrorResponse(http.StatusForbidden, "error", "insufficient scope", fmt.Errorf("the access token was not granted the scope this route requires"), nil)
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/pkg/signing"
+	"gorm.io/gorm"
+)
+
+const (
+	NonceStoreMemory   = "memory"
+	NonceStorePostgres = "postgres"
+
+	// maxSignedBodySize caps the body read into memory to check the signature of a request
+	maxSignedBodySize = 10 << 20
+)
+
+// nonceStore remembers the nonces of signed requests until their timestamp leaves the skew window, after that
+// the timestamp check refuses a replay on its own
+type nonceStore interface {
+	// use records the nonce and reports whether it had not been seen yet
+	use(nonce string, until time.Time) (bool, error)
+}
+
+// nonceCache keeps the nonces in memory, each instance has its own
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPurge time.Time
+}
+
+// postgresNonces keeps the nonces in the signature_nonces table shared by every instance
+type postgresNonces struct {
+	db        *gorm.DB
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+var (
+	noncesOnce sync.Once
+	nonces     nonceStore
+)
+
+// signatureNonces returns the nonce store of the service, nonces are shared in postgres when SIGNING_NONCESTORE
+// is postgres and kept in memory otherwise
+func signatureNonces(db postgresql.Databases) nonceStore {
+	noncesOnce.Do(func() {
+		nonces = &nonceCache{seen: map[string]time.Time{}}
+		if config.GetConfig().Signing.NonceStore == NonceStorePostgres {
+			nonces = &postgresNonces{db: db.Auth}
+		}
+	})
+	return nonces
+}
+
+// SignatureMaxSkew is how far the timestamp of a signed request can be from the server clock
+func SignatureMaxSkew() time.Duration {
+	maxSkew := time.Duration(config.GetConfig().ApiKey.SignatureMaxSkew) * time.Second
+	if maxSkew <= 0 {
+		maxSkew = 5 * time.Minute
+	}
+	return maxSkew
+}
+
+// usesSignature reports whether the request is signed instead of carrying the private key
+func (at AuthorizationType) usesSignature(c *gin.Context) bool {
+	return GetHeader(c, signing.HeaderSignature) != ""
+}
+
+// checkSignedRequest is the alternative to CheckAccessTokens for requests signed with the signing package
+func (at AuthorizationType) checkSignedRequest(c *gin.Context, db postgresql.Databases) (models.AccessToken, string, bool) {
+	var (
+		keyID     = GetHeader(c, signing.HeaderKeyID)
+		timestamp = GetHeader(c, signing.HeaderTimestamp)
+		nonce     = GetHeader(c, signing.HeaderNonce)
+		signature = GetHeader(c, signing.HeaderSignature)
+		maxSkew   = SignatureMaxSkew()
+	)
+
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return models.AccessToken{}, "missing signature headers", false
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return models.AccessToken{}, "invalid request timestamp", false
+	}
+	signedAt := time.Unix(unix, 0)
+	if skew := time.Since(signedAt); skew > maxSkew || skew < -maxSkew {
+		return models.AccessToken{}, "request timestamp is outside the allowed window", false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignedBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return models.AccessToken{}, "request body is too large", false
+		}
+		return models.AccessToken{}, "invalid request body", false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	accessToken := models.AccessToken{PublicKey: keyID}
+	code, err := accessToken.GetActiveByPublicKey(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return models.AccessToken{}, "server error", false
+		}
+		return models.AccessToken{}, "invalid keys", false
+	}
+
+	signingKey, err := accessToken.DecryptSigningKey()
+	if err != nil {
+		return models.AccessToken{}, err.Error(), false
+	}
+	if !signing.Verify(signingKey, signing.StringToSign(c.Request.Method, c.Request.URL.RequestURI(), body, timestamp, nonce), signature) {
+		return models.AccessToken{}, "invalid signature", false
+	}
+
+	// only nonces of valid signatures are recorded, so nobody else can use up a client's nonces
+	fresh, err := signatureNonces(db).use(keyID+":"+nonce, signedAt.Add(maxSkew))
+	if err != nil {
+		return models.AccessToken{}, "server error", false
+	}
+	if !fresh {
+		return models.AccessToken{}, "this request was already received", false
+	}
+
+	return at.acceptApiKey(c, db, introspectActiveApiKey(db, accessToken))
+}
+
+func (n *nonceCache) use(nonce string, until time.Time) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(n.lastPurge) > time.Minute {
+		for k, expiresAt := range n.seen {
+			if expiresAt.Before(now) {
+				delete(n.seen, k)
+			}
+		}
+		n.lastPurge = now
+	}
+
+	if expiresAt, ok := n.seen[nonce]; ok && expiresAt.After(now) {
+		return false, nil
+	}
+	n.seen[nonce] = until
+	return true, nil
+}
+
+func (p *postgresNonces) use(nonce string, until time.Time) (bool, error) {
+	p.mu.Lock()
+	purge := time.Since(p.lastPurge) > time.Minute
+	if purge {
+		p.lastPurge = time.Now()
+	}
+	p.mu.Unlock()
+
+	if purge {
+		// expired rows are only dropped to keep the table small, a failure can wait for the next purge
+		(&models.SignatureNonce{}).DeleteExpired(p.db)
+	}
+
+	signatureNonce := models.SignatureNonce{Nonce: nonce, ExpiresAt: until}
+	return signatureNonce.Use(p.db)
+}
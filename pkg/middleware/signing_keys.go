@@ -0,0 +1,396 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+const (
+	SigningAlgorithmRS256 = "RS256"
+	SigningAlgorithmEdDSA = "EdDSA"
+
+	signingKeyCheckInterval  = 5 * time.Minute
+	signingKeyReloadCooldown = 30 * time.Second
+	defaultKeyRotationHours  = 720
+)
+
+// SigningKey is an asymmetric key used to sign access tokens, its kid starts with the unix time it was created at
+type SigningKey struct {
+	Kid        string
+	CreatedAt  time.Time
+	PrivateKey crypto.Signer
+}
+
+type signingKeyRing struct {
+	mu         sync.RWMutex
+	keys       []SigningKey
+	reloadedAt time.Time
+}
+
+var signingKeys = &signingKeyRing{}
+
+// startedAt opens the default HS256 migration window, tokens issued before a deploy stay valid for as long as
+// they could have lived anyway
+var startedAt = time.Now()
+
+// LoadSigningKeys reads the configured key directory, creating a key when it is empty or due for rotation.
+// Without a key directory tokens keep being signed with the HS256 server secret.
+func LoadSigningKeys(logger *utility.Logger) error {
+	dir := config.GetConfig().Server.JwtKeyDirectory
+	if dir == "" {
+		logger.Info("jwt key directory not configured, signing tokens with HS256")
+		return nil
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	return RotateSigningKeys(logger)
+}
+
+// RotateSigningKeys creates a new signing key once the current one is older than the rotation duration and
+// drops keys that can no longer have signed an unexpired token
+func RotateSigningKeys(logger *utility.Logger) error {
+	dir := config.GetConfig().Server.JwtKeyDirectory
+	if dir == "" {
+		return nil
+	}
+
+	keys, err := readSigningKeys(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 || time.Since(keys[0].CreatedAt) >= keyRotationDuration() {
+		key, err := generateSigningKey(dir)
+		if err != nil {
+			return err
+		}
+		logger.Info("rotated jwt signing key", key.Kid)
+		keys = append([]SigningKey{key}, keys...)
+	}
+
+	retained := keys[:1]
+	for i := 1; i < len(keys); i++ {
+		// a key stops signing when the next one is created, its tokens are valid for at most one token lifetime after that
+		if time.Since(keys[i-1].CreatedAt) < tokenLifetime() {
+			retained = append(retained, keys[i])
+			continue
+		}
+		err := os.Remove(filepath.Join(dir, keys[i].Kid+".pem"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		logger.Info("retired jwt signing key", keys[i].Kid)
+	}
+
+	signingKeys.set(retained)
+	return nil
+}
+
+// StartSigningKeyRotation periodically rotates the signing keys, it is meant to run in its own goroutine
+func StartSigningKeyRotation(logger *utility.Logger) {
+	if config.GetConfig().Server.JwtKeyDirectory == "" {
+		return
+	}
+
+	ticker := time.NewTicker(signingKeyCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := RotateSigningKeys(logger)
+		if err != nil {
+			logger.Error("jwt signing key rotation failed", err.Error())
+		}
+	}
+}
+
+// JWKS returns the public keys that verify currently valid tokens in RFC 7517 format
+func JWKS() map[string]interface{} {
+	keys := []map[string]interface{}{}
+	for _, key := range signingKeys.all() {
+		keys = append(keys, key.jwk())
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+func (k SigningKey) Algorithm() string {
+	if _, ok := k.PrivateKey.(ed25519.PrivateKey); ok {
+		return SigningAlgorithmEdDSA
+	}
+	return SigningAlgorithmRS256
+}
+
+func (k SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm() == SigningAlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func (k SigningKey) jwk() map[string]interface{} {
+	jwk := map[string]interface{}{
+		"kid": k.Kid,
+		"alg": k.Algorithm(),
+		"use": "sig",
+	}
+
+	switch publicKey := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(publicKey)
+	}
+	return jwk
+}
+
+// signClaims signs claims with the newest asymmetric key, falling back to the HS256 secret when none is loaded
+func signClaims(claims jwt.MapClaims) (string, error) {
+	key, ok := signingKeys.current()
+	if !ok {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.GetConfig().Server.Secret))
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.PrivateKey)
+}
+
+// verificationKey resolves the key a token should be verified with based on its alg and kid headers
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if !hs256Accepted() {
+			return nil, fmt.Errorf("HS256 tokens are no longer accepted")
+		}
+		return []byte(config.GetConfig().Server.Secret), nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+		kid, _ := token.Header["kid"].(string)
+		key, ok := signingKeys.byKid(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %v", kid)
+		}
+		if key.method().Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.PrivateKey.Public(), nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+}
+
+// hs256Accepted reports whether tokens signed with the shared secret are still valid. They always are while no
+// asymmetric key is loaded, otherwise only until the configured migration deadline, or for the lifetime of an
+// access token after the process started when no deadline is set.
+func hs256Accepted() bool {
+	if _, ok := signingKeys.current(); !ok {
+		return true
+	}
+
+	acceptedUntil := config.GetConfig().Server.HS256AcceptedUntil
+	if acceptedUntil == "" {
+		return time.Now().Before(startedAt.Add(tokenLifetime()))
+	}
+
+	deadline, err := time.Parse(time.RFC3339, acceptedUntil)
+	if err != nil {
+		return false
+	}
+	return time.Now().Before(deadline)
+}
+
+func keyRotationDuration() time.Duration {
+	hours := config.GetConfig().Server.JwtKeyRotationDuration
+	if hours == 0 {
+		hours = defaultKeyRotationHours
+	}
+	return time.Hour * time.Duration(hours)
+}
+
+func tokenLifetime() time.Duration {
+	lifetime := time.Hour * time.Duration(config.GetConfig().Server.AccessTokenExpireDuration)
+	if lifetime < MfaTokenExpireDuration {
+		lifetime = MfaTokenExpireDuration
+	}
+	return lifetime
+}
+
+func (r *signingKeyRing) set(keys []SigningKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = keys
+	r.reloadedAt = time.Now()
+}
+
+func (r *signingKeyRing) all() []SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]SigningKey{}, r.keys...)
+}
+
+func (r *signingKeyRing) current() (SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.keys) == 0 {
+		return SigningKey{}, false
+	}
+	return r.keys[0], true
+}
+
+// byKid looks a key up, rereading the key directory when the kid is unknown since another instance sharing
+// the directory may have rotated
+func (r *signingKeyRing) byKid(kid string) (SigningKey, bool) {
+	if key, ok := r.find(kid); ok {
+		return key, true
+	}
+
+	dir := config.GetConfig().Server.JwtKeyDirectory
+	r.mu.RLock()
+	reloadedAt := r.reloadedAt
+	r.mu.RUnlock()
+	if dir == "" || time.Since(reloadedAt) < signingKeyReloadCooldown {
+		return SigningKey{}, false
+	}
+
+	keys, err := readSigningKeys(dir)
+	if err != nil || len(keys) == 0 {
+		return SigningKey{}, false
+	}
+	r.set(keys)
+	return r.find(kid)
+}
+
+func (r *signingKeyRing) find(kid string) (SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// readSigningKeys loads every <kid>.pem file in dir, newest first
+func readSigningKeys(dir string) ([]SigningKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []SigningKey{}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		createdAt, err := kidCreatedAt(kid)
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("invalid pem in %v", file)
+		}
+
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key in %v: %v", file, err.Error())
+		}
+
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key in %v", file)
+		}
+
+		switch signer.(type) {
+		case *rsa.PrivateKey, ed25519.PrivateKey:
+		default:
+			return nil, fmt.Errorf("unsupported private key type in %v", file)
+		}
+
+		keys = append(keys, SigningKey{Kid: kid, CreatedAt: createdAt, PrivateKey: signer})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func generateSigningKey(dir string) (SigningKey, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+
+	switch config.GetConfig().Server.JwtAlgorithm {
+	case SigningAlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	case SigningAlgorithmRS256, "":
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported jwt algorithm: %v", config.GetConfig().Server.JwtAlgorithm)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	createdAt := time.Now()
+	kid := fmt.Sprintf("%d-%s", createdAt.Unix(), hex.EncodeToString(suffix))
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	path := filepath.Join(dir, kid+".pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return SigningKey{Kid: kid, CreatedAt: time.Unix(createdAt.Unix(), 0), PrivateKey: signer}, nil
+}
+
+func kidCreatedAt(kid string) (time.Time, error) {
+	parts := strings.SplitN(kid, "-", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0), nil
+}
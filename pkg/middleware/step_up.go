@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+const (
+	StepUpRequired = "step_up_required"
+
+	StepUpMethodPassword = "password"
+	StepUpMethodOtp      = "otp"
+	StepUpMethodTotp     = "totp"
+)
+
+// StepUpMaxAge is how recent a re-authentication has to be for routes that do not ask for a stricter window
+func StepUpMaxAge() time.Duration {
+	maxAge := time.Duration(config.GetConfig().StepUp.MaxAge) * time.Minute
+	if maxAge <= 0 {
+		maxAge = 10 * time.Minute
+	}
+	return maxAge
+}
+
+// RequireStepUp admits requests whose session re-authenticated at /auth/step-up within maxAge. It has to run
+// after Authorize with AuthType, other requests are answered with a step_up_required error.
+func RequireStepUp(db postgresql.Databases, maxAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if models.MyIdentity == nil || models.MyIdentity.AccessUuid == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, stepUpRequiredResponse(c, maxAge))
+			return
+		}
+
+		session := models.Session{AccessUuid: models.MyIdentity.AccessUuid}
+		code, err := session.GetByAccessUuid(db.Auth)
+		if err != nil {
+			if code == http.StatusInternalServerError {
+				c.AbortWithStatusJSON(code, utility.BuildErrorResponse(code, "error", err.Error(), err, nil))
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, stepUpRequiredResponse(c, maxAge))
+			return
+		}
+
+		if !session.IsElevated(maxAge) {
+			c.AbortWithStatusJSON(http.StatusForbidden, stepUpRequiredResponse(c, maxAge))
+			return
+		}
+	}
+}
+
+// stepUpRequiredResponse also sets the RFC 9470 challenge so oauth aware clients can react to it
+func stepUpRequiredResponse(c *gin.Context, maxAge time.Duration) utility.Response {
+	seconds := int(maxAge.Seconds())
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_user_authentication", error_description="a recent re-authentication is required", max_age=%d`, seconds))
+	return utility.BuildErrorResponse(http.StatusForbidden, "error", StepUpRequired, fmt.Errorf("this action requires re-authenticating within the last %v minutes", int(maxAge.Minutes())), gin.H{
+		"error":   StepUpRequired,
+		"max_age": seconds,
+		"methods": []string{StepUpMethodPassword, StepUpMethodOtp, StepUpMethodTotp},
+	})
+}
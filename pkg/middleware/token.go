@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -33,13 +35,13 @@ func CreateToken(user models.User, universalAccess bool) (*TokenDetailsDTO, erro
 	atClaims["type"] = user.AccountType
 	atClaims["account_id"] = int(user.AccountID)
 	atClaims["access_uuid"] = td.AccessUuid
+	atClaims["jti"] = td.AccessUuid
 	atClaims["authorised"] = true
 	atClaims["universal_access"] = universalAccess
 	atClaims["exp"] = td.AtExpiresTime.Unix()
 	// atClaims["exp"] = time.Now().AddDate(0, 0, 7).Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
 
-	td.AccessToken, err = token.SignedString([]byte(config.Server.Secret))
+	td.AccessToken, err = signClaims(atClaims)
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +55,107 @@ func CreateToken(user models.User, universalAccess bool) (*TokenDetailsDTO, erro
 	return td, nil
 }
 
+const (
+	OauthGrantAuthorizationCode = "authorization_code"
+	OauthGrantClientCredentials = "client_credentials"
+)
+
+// CreateOauthToken issues an access token to an oauth client, the scope claim limits it to routes registered with Scoped
+func CreateOauthToken(user models.User, clientID, grant string, scopes []string) (*TokenDetailsDTO, error) {
+	td := &TokenDetailsDTO{}
+	td.AtExpiresTime = time.Now().Add(time.Hour * time.Duration(config.GetConfig().Server.AccessTokenExpireDuration))
+	AccessUuid, _ := uuid.NewV4()
+	td.AccessUuid = AccessUuid.String()
+
+	atClaims := jwt.MapClaims{}
+	atClaims["type"] = user.AccountType
+	atClaims["account_id"] = int(user.AccountID)
+	atClaims["access_uuid"] = td.AccessUuid
+	atClaims["jti"] = td.AccessUuid
+	atClaims["authorised"] = true
+	atClaims["universal_access"] = false
+	atClaims["client_id"] = clientID
+	atClaims["grant"] = grant
+	atClaims["scope"] = strings.Join(scopes, " ")
+	atClaims["exp"] = td.AtExpiresTime.Unix()
+
+	var err error
+	td.AccessToken, err = signClaims(atClaims)
+	if err != nil {
+		return nil, err
+	}
+	return td, nil
+}
+
+// CreateImpersonationToken issues a short-lived access token for user on behalf of an admin. The act claim
+// (RFC 8693) names the admin, tokens carrying it are read-only and every request made with them is audited.
+func CreateImpersonationToken(user models.User, actorAccountID int) (*TokenDetailsDTO, error) {
+	td := &TokenDetailsDTO{}
+	td.AtExpiresTime = time.Now().Add(ImpersonationExpireDuration())
+	AccessUuid, _ := uuid.NewV4()
+	td.AccessUuid = AccessUuid.String()
+
+	atClaims := jwt.MapClaims{}
+	atClaims["type"] = user.AccountType
+	atClaims["account_id"] = int(user.AccountID)
+	atClaims["access_uuid"] = td.AccessUuid
+	atClaims["jti"] = td.AccessUuid
+	atClaims["authorised"] = true
+	atClaims["universal_access"] = false
+	atClaims["act"] = map[string]interface{}{"sub": strconv.Itoa(actorAccountID)}
+	atClaims["exp"] = td.AtExpiresTime.Unix()
+
+	var err error
+	td.AccessToken, err = signClaims(atClaims)
+	if err != nil {
+		return nil, err
+	}
+	return td, nil
+}
+
+const (
+	MfaTokenPurpose        = "mfa"
+	MfaTokenExpireDuration = 5 * time.Minute
+)
+
+// CreateMfaToken issues the short-lived challenge token returned by login when a second factor is required.
+// It is not authorised, so it can only be exchanged at the mfa login endpoint.
+func CreateMfaToken(user models.User) (string, time.Time, error) {
+	expiresAt := time.Now().Add(MfaTokenExpireDuration)
+
+	mfaClaims := jwt.MapClaims{}
+	mfaClaims["account_id"] = int(user.AccountID)
+	mfaClaims["purpose"] = MfaTokenPurpose
+	mfaClaims["authorised"] = false
+	mfaClaims["exp"] = expiresAt.Unix()
+
+	signed, err := signClaims(mfaClaims)
+	if err != nil {
+		return "", expiresAt, err
+	}
+	return signed, expiresAt, nil
+}
+
+// ParseMfaToken returns the account id an mfa challenge token was issued for
+func ParseMfaToken(mfaToken string) (int, error) {
+	token, err := TokenValid(mfaToken)
+	if err != nil {
+		return 0, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	purpose, _ := claims["purpose"].(string)
+	if purpose != MfaTokenPurpose {
+		return 0, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	accountID, ok := claims["account_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid or expired mfa token")
+	}
+	return int(accountID), nil
+}
+
 // TokenValid method
 func TokenValid(bearerToken string) (*jwt.Token, error) {
 	token, err := verifyToken(bearerToken)
@@ -69,13 +172,7 @@ func TokenValid(bearerToken string) (*jwt.Token, error) {
 }
 
 func verifyToken(tokenString string) (*jwt.Token, error) {
-	config := config.GetConfig()
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(config.Server.Secret), nil
-	})
+	token, err := jwt.Parse(tokenString, verificationKey)
 	if err != nil {
 		return token, fmt.Errorf("Unauthorized")
 	}
@@ -0,0 +1,107 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+var bloomFilterMagic = []byte("PWBLOOM1")
+
+// BloomFilter is a compact set of breached passwords, it can report false positives but never false negatives.
+// Serialized filters start with the PWBLOOM1 magic, the bit count and hash count, followed by the bit array.
+type BloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint32
+}
+
+// NewBloomFilter sizes a filter for n passwords at the given false positive rate
+func NewBloomFilter(n int, falsePositiveRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.001
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	m = (m + 63) / 64 * 64
+	return &BloomFilter{bits: make([]uint64, m/64), m: m, k: k}
+}
+
+func (b *BloomFilter) Add(password string) {
+	h1, h2 := bloomHashes(password)
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Test reports whether password is probably in the set
+func (b *BloomFilter) Test(password string) bool {
+	h1, h2 := bloomHashes(password)
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 20)
+	copy(header, bloomFilterMagic)
+	binary.BigEndian.PutUint64(header[8:], b.m)
+	binary.BigEndian.PutUint32(header[16:], b.k)
+	if _, err := bw.Write(header); err != nil {
+		return 0, err
+	}
+
+	word := make([]byte, 8)
+	for _, bits := range b.bits {
+		binary.BigEndian.PutUint64(word, bits)
+		if _, err := bw.Write(word); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(header) + 8*len(b.bits)), bw.Flush()
+}
+
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("reading bloom filter header failed: %v", err.Error())
+	}
+	if !bytes.Equal(header[:8], bloomFilterMagic) {
+		return nil, fmt.Errorf("not a breached password bloom filter")
+	}
+
+	b := &BloomFilter{m: binary.BigEndian.Uint64(header[8:]), k: binary.BigEndian.Uint32(header[16:])}
+	if b.m == 0 || b.m%64 != 0 || b.k == 0 {
+		return nil, fmt.Errorf("invalid bloom filter size")
+	}
+
+	b.bits = make([]uint64, b.m/64)
+	word := make([]byte, 8)
+	for i := range b.bits {
+		if _, err := io.ReadFull(br, word); err != nil {
+			return nil, fmt.Errorf("reading bloom filter failed: %v", err.Error())
+		}
+		b.bits[i] = binary.BigEndian.Uint64(word)
+	}
+	return b, nil
+}
+
+func bloomHashes(password string) (uint64, uint64) {
+	sum := sha256.Sum256([]byte(password))
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
@@ -0,0 +1,80 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+var breachedPasswords = struct {
+	mu     sync.RWMutex
+	filter *BloomFilter
+}{}
+
+// LoadBreachedPasswords loads the configured breached password dataset. Files ending in .bloom are read as a
+// serialized BloomFilter, any other file is read as a newline separated password list.
+// Without a dataset the breached password check is skipped.
+func LoadBreachedPasswords(logger *utility.Logger) error {
+	path := config.GetConfig().PasswordPolicy.BreachedPasswordsFile
+	if path == "" {
+		logger.Info("breached passwords file not configured, skipping breached password check")
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening breached passwords file failed: %v", err.Error())
+	}
+	defer file.Close()
+
+	var filter *BloomFilter
+	if filepath.Ext(path) == ".bloom" {
+		filter, err = ReadBloomFilter(file)
+	} else {
+		filter, err = readPasswordList(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	SetBreachedPasswords(filter)
+	logger.Info("breached passwords loaded", path)
+	return nil
+}
+
+// SetBreachedPasswords replaces the dataset used by the breached password check, nil disables the check
+func SetBreachedPasswords(filter *BloomFilter) {
+	breachedPasswords.mu.Lock()
+	defer breachedPasswords.mu.Unlock()
+	breachedPasswords.filter = filter
+}
+
+func IsBreached(password string) bool {
+	breachedPasswords.mu.RLock()
+	defer breachedPasswords.mu.RUnlock()
+	return breachedPasswords.filter != nil && breachedPasswords.filter.Test(password)
+}
+
+func readPasswordList(file *os.File) (*BloomFilter, error) {
+	passwords := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			passwords = append(passwords, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading breached passwords file failed: %v", err.Error())
+	}
+
+	filter := NewBloomFilter(len(passwords), 0.001)
+	for _, password := range passwords {
+		filter.Add(password)
+	}
+	return filter, nil
+}
@@ -0,0 +1,94 @@
+package passwordpolicy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/vesicash/auth-ms/internal/config"
+)
+
+const (
+	RuleMinLength    = "min_length"
+	RuleUpper        = "require_upper"
+	RuleLower        = "require_lower"
+	RuleDigit        = "require_digit"
+	RuleSymbol       = "require_symbol"
+	RulePersonalInfo = "personal_info"
+	RuleBreached     = "breached"
+	RuleHistory      = "history"
+)
+
+// MinLength is the shortest password accepted whatever the configuration says, a policy can only raise it
+const MinLength = 8
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error lists every rule a candidate password broke, so the user can fix them all at once
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	return "password does not meet the password policy"
+}
+
+// Check validates password against policy and the breached password list. personalInfo holds values the
+// password must not contain, such as the email address and username of the account.
+func Check(policy config.PasswordPolicy, password string, personalInfo ...string) []Violation {
+	var (
+		violations                   = []Violation{}
+		hasUpper, hasLower, hasDigit bool
+		hasSymbol                    bool
+		lowerPassword                = strings.ToLower(password)
+	)
+
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	minLength := policy.MinLength
+	if minLength < MinLength {
+		minLength = MinLength
+	}
+	if len([]rune(password)) < minLength {
+		violations = append(violations, Violation{RuleMinLength, fmt.Sprintf("password must be at least %v characters long", minLength)})
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, Violation{RuleUpper, "password must contain an uppercase letter"})
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, Violation{RuleLower, "password must contain a lowercase letter"})
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, Violation{RuleDigit, "password must contain a digit"})
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, Violation{RuleSymbol, "password must contain a symbol"})
+	}
+
+	for _, info := range personalInfo {
+		info = strings.ToLower(strings.TrimSpace(info))
+		if len(info) >= 3 && strings.Contains(lowerPassword, info) {
+			violations = append(violations, Violation{RulePersonalInfo, "password must not contain your email address or username"})
+			break
+		}
+	}
+
+	if IsBreached(password) {
+		violations = append(violations, Violation{RuleBreached, "password has appeared in a data breach, choose a different one"})
+	}
+	return violations
+}
@@ -0,0 +1,97 @@
+// Package ratelimit counts requests per key in fixed windows. The counters live in a Store, MemoryStore keeps
+// them in the process for a single instance and PostgresStore in the database so a cluster shares them.
+package ratelimit
+
+import (
+	"time"
+)
+
+// Store keeps the request counters of every key and window
+type Store interface {
+	// Increment adds n to the counter of key for the window starting at windowStart and returns the count after
+	// the change, the counter can be dropped once expiresAt passes
+	Increment(key string, windowStart time.Time, n int64, expiresAt time.Time) (int64, error)
+	// DeleteExpired drops the counters of windows that have ended
+	DeleteExpired() error
+}
+
+// Policy allows Limit requests per Window, a zero Window counts per calendar month in UTC
+type Policy struct {
+	Limit  int64
+	Window time.Duration
+}
+
+// Monthly is a policy allowing limit requests per calendar month
+func Monthly(limit int64) Policy {
+	return Policy{Limit: limit}
+}
+
+// IsUnlimited reports whether the policy lets every request through
+func (p Policy) IsUnlimited() bool {
+	return p.Limit <= 0
+}
+
+// bounds returns the start of the window now falls in and when the next one starts
+func (p Policy) bounds(now time.Time) (time.Time, time.Time) {
+	if p.Window <= 0 {
+		now = now.UTC()
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := now.Truncate(p.Window)
+	return start, start.Add(p.Window)
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	Reset     time.Time
+}
+
+// ResetAfter is how long until the window of the result ends, rounded up to whole seconds
+func (r Result) ResetAfter(now time.Time) int64 {
+	seconds := int64(r.Reset.Sub(now) / time.Second)
+	if r.Reset.Sub(now)%time.Second > 0 {
+		seconds++
+	}
+	if seconds < 0 {
+		return 0
+	}
+	return seconds
+}
+
+type Limiter struct {
+	Store Store
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{Store: store}
+}
+
+// Take counts a request for key against the policy
+func (l *Limiter) Take(key string, policy Policy, now time.Time) (Result, error) {
+	start, reset := policy.bounds(now)
+	count, err := l.Store.Increment(key, start, 1, reset)
+	if err != nil {
+		return Result{}, err
+	}
+
+	remaining := policy.Limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Result{
+		Allowed:   count <= policy.Limit,
+		Limit:     policy.Limit,
+		Remaining: remaining,
+		Reset:     reset,
+	}, nil
+}
+
+// Refund gives back a request taken for key at now, for requests that end up counted against another key
+func (l *Limiter) Refund(key string, policy Policy, now time.Time) error {
+	start, reset := policy.bounds(now)
+	_, err := l.Store.Increment(key, start, -1, reset)
+	return err
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vesicash/auth-ms/internal/models"
+	"gorm.io/gorm"
+)
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// MemoryStore keeps the counters in the process, each instance of the service counts on its own
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: map[string]*memoryCounter{}}
+}
+
+func (s *MemoryStore) Increment(key string, windowStart time.Time, n int64, expiresAt time.Time) (int64, error) {
+	id := fmt.Sprintf("%v|%v", key, windowStart.UnixNano())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter, ok := s.counters[id]
+	if !ok {
+		counter = &memoryCounter{expiresAt: expiresAt}
+		s.counters[id] = counter
+	}
+	counter.count += n
+	return counter.count, nil
+}
+
+func (s *MemoryStore) DeleteExpired() error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, counter := range s.counters {
+		if now.After(counter.expiresAt) {
+			delete(s.counters, id)
+		}
+	}
+	return nil
+}
+
+// PostgresStore keeps the counters in the rate_limit_counters table so every instance of the service shares them
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Increment(key string, windowStart time.Time, n int64, expiresAt time.Time) (int64, error) {
+	counter := models.RateLimitCounter{Key: key, WindowStart: windowStart, ExpiresAt: expiresAt}
+	err := counter.Increment(s.db, n)
+	if err != nil {
+		return 0, fmt.Errorf("rate limit counter update failed: %v", err.Error())
+	}
+	return counter.Count, nil
+}
+
+func (s *PostgresStore) DeleteExpired() error {
+	counter := models.RateLimitCounter{}
+	return counter.DeleteExpired(s.db)
+}
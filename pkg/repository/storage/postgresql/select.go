@@ -72,3 +72,9 @@ func CheckExistsInTable(db *gorm.DB, table string, query interface{}, args ...in
 	tx := db.Table(table).Where(query, args...).Take(&result)
 	return tx.RowsAffected != 0
 }
+
+func CountRecords(db *gorm.DB, model interface{}, query interface{}, args ...interface{}) (int64, error) {
+	var count int64
+	tx := db.Model(model).Where(query, args...).Count(&count)
+	return count, tx.Error
+}
@@ -11,3 +11,11 @@ func SaveAllFields(db *gorm.DB, model interface{}) (*gorm.DB, error) {
 	}
 	return result, nil
 }
+
+func UpdateFieldsWhere(db *gorm.DB, model interface{}, fields interface{}, query interface{}, args ...interface{}) (int64, error) {
+	result := db.Model(model).Where(query, args...).Updates(fields)
+	if result.Error != nil {
+		return result.RowsAffected, result.Error
+	}
+	return result.RowsAffected, nil
+}
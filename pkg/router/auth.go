@@ -14,13 +14,21 @@ import (
 func Auth(r *gin.Engine, ApiVersion string, validator *validator.Validate, db postgresql.Databases, logger *utility.Logger) *gin.Engine {
 	auth := auth.Controller{Db: db, Validator: validator, Logger: logger}
 
-	authUrl := r.Group(fmt.Sprintf("%v", ApiVersion))
+	authUrl := r.Group(fmt.Sprintf("%v", ApiVersion), middleware.LimitAnonymous())
 	{
 		authUrl.POST("/signup", auth.Signup)
 		authUrl.POST("/signup/bulk", auth.BulkSignup)
 
 		authUrl.POST("/login", auth.Login)
 		authUrl.POST("/login-phone", auth.PhoneOtpLogin)
+		authUrl.POST("/login/mfa", auth.LoginMfa)
+		authUrl.POST("/login/webauthn/begin", auth.BeginWebauthnLogin)
+		authUrl.POST("/login/webauthn/finish", auth.FinishWebauthnLogin)
+		authUrl.POST("/login/magic-link", auth.RequestMagicLink)
+		authUrl.POST("/login/magic-link/consume", auth.ConsumeMagicLink)
+		authUrl.POST("/login/authorize-device", auth.AuthorizeDevice)
+		authUrl.POST("/contact-change/undo", auth.UndoContactChange)
+		authUrl.POST("/token/refresh", auth.RefreshToken)
 
 		authUrl.POST("/otp/send_otp", auth.SendOTPAPI)
 		authUrl.POST("/is_otp_valid", auth.ValidateOtp)
@@ -33,46 +41,88 @@ func Auth(r *gin.Engine, ApiVersion string, validator *validator.Validate, db po
 
 	}
 
+	// sensitive actions need the session to have re-authenticated at /auth/step-up recently
+	stepUp := middleware.RequireStepUp(db, middleware.StepUpMaxAge())
+	// impersonation tokens are refused by mutating routes already, this covers reads that expose secrets
+	noImpersonation := middleware.RefuseImpersonation(db)
+
 	authTypeUrl := r.Group(fmt.Sprintf("%v", ApiVersion), middleware.Authorize(db, middleware.AuthType))
 	{
+		authTypeUrl.POST("/auth/step-up", auth.StepUp)
+
 		authTypeUrl.POST("/send_otp", auth.SendOTP)
 
-		authTypeUrl.POST("/user/bank_details", auth.AddBankDetails)
+		authTypeUrl.POST("/user/bank_details", stepUp, auth.AddBankDetails)
 		authTypeUrl.POST("/user/update_tour_status", auth.UpdateTourStatus)
 
-		authTypeUrl.GET("/user/restrictions", auth.GetUserRestrictions)
 		authTypeUrl.POST("/user/upgrade_tier", auth.UpgradeUserTier)
 		authTypeUrl.POST("/user/upgrade/account", auth.UpgradeAccount)
 
-		authTypeUrl.POST("/user/security/update_password", auth.UpdatePassword)
-		authTypeUrl.GET("/user/security/get_access_token", auth.GetAccessToken)
-
-		authTypeUrl.GET("/user/disbursements", auth.GetDisbursements)
+		authTypeUrl.POST("/user/security/update_password", stepUp, auth.UpdatePassword)
+		authTypeUrl.GET("/user/security/get_access_token", noImpersonation, stepUp, auth.GetAccessToken)
+		authTypeUrl.POST("/user/api-keys", stepUp, auth.CreateApiKey)
+		authTypeUrl.GET("/user/api-keys", noImpersonation, stepUp, auth.GetApiKeys)
+		authTypeUrl.PATCH("/user/api-keys/:key_id", stepUp, auth.UpdateApiKey)
+		authTypeUrl.DELETE("/user/api-keys/:key_id", auth.DeleteApiKey)
+		authTypeUrl.POST("/user/security/totp/enroll", auth.EnrollTotp)
+		authTypeUrl.POST("/user/security/totp/confirm", auth.ConfirmTotp)
+		authTypeUrl.POST("/user/security/totp/disable", auth.DisableTotp)
+		authTypeUrl.POST("/user/security/webauthn/register/begin", auth.BeginWebauthnRegistration)
+		authTypeUrl.POST("/user/security/webauthn/register/finish", auth.FinishWebauthnRegistration)
+		authTypeUrl.GET("/user/security/webauthn/credentials", auth.GetWebauthnCredentials)
+		authTypeUrl.DELETE("/user/security/webauthn/credentials/:credential_id", auth.DeleteWebauthnCredential)
 
 		authTypeUrl.GET("/business/customers/bank_details", auth.GetBusinessCustomersBankDetails)
 
 		authTypeUrl.POST("/validate-token", auth.ValidateToken)
 		authTypeUrl.POST("/logout", auth.Logout)
 
-		authTypeUrl.POST("/toggle-mor-status", auth.ToggleMorStatus)
+		authTypeUrl.GET("/user/sessions", auth.GetSessions)
+		authTypeUrl.DELETE("/user/sessions/:session_id", auth.RevokeSession)
+		authTypeUrl.DELETE("/user/sessions", auth.RevokeAllSessions)
+		authTypeUrl.GET("/user/devices", auth.GetDevices)
+		authTypeUrl.DELETE("/user/devices/:device_id", auth.ForgetDevice)
+		authTypeUrl.POST("/user/contact-change", stepUp, auth.RequestContactChange)
+		authTypeUrl.POST("/user/contact-change/confirm", auth.ConfirmContactChange)
+		authTypeUrl.GET("/user/audit-logs", auth.GetAuditLogs)
+
+		authTypeUrl.GET("/user/data-export", noImpersonation, stepUp, auth.ExportData)
+		authTypeUrl.POST("/user/erasure", stepUp, auth.RequestErasure)
+		authTypeUrl.GET("/user/erasure", auth.GetErasure)
+		authTypeUrl.DELETE("/user/erasure", auth.CancelErasure)
+
+		authTypeUrl.POST("/toggle-mor-status", stepUp, auth.ToggleMorStatus)
 
 		authTypeUrl.POST("/revoke-token", auth.RevokeTokenHandler)
 
-		authTypeUrl.GET("/account/wallet", auth.GetUserWalletBalance)
+	}
 
+	// routes oauth clients can call with an access token carrying the listed scopes, the wallet and disbursement
+	// reads also take api keys and answer test keys with sandbox data
+	scopedUrl := r.Group(fmt.Sprintf("%v", ApiVersion))
+	{
+		scopedUrl.GET("/user/restrictions", middleware.Scoped(db, []string{middleware.ScopeProfile}, middleware.AuthType), auth.GetUserRestrictions)
+		scopedUrl.GET("/user/disbursements", middleware.Scoped(db, []string{middleware.ScopeDisbursementsRead}, middleware.AuthType, middleware.ApiType), auth.GetDisbursements)
+		scopedUrl.GET("/account/wallet", middleware.Scoped(db, []string{middleware.ScopeWalletRead}, middleware.AuthType, middleware.ApiType), auth.GetUserWalletBalance)
 	}
 
 	businessAdminUrl := r.Group(fmt.Sprintf("%v", ApiVersion), middleware.Authorize(db, middleware.BusinessAdmin))
 	{
 		businessAdminUrl.GET("/users/get", auth.GetUsers)
+		businessAdminUrl.POST("/users/unlock", auth.UnlockAccount)
+		businessAdminUrl.POST("/users/logout", auth.ForceLogout)
+		businessAdminUrl.POST("/users/impersonate", auth.Impersonate)
+		businessAdminUrl.POST("/users/ban", auth.BanAccount)
+		businessAdminUrl.POST("/users/unban", auth.UnbanAccount)
+		businessAdminUrl.GET("/users/bans", auth.GetBans)
 
 		businessAdminUrl.GET("/countries/mor", auth.ListSelectedCountries)
 
 	}
 
-	authApiUrl := r.Group(fmt.Sprintf("%v/api", ApiVersion), middleware.Authorize(db, middleware.ApiType))
+	authApiUrl := r.Group(fmt.Sprintf("%v/api", ApiVersion))
 	{
-		authApiUrl.POST("/send_otp", auth.SendOTPAPI)
+		authApiUrl.POST("/send_otp", middleware.Scoped(db, []string{middleware.ScopeOtpSend}, middleware.ApiType), auth.SendOTPAPI)
 
 	}
 	return r
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/vesicash/auth-ms/pkg/controller/health"
+	"github.com/vesicash/auth-ms/pkg/middleware"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
 	"github.com/vesicash/auth-ms/utility"
 )
@@ -13,7 +14,7 @@ import (
 func Health(r *gin.Engine, ApiVersion string, validator *validator.Validate, db postgresql.Databases, logger *utility.Logger) *gin.Engine {
 	healthController := health.Controller{Db: db, Logger: logger}
 
-	healthUrl := r.Group(fmt.Sprintf("%v", ApiVersion))
+	healthUrl := r.Group(fmt.Sprintf("%v", ApiVersion), middleware.LimitAnonymous())
 	{
 		healthUrl.GET("/health", healthController.Get)
 	}
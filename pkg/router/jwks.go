@@ -0,0 +1,15 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/pkg/controller/jwks"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func Jwks(r *gin.Engine, logger *utility.Logger) *gin.Engine {
+	jwksController := jwks.Controller{Logger: logger}
+
+	r.GET("/.well-known/jwks.json", middleware.LimitAnonymous(), jwksController.Get)
+	return r
+}
@@ -0,0 +1,34 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/vesicash/auth-ms/pkg/controller/oauth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func Oauth(r *gin.Engine, ApiVersion string, validator *validator.Validate, db postgresql.Databases, logger *utility.Logger) *gin.Engine {
+	oauth := oauth.Controller{Db: db, Validator: validator, Logger: logger}
+
+	oauthUrl := r.Group(fmt.Sprintf("%v/oauth", ApiVersion), middleware.LimitAnonymous())
+	{
+		oauthUrl.POST("/token", oauth.Token)
+		oauthUrl.POST("/introspect", oauth.Introspect)
+		oauthUrl.POST("/revoke", oauth.Revoke)
+	}
+
+	oauthAuthUrl := r.Group(fmt.Sprintf("%v/oauth", ApiVersion), middleware.Authorize(db, middleware.AuthType))
+	{
+		oauthAuthUrl.GET("/authorize", oauth.Authorize)
+		oauthAuthUrl.POST("/authorize", oauth.Consent)
+
+		oauthAuthUrl.POST("/clients", oauth.CreateClient)
+		oauthAuthUrl.GET("/clients", oauth.GetClients)
+		oauthAuthUrl.DELETE("/clients/:client_id", oauth.DeleteClient)
+	}
+	return r
+}
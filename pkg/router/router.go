@@ -25,7 +25,7 @@ func Setup(logger *utility.Logger, validator *validator.Validate, db postgresql.
 	r.SetTrustedProxies(config.GetConfig().Server.TrustedProxies)
 	r.Use(middleware.PrometheusMiddleware())
 	r.Use(middleware.Security())
-	r.Use(middleware.Throttle())
+	r.Use(middleware.RateLimit(db))
 	r.Use(middleware.Logger())
 	r.Use(gin.Recovery())
 	r.Use(middleware.CORS())
@@ -36,8 +36,10 @@ func Setup(logger *utility.Logger, validator *validator.Validate, db postgresql.
 	Health(r, ApiVersion, validator, db, logger)
 	Auth(r, ApiVersion, validator, db, logger)
 	Model(r, ApiVersion, validator, db, logger)
+	Oauth(r, ApiVersion, validator, db, logger)
+	Jwks(r, logger)
 
-	r.GET("/", func(c *gin.Context) {
+	r.GET("/", middleware.LimitAnonymous(), func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"code":    200,
 			"message": "Welcome to auth micro-service",
@@ -45,7 +47,7 @@ func Setup(logger *utility.Logger, validator *validator.Validate, db postgresql.
 		})
 	})
 
-	r.NoRoute(func(c *gin.Context) {
+	r.NoRoute(middleware.LimitAnonymous(), func(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"name":    "Not Found",
 			"message": "Page not found.",
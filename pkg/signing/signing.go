@@ -0,0 +1,103 @@
+// Package signing signs requests to the api routes so the private key never has to leave the client. It only
+// depends on the standard library so merchants can vendor it as is.
+//
+// The client derives a signing key from its private key and signs the method, path, body hash, timestamp and
+// nonce of the request with HMAC-SHA256. The public key is sent as the key id along with the timestamp, nonce
+// and signature headers.
+package signing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderKeyID     = "v-key-id"
+	HeaderTimestamp = "v-timestamp"
+	HeaderNonce     = "v-nonce"
+	HeaderSignature = "v-signature"
+
+	signingKeyContext = "vesicash-request-signing"
+)
+
+// SigningKey derives the key requests are signed with from an api private key
+func SigningKey(privateKey string) string {
+	mac := hmac.New(sha256.New, []byte(privateKey))
+	mac.Write([]byte(signingKeyContext))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// StringToSign builds the canonical form of a request, path includes the query string
+func StringToSign(method, path string, body []byte, timestamp, nonce string) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		hex.EncodeToString(bodyHash[:]),
+		timestamp,
+		nonce,
+	}, "\n")
+}
+
+// Sign returns the hex encoded signature of stringToSign
+func Sign(signingKey, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify compares signature with the expected one in constant time
+func Verify(signingKey, stringToSign, signature string) bool {
+	expected, err := hex.DecodeString(Sign(signingKey, stringToSign))
+	if err != nil {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, got)
+}
+
+// SignRequest sets the signature headers on req, the body is read and put back so req can still be sent
+func SignRequest(req *http.Request, publicKey, privateKey string) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(HeaderKeyID, publicKey)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Sign(SigningKey(privateKey), StringToSign(req.Method, req.URL.RequestURI(), body, timestamp, nonce)))
+	return nil
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -6,10 +6,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/internal/models"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
-	"github.com/vesicash/auth-ms/utility"
 )
 
 func IssueAccessTokenService(db postgresql.Databases, accountID int) (models.AccessToken, int, error) {
@@ -23,7 +21,7 @@ func IssueAccessTokenService(db postgresql.Databases, accountID int) (models.Acc
 		return token, code, err
 	}
 
-	code, err = token.GetByAccountID(db.Auth)
+	code, err = token.GetDefaultByAccountID(db.Auth)
 	if err != nil {
 		if code == http.StatusInternalServerError {
 			return token, code, err
@@ -35,10 +33,12 @@ func IssueAccessTokenService(db postgresql.Databases, accountID int) (models.Acc
 		}
 	}
 
-	app := config.GetConfig().App
-	token.IsLive = true
-	token.PrivateKey = "v_" + app.Name + "_" + utility.RandomString(50)
-	token.PublicKey = "v_" + app.Name + "_" + utility.RandomString(50)
+	err = token.GenerateKeys()
+	if err != nil {
+		return token, http.StatusInternalServerError, err
+	}
+	token.IsLive = token.Environment == models.ApiKeyEnvironmentLive
+	token.RevokedAt = nil
 
 	err = token.Update(db.Auth)
 	if err != nil {
@@ -126,7 +126,7 @@ func RevokeAccessTokenService(db postgresql.Databases, accountID int) (models.Ac
 		return token, code, err
 	}
 
-	code, err = token.GetByAccountID(db.Auth)
+	code, err = token.GetDefaultByAccountID(db.Auth)
 	if err != nil {
 		if code == http.StatusInternalServerError {
 			return token, code, err
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+)
+
+// CreateApiKeyService issues a new named key pair, live unless a test key is asked for. Keys created without
+// scopes can call every api key route.
+func CreateApiKeyService(db postgresql.Databases, accountID int, req models.CreateApiKeyReq) (models.AccessToken, int, error) {
+	scopes, err := middleware.ParseApiKeyScopes(req.Scopes)
+	if err != nil {
+		return models.AccessToken{}, http.StatusBadRequest, err
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return models.AccessToken{}, http.StatusBadRequest, fmt.Errorf("expires_at must be in the future")
+	}
+
+	code, err := checkApiKeyLabel(db, accountID, 0, req.Label)
+	if err != nil {
+		return models.AccessToken{}, code, err
+	}
+
+	token := models.AccessToken{
+		AccountID:   accountID,
+		Label:       req.Label,
+		Environment: req.Environment,
+		Scope:       strings.Join(scopes, " "),
+		ExpiresAt:   req.ExpiresAt,
+	}
+	err = token.CreateAccessToken(db.Auth)
+	if err != nil {
+		return token, http.StatusInternalServerError, err
+	}
+	return token, http.StatusOK, nil
+}
+
+func ListApiKeysService(db postgresql.Databases, accountID int) ([]models.AccessToken, int, error) {
+	token := models.AccessToken{AccountID: accountID}
+	tokens, err := token.GetAllActiveByAccountID(db.Auth)
+	if err != nil {
+		return tokens, http.StatusInternalServerError, err
+	}
+	return tokens, http.StatusOK, nil
+}
+
+// UpdateApiKeyService renames a key or changes its scopes, an empty scope list lifts the restriction
+func UpdateApiKeyService(db postgresql.Databases, accountID int, keyID uint, req models.UpdateApiKeyReq) (models.AccessToken, int, error) {
+	token, code, err := getActiveApiKey(db, accountID, keyID)
+	if err != nil {
+		return token, code, err
+	}
+
+	if req.Label != "" && req.Label != token.Label {
+		code, err = checkApiKeyLabel(db, accountID, token.ID, req.Label)
+		if err != nil {
+			return token, code, err
+		}
+		token.Label = req.Label
+	}
+
+	if req.Scopes != nil {
+		scopes, err := middleware.ParseApiKeyScopes(*req.Scopes)
+		if err != nil {
+			return token, http.StatusBadRequest, err
+		}
+		token.Scope = strings.Join(scopes, " ")
+	}
+
+	err = token.Update(db.Auth)
+	if err != nil {
+		return token, http.StatusInternalServerError, err
+	}
+	return token, http.StatusOK, nil
+}
+
+func DeleteApiKeyService(db postgresql.Databases, accountID int, keyID uint) (int, error) {
+	token, code, err := getActiveApiKey(db, accountID, keyID)
+	if err != nil {
+		return code, err
+	}
+
+	err = token.RevokeAccessToken(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func getActiveApiKey(db postgresql.Databases, accountID int, keyID uint) (models.AccessToken, int, error) {
+	token := models.AccessToken{ID: keyID, AccountID: accountID}
+	code, err := token.GetByIDAndAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return token, code, err
+		}
+		return token, http.StatusNotFound, fmt.Errorf("api key not found")
+	}
+	if token.IsRevoked() {
+		return token, http.StatusNotFound, fmt.Errorf("api key not found")
+	}
+	return token, http.StatusOK, nil
+}
+
+// checkApiKeyLabel keeps labels unique among the keys of the account that are not revoked so they can tell
+// them apart
+func checkApiKeyLabel(db postgresql.Databases, accountID int, keyID uint, label string) (int, error) {
+	tokens, code, err := ListApiKeysService(db, accountID)
+	if err != nil {
+		return code, err
+	}
+	for _, t := range tokens {
+		if t.ID != keyID && strings.EqualFold(t.Label, label) {
+			return http.StatusBadRequest, fmt.Errorf("an api key labelled %v already exists", label)
+		}
+	}
+	return http.StatusOK, nil
+}
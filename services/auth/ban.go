@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+)
+
+// BanAccountService bans the account and logs it out everywhere, its api keys and oauth clients are revoked and
+// are not restored when the ban is lifted
+func BanAccountService(db postgresql.Databases, adminAccountID int, req models.BanAccountReq) (models.BannedAccount, int, error) {
+	user := models.User{AccountID: uint(req.AccountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return models.BannedAccount{}, code, err
+		}
+		return models.BannedAccount{}, http.StatusNotFound, fmt.Errorf("user not found")
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return models.BannedAccount{}, http.StatusBadRequest, fmt.Errorf("expires_at must be in the future")
+	}
+
+	bannedAccount := models.BannedAccount{AccountID: req.AccountID}
+	banned, err := bannedAccount.CheckByAccountID(db.Auth)
+	if err != nil {
+		return models.BannedAccount{}, http.StatusInternalServerError, err
+	}
+	if banned {
+		return models.BannedAccount{}, http.StatusBadRequest, fmt.Errorf("this account is already banned")
+	}
+
+	bannedAccount = models.BannedAccount{
+		AccountID:  req.AccountID,
+		ReasonCode: req.ReasonCode,
+		Note:       req.Note,
+		BannedBy:   adminAccountID,
+		ExpiresAt:  req.ExpiresAt,
+	}
+	err = bannedAccount.CreateBannedAccount(db.Auth)
+	if err != nil {
+		return bannedAccount, http.StatusInternalServerError, err
+	}
+
+	err = RevokeAllAccountSessions(db, req.AccountID)
+	if err != nil {
+		return bannedAccount, http.StatusInternalServerError, err
+	}
+
+	accessToken := models.AccessToken{AccountID: req.AccountID}
+	err = accessToken.RevokeAllByAccountID(db.Auth)
+	if err != nil {
+		return bannedAccount, http.StatusInternalServerError, err
+	}
+
+	oauthClient := models.OauthClient{AccountID: req.AccountID}
+	err = oauthClient.RevokeAllByAccountID(db.Auth)
+	if err != nil {
+		return bannedAccount, http.StatusInternalServerError, err
+	}
+
+	bannedAccount.Active = true
+	return bannedAccount, http.StatusOK, nil
+}
+
+// UnbanAccountService lifts the ban in force, the ban stays in the history of the account
+func UnbanAccountService(db postgresql.Databases, adminAccountID int, req models.UnbanAccountReq) (models.BannedAccount, int, error) {
+	bannedAccount := models.BannedAccount{AccountID: req.AccountID}
+	code, err := bannedAccount.GetActiveByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return bannedAccount, code, err
+		}
+		return bannedAccount, http.StatusNotFound, fmt.Errorf("this account is not banned")
+	}
+
+	liftedAt := time.Now()
+	bannedAccount.LiftedAt = &liftedAt
+	bannedAccount.LiftedBy = adminAccountID
+	bannedAccount.LiftNote = req.Note
+	err = bannedAccount.Update(db.Auth)
+	if err != nil {
+		return bannedAccount, http.StatusInternalServerError, err
+	}
+	return bannedAccount, http.StatusOK, nil
+}
+
+func ListBansService(db postgresql.Databases, accountIDParam string) ([]models.BannedAccount, int, error) {
+	bannedAccount := models.BannedAccount{}
+	if accountIDParam != "" {
+		accountID, err := strconv.Atoi(accountIDParam)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid account id type")
+		}
+		bannedAccount.AccountID = accountID
+	}
+
+	bans, err := bannedAccount.GetAll(db.Auth)
+	if err != nil {
+		return bans, http.StatusInternalServerError, err
+	}
+
+	for i := range bans {
+		bans[i].Active = bans[i].IsActive()
+	}
+	return bans, http.StatusOK, nil
+}
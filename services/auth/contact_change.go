@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/vesicash/auth-ms/external/microservice/notification"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// RequestContactChangeService sends a confirmation code to the new email address or phone number, the user keeps
+// the current one until the code is confirmed
+func RequestContactChangeService(logger *utility.Logger, db postgresql.Databases, accountID int, req models.RequestContactChangeReq) (models.ContactChange, int, error) {
+	var (
+		otpConfig = config.GetConfig().Otp
+		now       = time.Now()
+	)
+
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return models.ContactChange{}, code, err
+	}
+
+	value, err := validateContactValue(db, req.Channel, req.Value)
+	if err != nil {
+		return models.ContactChange{}, http.StatusBadRequest, err
+	}
+	if value == contactValue(user, req.Channel) {
+		return models.ContactChange{}, http.StatusBadRequest, fmt.Errorf("this is already the %v of your account", contactLabel(req.Channel))
+	}
+
+	otp := models.OtpVerification{AccountID: accountID, Purpose: models.OtpPurposeContactChange, Authenticated: true}
+	if otpConfig.MaxSendsPerHour > 0 {
+		sent, err := otp.CountSentSince(db.Auth, now.Add(-time.Hour))
+		if err != nil {
+			return models.ContactChange{}, http.StatusInternalServerError, err
+		}
+		if sent >= int64(otpConfig.MaxSendsPerHour) {
+			return models.ContactChange{}, http.StatusTooManyRequests, fmt.Errorf("too many otp requests, try again later")
+		}
+	}
+
+	token := strconv.Itoa(utility.GetRandomNumbersInRange(100000, 999999))
+	otp = models.OtpVerification{
+		AccountID:     accountID,
+		Purpose:       models.OtpPurposeContactChange,
+		Authenticated: true,
+		TokenHash:     models.HashOtp(accountID, models.OtpPurposeContactChange, token),
+	}
+	err = otp.Create(db.Auth)
+	if err != nil {
+		return models.ContactChange{}, http.StatusInternalServerError, err
+	}
+
+	contactChange := models.ContactChange{
+		AccountID: accountID,
+		Channel:   req.Channel,
+		OldValue:  contactValue(user, req.Channel),
+		NewValue:  value,
+	}
+	err = contactChange.CreateContactChange(db.Auth)
+	if err != nil {
+		return contactChange, http.StatusInternalServerError, err
+	}
+
+	go notification.SendContactChangeCode(logger, db.Auth, accountID, req.Channel, value, token, otp.ExpiresAt)
+
+	return contactChange, http.StatusOK, nil
+}
+
+// ConfirmContactChangeService puts the new value on the user once the code sent to it is confirmed, and warns the
+// previous value with a link to undo the change
+func ConfirmContactChangeService(logger *utility.Logger, db postgresql.Databases, accountID int, req models.ConfirmContactChangeReq) (models.ContactChange, int, error) {
+	contactChange := models.ContactChange{AccountID: accountID}
+	code, err := contactChange.GetPendingByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return contactChange, code, err
+		}
+		return contactChange, http.StatusBadRequest, fmt.Errorf("no pending contact change")
+	}
+
+	code, err = VerifyOtp(db, accountID, models.OtpPurposeContactChange, req.Code)
+	if err != nil {
+		return contactChange, code, err
+	}
+
+	// the value could have been taken while the code was on its way
+	_, err = validateContactValue(db, contactChange.Channel, contactChange.NewValue)
+	if err != nil {
+		return contactChange, http.StatusBadRequest, err
+	}
+
+	user := models.User{AccountID: uint(accountID)}
+	code, err = user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return contactChange, code, err
+	}
+	setContactValue(&user, contactChange.Channel, contactChange.NewValue)
+	err = user.Update(db.Auth)
+	if err != nil {
+		return contactChange, http.StatusInternalServerError, err
+	}
+
+	undoToken, err := utility.GenerateSecureToken(32)
+	if err != nil {
+		return contactChange, http.StatusInternalServerError, err
+	}
+	var (
+		confirmedAt   = time.Now()
+		undoExpiresAt = confirmedAt.Add(contactChangeUndoWindow())
+	)
+	contactChange.ConfirmedAt = &confirmedAt
+	contactChange.UndoTokenHash = utility.HashToken(undoToken)
+	contactChange.UndoExpiresAt = &undoExpiresAt
+	err = contactChange.Update(db.Auth)
+	if err != nil {
+		return contactChange, http.StatusInternalServerError, err
+	}
+
+	// accounts that had no value on the changed channel are warned on their email address instead
+	channel, recipient := contactChange.Channel, contactChange.OldValue
+	if recipient == "" && channel == models.ContactChannelPhone {
+		channel, recipient = models.ContactChannelEmail, user.EmailAddress
+	}
+	if recipient != "" {
+		go notification.SendContactChangeNotice(logger, db.Auth, accountID, channel, recipient, contactChange.NewValue, contactChangeUndoUrl(undoToken), undoExpiresAt)
+	}
+
+	return contactChange, http.StatusOK, nil
+}
+
+// UndoContactChangeService restores the previous value from the link sent to it. Whoever made the change may
+// have taken over the account, so every session is signed out.
+func UndoContactChangeService(db postgresql.Databases, req models.UndoContactChangeReq) (int, error) {
+	contactChange := models.ContactChange{UndoTokenHash: utility.HashToken(req.Token)}
+	code, err := contactChange.GetByUndoTokenHash(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusBadRequest, fmt.Errorf("invalid or expired undo link")
+	}
+	if !contactChange.CanUndo() {
+		return http.StatusBadRequest, fmt.Errorf("invalid or expired undo link")
+	}
+
+	user := models.User{AccountID: uint(contactChange.AccountID)}
+	code, err = user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return code, err
+	}
+	if contactValue(user, contactChange.Channel) != contactChange.NewValue {
+		return http.StatusBadRequest, fmt.Errorf("the %v has been changed again since, contact support", contactLabel(contactChange.Channel))
+	}
+
+	if contactChange.OldValue != "" {
+		_, err = validateContactValue(db, contactChange.Channel, contactChange.OldValue)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("the previous %v is now used by another account, contact support", contactLabel(contactChange.Channel))
+		}
+	}
+
+	setContactValue(&user, contactChange.Channel, contactChange.OldValue)
+	err = user.Update(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	undoneAt := time.Now()
+	contactChange.UndoneAt = &undoneAt
+	err = contactChange.Update(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = RevokeAllAccountSessions(db, contactChange.AccountID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// validateContactValue applies the signup rules to the value and returns it normalised
+func validateContactValue(db postgresql.Databases, channel, value string) (string, error) {
+	signupReq := models.CreateUserRequestModel{}
+	if channel == models.ContactChannelEmail {
+		signupReq.EmailAddress = value
+	} else {
+		signupReq.PhoneNumber = value
+	}
+
+	signupReq, err := ValidateSignupRequest(signupReq, db)
+	if err != nil {
+		return "", err
+	}
+
+	if channel == models.ContactChannelEmail {
+		return signupReq.EmailAddress, nil
+	}
+	return signupReq.PhoneNumber, nil
+}
+
+func contactValue(user models.User, channel string) string {
+	if channel == models.ContactChannelEmail {
+		return user.EmailAddress
+	}
+	return user.PhoneNumber
+}
+
+func setContactValue(user *models.User, channel, value string) {
+	if channel == models.ContactChannelEmail {
+		user.EmailAddress = value
+	} else {
+		user.PhoneNumber = value
+	}
+}
+
+func contactLabel(channel string) string {
+	if channel == models.ContactChannelEmail {
+		return "email address"
+	}
+	return "phone number"
+}
+
+func contactChangeUndoWindow() time.Duration {
+	hours := config.GetConfig().ContactChange.UndoWindow
+	if hours <= 0 {
+		hours = 48
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func contactChangeUndoUrl(token string) string {
+	return fmt.Sprintf("%v?%v", config.GetConfig().ContactChange.UndoLinkUrl, url.Values{"token": {token}}.Encode())
+}
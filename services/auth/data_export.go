@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+)
+
+// ExportDataService collects everything auth-ms holds about the account into one archive for data subject
+// access requests. Secrets such as password hashes, api keys and totp seeds are left out.
+func ExportDataService(db postgresql.Databases, accountID int) (gin.H, int, error) {
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return nil, code, err
+		}
+		return nil, http.StatusNotFound, fmt.Errorf("user not found")
+	}
+
+	var userProfile *models.UserProfile
+	profile := models.UserProfile{AccountID: accountID}
+	code, err = profile.GetByAccountID(db.Auth)
+	if err != nil && code == http.StatusInternalServerError {
+		return nil, code, err
+	} else if err == nil {
+		userProfile = &profile
+	}
+
+	var businessProfile *models.BusinessProfile
+	business := models.BusinessProfile{AccountID: accountID}
+	code, err = business.GetByAccountID(db.Auth)
+	if err != nil && code == http.StatusInternalServerError {
+		return nil, code, err
+	} else if err == nil {
+		businessProfile = &business
+	}
+
+	bankDetails, err := (&models.BankDetail{AccountID: accountID}).GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	credentials, err := (&models.UsersCredential{AccountID: accountID}).GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	tracking, err := (&models.UserTracking{AccountID: accountID}).GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	wallets, err := (&models.WalletBalance{AccountID: accountID}).GetUserWalletBalances(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	walletHistories, err := (&models.WalletHistory{AccountID: strconv.Itoa(accountID)}).GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	walletTransactions, err := (&models.WalletTransaction{SenderAccountID: strconv.Itoa(accountID)}).GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	devices, err := (&models.Authorize{AccountID: accountID}).GetAllAuthorizedByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	sessions, err := (&models.Session{AccountID: accountID}).GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	contactChanges, err := (&models.ContactChange{AccountID: accountID}).GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	auditLogs, err := (&models.AuditLog{AccountID: accountID}).GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return gin.H{
+		"exported_at":         time.Now(),
+		"user":                user,
+		"user_profile":        userProfile,
+		"business_profile":    businessProfile,
+		"bank_details":        bankDetails,
+		"users_credentials":   credentials,
+		"user_tracking":       tracking,
+		"wallets":             wallets,
+		"wallet_histories":    walletHistories,
+		"wallet_transactions": walletTransactions,
+		"devices":             devices,
+		"sessions":            sessions,
+		"contact_changes":     contactChanges,
+		"audit_logs":          auditLogs,
+	}, http.StatusOK, nil
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/external/microservice/notification"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// deviceAuthorizationRequired reports whether user has authorization required and is logging in from an ip
+// address and browser combination they have not approved yet
+func deviceAuthorizationRequired(c *gin.Context, db postgresql.Databases, user models.User) (bool, error) {
+	if !user.AuthorizationRequired {
+		return false, nil
+	}
+
+	authorize := models.Authorize{AccountID: int(user.AccountID), IpAddress: c.ClientIP(), Browser: deviceBrowser(c)}
+	code, err := authorize.GetAuthorizedDevice(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// requireDeviceAuthorization holds back the session when the device is not approved yet and emails the user a
+// link to approve it, the user logs in again once the device is approved
+func requireDeviceAuthorization(c *gin.Context, logger *utility.Logger, db postgresql.Databases, user models.User) (int, error) {
+	required, err := deviceAuthorizationRequired(c, db, user)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !required {
+		return http.StatusOK, nil
+	}
+
+	var (
+		browser        = deviceBrowser(c)
+		expireDuration = config.GetConfig().DeviceAuthorization.ExpireDuration
+	)
+	if expireDuration <= 0 {
+		expireDuration = 30
+	}
+
+	token, err := utility.GenerateSecureToken(32)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	expiresAt := time.Now().Add(time.Duration(expireDuration) * time.Minute)
+
+	authorize := models.Authorize{AccountID: int(user.AccountID), IpAddress: c.ClientIP(), Browser: browser}
+	code, err := authorize.GetPendingDevice(db.Auth)
+	if err != nil && code == http.StatusInternalServerError {
+		return code, err
+	}
+
+	authorize.Token = utility.HashToken(token)
+	authorize.Os = browser
+	authorize.Attempt++
+	authorize.ExpiresAt = &expiresAt
+	if authorize.ID == 0 {
+		err = authorize.CreateAuthorize(db.Auth)
+	} else {
+		err = authorize.Update(db.Auth)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	go notification.SendDeviceAuthorization(logger, db.Auth, int(user.AccountID), deviceAuthorizationUrl(token), authorize.IpAddress, authorize.Os, expiresAt)
+
+	return http.StatusForbidden, fmt.Errorf("this device needs to be authorized before you can log in, check your email for the authorization link")
+}
+
+// AuthorizeDeviceService approves the device an authorization link was sent for
+func AuthorizeDeviceService(db postgresql.Databases, req models.AuthorizeDeviceReq) (int, error) {
+	authorize := models.Authorize{Token: utility.HashToken(req.Token)}
+	code, err := authorize.GetPendingByToken(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusBadRequest, fmt.Errorf("invalid or expired authorization link")
+	}
+
+	if !authorize.IsPending() {
+		return http.StatusBadRequest, fmt.Errorf("invalid or expired authorization link")
+	}
+
+	authorize.Authorized = true
+	authorize.AuthorizedAt = time.Now()
+	authorize.Token = ""
+	authorize.ExpiresAt = nil
+	err = authorize.Update(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func ListDevicesService(db postgresql.Databases, accountID int) ([]models.Authorize, int, error) {
+	authorize := models.Authorize{AccountID: accountID}
+	devices, err := authorize.GetAllAuthorizedByAccountID(db.Auth)
+	if err != nil {
+		return devices, http.StatusInternalServerError, err
+	}
+	return devices, http.StatusOK, nil
+}
+
+// ForgetDeviceService removes an approved device, logging in from it needs a new authorization
+func ForgetDeviceService(db postgresql.Databases, accountID int, deviceID uint) (int, error) {
+	authorize := models.Authorize{ID: deviceID, AccountID: accountID}
+	code, err := authorize.GetAuthorizedByIDAndAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusNotFound, fmt.Errorf("device not found")
+	}
+
+	err = authorize.Delete(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// deviceBrowser names the browser and os of the request, devices are matched on it rather than the full user
+// agent so a browser update does not need a new authorization
+func deviceBrowser(c *gin.Context) string {
+	return utility.DeviceFromUserAgent(c.Request.UserAgent())
+}
+
+func deviceAuthorizationUrl(token string) string {
+	return fmt.Sprintf("%v?%v", config.GetConfig().DeviceAuthorization.LinkUrl, url.Values{"token": {token}}.Encode())
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+	"gorm.io/gorm"
+)
+
+// RequestErasureService schedules the erasure of the account once the cooling-off period is over. Accounts still
+// holding money have to withdraw it first.
+func RequestErasureService(db postgresql.Databases, accountID int) (models.ErasureRequest, int, error) {
+	erasureRequest := models.ErasureRequest{AccountID: accountID}
+	code, err := erasureRequest.GetPendingByAccountID(db.Auth)
+	if err == nil {
+		return erasureRequest, http.StatusBadRequest, fmt.Errorf("an erasure request is already pending")
+	} else if code == http.StatusInternalServerError {
+		return erasureRequest, code, err
+	}
+
+	wallets, err := (&models.WalletBalance{AccountID: accountID}).GetUserWalletBalances(db.Auth)
+	if err != nil {
+		return erasureRequest, http.StatusInternalServerError, err
+	}
+	for _, wallet := range wallets {
+		if wallet.Available != 0 {
+			return erasureRequest, http.StatusBadRequest, fmt.Errorf("withdraw the balance of your %v wallet before requesting erasure", wallet.Currency)
+		}
+	}
+
+	erasureRequest = models.ErasureRequest{
+		AccountID:    accountID,
+		Status:       models.ErasureStatusPending,
+		ScheduledFor: time.Now().Add(erasureCoolingOff()),
+	}
+	err = erasureRequest.CreateErasureRequest(db.Auth)
+	if err != nil {
+		return erasureRequest, http.StatusInternalServerError, err
+	}
+	return erasureRequest, http.StatusOK, nil
+}
+
+func CancelErasureService(db postgresql.Databases, accountID int) (models.ErasureRequest, int, error) {
+	erasureRequest := models.ErasureRequest{AccountID: accountID}
+	code, err := erasureRequest.GetPendingByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return erasureRequest, code, err
+		}
+		return erasureRequest, http.StatusNotFound, fmt.Errorf("no pending erasure request")
+	}
+
+	cancelledAt := time.Now()
+	erasureRequest.Status = models.ErasureStatusCancelled
+	erasureRequest.CancelledAt = &cancelledAt
+	err = erasureRequest.Update(db.Auth)
+	if err != nil {
+		return erasureRequest, http.StatusInternalServerError, err
+	}
+	return erasureRequest, http.StatusOK, nil
+}
+
+func GetErasureService(db postgresql.Databases, accountID int) (models.ErasureRequest, int, error) {
+	erasureRequest := models.ErasureRequest{AccountID: accountID}
+	code, err := erasureRequest.GetLatestByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return erasureRequest, code, err
+		}
+		return erasureRequest, http.StatusNotFound, fmt.Errorf("no erasure request found")
+	}
+	return erasureRequest, http.StatusOK, nil
+}
+
+// StartErasureWorker carries out the erasure requests whose cooling-off period is over
+func StartErasureWorker(logger *utility.Logger, db postgresql.Databases) {
+	interval := time.Duration(config.GetConfig().Erasure.Interval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := ProcessDueErasures(logger, db)
+		if err != nil {
+			logger.Error("erasure worker", err.Error())
+		}
+	}
+}
+
+// ProcessDueErasures erases every account whose cooling-off period is over, an account that fails is logged and
+// retried on the next run without holding back the others
+func ProcessDueErasures(logger *utility.Logger, db postgresql.Databases) error {
+	due, err := (&models.ErasureRequest{}).GetDue(db.Auth)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, erasureRequest := range due {
+		err = completeErasure(db, erasureRequest)
+		if err != nil {
+			failed++
+			logger.Error("erasure worker", fmt.Sprintf("erasing account %v: %v", erasureRequest.AccountID, err.Error()))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v due erasures failed", failed, len(due))
+	}
+	return nil
+}
+
+func completeErasure(db postgresql.Databases, erasureRequest models.ErasureRequest) error {
+	err := EraseAccount(db, erasureRequest.AccountID)
+	if err != nil {
+		return err
+	}
+
+	completedAt := time.Now()
+	erasureRequest.Status = models.ErasureStatusCompleted
+	erasureRequest.CompletedAt = &completedAt
+	return erasureRequest.Update(db.Auth)
+}
+
+// EraseAccount anonymises the personal data of the account. The user row and the wallet balances, histories and
+// transactions are kept, financial regulations require the ledger to be retained, but nothing in them identifies
+// the owner anymore. Everything else held about the account is deleted.
+func EraseAccount(db postgresql.Databases, accountID int) error {
+	err := RevokeAllAccountSessions(db, accountID)
+	if err != nil {
+		return err
+	}
+
+	return db.Auth.Transaction(func(tx *gorm.DB) error {
+		user := models.User{AccountID: uint(accountID)}
+		_, err := user.GetUserByAccountID(tx)
+		if err != nil {
+			return err
+		}
+
+		// the lockout counters of the ip addresses the account logged in from go too, they are found through the
+		// login tracking before it is deleted below
+		trackings, err := (&models.UserTracking{AccountID: accountID}).GetAllByAccountID(tx)
+		if err != nil {
+			return err
+		}
+		keys := []string{models.LoginAttemptAccountKey(accountID)}
+		for _, tracking := range trackings {
+			if tracking.IpAddress != "" {
+				keys = append(keys, models.LoginAttemptIpKey(tracking.IpAddress))
+			}
+		}
+		err = (&models.LoginAttempt{}).DeleteByKeys(tx, keys)
+		if err != nil {
+			return err
+		}
+
+		err = (&models.AuditLog{AccountID: accountID}).AnonymiseIpAddresses(tx)
+		if err != nil {
+			return err
+		}
+		anonymiseUser(&user)
+		err = user.Update(tx)
+		if err != nil {
+			return err
+		}
+
+		_, err = postgresql.UpdateFieldsWhere(tx, &models.UserProfile{}, map[string]interface{}{
+			"address": "", "state": "", "city": "", "dob": "", "ip_address": "", "sex": "", "profession": "", "age": 0, "bio": "",
+		}, "account_id = ?", accountID)
+		if err != nil {
+			return err
+		}
+
+		_, err = postgresql.UpdateFieldsWhere(tx, &models.BusinessProfile{}, map[string]interface{}{
+			"business_name": "erased business", "logo_uri": "", "website": "", "business_address": "", "state": "", "city": "",
+			"webhook_uri": "", "redirect_url": "", "bio": "", "business_email": "",
+		}, "account_id = ?", accountID)
+		if err != nil {
+			return err
+		}
+
+		_, err = postgresql.UpdateFieldsWhere(tx, &models.AccessToken{}, map[string]interface{}{"is_live": false, "revoked_at": time.Now()}, "account_id = ? and revoked_at is null", accountID)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range []interface{}{
+			&models.Authorize{},
+			&models.BankDetail{},
+			&models.ContactChange{},
+			&models.MagicLink{},
+			&models.OauthAuthorizationCode{},
+			&models.OauthClient{},
+			&models.OauthConsent{},
+			&models.OtpVerification{},
+			&models.PasswordHistory{},
+			&models.PasswordResetToken{},
+			&models.RefreshToken{},
+			&models.Session{},
+			&models.UserTotp{},
+			&models.UserTracking{},
+			&models.UsersCredential{},
+			&models.WebauthnChallenge{},
+			&models.WebauthnCredential{},
+		} {
+			err = postgresql.DeleteRecordFromDb(tx.Where("account_id = ?", accountID), record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// anonymiseUser replaces the identifying fields, the placeholders stay unique so signup validation still works
+func anonymiseUser(user *models.User) {
+	user.Firstname = "erased"
+	user.Lastname = "user"
+	user.Middlename = ""
+	user.EmailAddress = fmt.Sprintf("erased-%v@erased.invalid", user.AccountID)
+	user.PhoneNumber = ""
+	user.Username = fmt.Sprintf("erased_%v", user.AccountID)
+	user.Password = ""
+	user.LoginAccessToken = ""
+	user.LoginAccessTokenExpiresIn = ""
+	user.Meta = ""
+	user.ThePeerReference = ""
+}
+
+func erasureCoolingOff() time.Duration {
+	days := config.GetConfig().Erasure.CoolingOffDays
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
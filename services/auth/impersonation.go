@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+)
+
+// ImpersonateService mints a read-only access token for the account on behalf of an admin, the customer sees the
+// reason in their audit log
+func ImpersonateService(c *gin.Context, db postgresql.Databases, actorAccountID int, req models.ImpersonateReq) (gin.H, int, error) {
+	if req.AccountID == actorAccountID {
+		return nil, http.StatusBadRequest, fmt.Errorf("you cannot impersonate your own account")
+	}
+
+	user := models.User{AccountID: uint(req.AccountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return nil, code, err
+		}
+		return nil, http.StatusNotFound, fmt.Errorf("user not found")
+	}
+
+	if user.AccountType == "admin" {
+		return nil, http.StatusForbidden, fmt.Errorf("admin accounts cannot be impersonated")
+	}
+
+	token, err := middleware.CreateImpersonationToken(user, actorAccountID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error creating token: %v", err)
+	}
+
+	auditLog := models.AuditLog{
+		AccountID:      req.AccountID,
+		ActorAccountID: actorAccountID,
+		Action:         models.AuditActionImpersonationStarted,
+		Detail:         req.Reason,
+		Jti:            token.AccessUuid,
+		IpAddress:      c.ClientIP(),
+	}
+	err = auditLog.CreateAuditLog(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return gin.H{
+		"access_token":     token.AccessToken,
+		"expires_at":       token.AtExpiresTime,
+		"account_id":       req.AccountID,
+		"actor_account_id": actorAccountID,
+	}, http.StatusOK, nil
+}
+
+func ListAuditLogsService(db postgresql.Databases, accountID int) ([]models.AuditLog, int, error) {
+	auditLog := models.AuditLog{AccountID: accountID}
+	logs, err := auditLog.GetAllByAccountID(db.Auth)
+	if err != nil {
+		return logs, http.StatusInternalServerError, err
+	}
+	return logs, http.StatusOK, nil
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/external/microservice/notification"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// checkLoginAllowed refuses a login attempt while the client ip or the account is locked out, or while the
+// account is still inside the back-off delay of its last failure
+func checkLoginAllowed(c *gin.Context, db postgresql.Databases, accountID int) (int, error) {
+	code, err := checkIpLoginAllowed(c, db)
+	if err != nil {
+		return code, err
+	}
+	return checkAccountLoginAllowed(c, db, accountID)
+}
+
+// checkIpLoginAllowed refuses a login attempt while the client ip is locked out. It does not depend on the account
+// so it can run before the user is looked up, known and unknown usernames then get the same answer.
+func checkIpLoginAllowed(c *gin.Context, db postgresql.Databases) (int, error) {
+	ipAttempt := models.LoginAttempt{Key: models.LoginAttemptIpKey(c.ClientIP())}
+	code, err := ipAttempt.GetByKey(db.Auth)
+	if err != nil && code == http.StatusInternalServerError {
+		return code, err
+	}
+	if err == nil && ipAttempt.IsLocked() {
+		setRetryAfter(c, *ipAttempt.LockedUntil)
+		return http.StatusTooManyRequests, fmt.Errorf("too many failed login attempts from this address, try again later")
+	}
+	return http.StatusOK, nil
+}
+
+func checkAccountLoginAllowed(c *gin.Context, db postgresql.Databases, accountID int) (int, error) {
+	accountAttempt := models.LoginAttempt{Key: models.LoginAttemptAccountKey(accountID)}
+	code, err := accountAttempt.GetByKey(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusOK, nil
+	}
+
+	if accountAttempt.IsLocked() {
+		setRetryAfter(c, *accountAttempt.LockedUntil)
+		return http.StatusLocked, fmt.Errorf("account temporarily locked after too many failed login attempts")
+	}
+
+	if accountAttempt.Failures > 0 {
+		retryAt := accountAttempt.LastFailedAt.Add(loginBackoffDelay(accountAttempt.Failures))
+		if time.Now().Before(retryAt) {
+			setRetryAfter(c, retryAt)
+			return http.StatusTooManyRequests, fmt.Errorf("too many failed login attempts, try again in %v seconds", retryAfterSeconds(retryAt))
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// recordLoginFailure counts a failed password or otp attempt against the client ip and, when known, the account.
+// The user is notified when their account gets locked.
+func recordLoginFailure(c *gin.Context, logger *utility.Logger, db postgresql.Databases, accountID int) error {
+	var (
+		lockout   = config.GetConfig().Lockout
+		ipAddress = c.ClientIP()
+	)
+
+	_, _, err := registerLoginFailure(db, models.LoginAttemptIpKey(ipAddress), lockout.MaxIpAttempts)
+	if err != nil {
+		return err
+	}
+
+	if accountID == 0 {
+		return nil
+	}
+
+	attempt, locked, err := registerLoginFailure(db, models.LoginAttemptAccountKey(accountID), lockout.MaxAccountAttempts)
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		logger.Info("account locked after failed logins", accountID, ipAddress)
+		notification.SendAccountLockedNotification(logger, db.Auth, accountID, ipAddress, *attempt.LockedUntil)
+	}
+	return nil
+}
+
+// clearLoginFailures resets the account counter after a successful login, the ip counter is left to expire
+// so one valid account cannot be used to reset credential stuffing from the same address
+func clearLoginFailures(db postgresql.Databases, accountID int) error {
+	attempt := models.LoginAttempt{Key: models.LoginAttemptAccountKey(accountID)}
+	return attempt.DeleteByKey(db.Auth)
+}
+
+func UnlockAccountService(db postgresql.Databases, req models.UnlockAccountReq) (int, error) {
+	user := models.User{AccountID: uint(req.AccountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return code, err
+	}
+
+	err = clearLoginFailures(db, req.AccountID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if req.IpAddress != "" {
+		ipAttempt := models.LoginAttempt{Key: models.LoginAttemptIpKey(req.IpAddress)}
+		err = ipAttempt.DeleteByKey(db.Auth)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// registerLoginFailure increments the counter for key, starting over when the last failure is older than the
+// attempt window, and locks it once maxAttempts is reached. locked is only true for the failure that caused the lock.
+// Both steps are single statements so concurrent failures cannot overwrite each other's counts.
+func registerLoginFailure(db postgresql.Databases, key string, maxAttempts int) (models.LoginAttempt, bool, error) {
+	now := time.Now()
+	attempt := models.LoginAttempt{Key: key, LastFailedAt: now}
+	err := attempt.RegisterFailure(db.Auth, now.Add(-loginAttemptWindow()))
+	if err != nil {
+		return attempt, false, err
+	}
+
+	if maxAttempts <= 0 || attempt.Failures < maxAttempts || attempt.IsLocked() {
+		return attempt, false, nil
+	}
+	locked, err := attempt.Lock(db.Auth, maxAttempts, now.Add(lockoutDuration()))
+	return attempt, locked, err
+}
+
+// loginAttemptWindow is how long failures keep counting towards a lockout, without it every failure would start
+// the count over and nothing would ever lock
+func loginAttemptWindow() time.Duration {
+	window := time.Duration(config.GetConfig().Lockout.AttemptWindow) * time.Minute
+	if window <= 0 {
+		window = 15 * time.Minute
+	}
+	return window
+}
+
+func lockoutDuration() time.Duration {
+	duration := time.Duration(config.GetConfig().Lockout.Duration) * time.Minute
+	if duration <= 0 {
+		duration = 30 * time.Minute
+	}
+	return duration
+}
+
+// loginBackoffDelay doubles the wait after every consecutive failure, starting at the base delay
+func loginBackoffDelay(failures int) time.Duration {
+	lockout := config.GetConfig().Lockout
+	if lockout.BaseDelay <= 0 || failures <= 0 {
+		return 0
+	}
+
+	delay := float64(lockout.BaseDelay) * math.Pow(2, float64(failures-1))
+	if lockout.MaxDelay > 0 && delay > float64(lockout.MaxDelay) {
+		delay = float64(lockout.MaxDelay)
+	}
+	return time.Duration(delay) * time.Second
+}
+
+func setRetryAfter(c *gin.Context, retryAt time.Time) {
+	c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(retryAt)))
+}
+
+func retryAfterSeconds(retryAt time.Time) int {
+	return int(math.Ceil(time.Until(retryAt).Seconds()))
+}
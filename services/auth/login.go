@@ -3,7 +3,6 @@ package auth
 import (
 	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vesicash/auth-ms/external/microservice/verification"
@@ -22,10 +21,17 @@ func LoginService(c *gin.Context, logger *utility.Logger, req models.LoginUserRe
 	if req.EmailAddress == "" && req.PhoneNumber == "" && req.Username == "" {
 		return responseData, http.StatusBadRequest, fmt.Errorf("provide either username, email_address, or phone_number")
 	}
+
+	code, err := checkIpLoginAllowed(c, db)
+	if err != nil {
+		return responseData, code, err
+	}
+
 	user := models.User{Username: req.Username, EmailAddress: req.EmailAddress, PhoneNumber: req.PhoneNumber}
-	code, err := user.GetUserByUsernameEmailOrPhone(db.Auth)
+	code, err = user.GetUserByUsernameEmailOrPhone(db.Auth)
 	if err != nil {
 		if code == http.StatusBadRequest {
+			recordLoginFailure(c, logger, db, 0)
 			return responseData, code, fmt.Errorf("invalid login details")
 		}
 		return responseData, code, err
@@ -41,13 +47,46 @@ func LoginService(c *gin.Context, logger *utility.Logger, req models.LoginUserRe
 		return responseData, http.StatusBadRequest, fmt.Errorf("this account has been banned")
 	}
 
+	code, err = checkAccountLoginAllowed(c, db, int(user.AccountID))
+	if err != nil {
+		return responseData, code, err
+	}
+
 	if !utility.CompareHash(req.Password, user.Password) {
+		err = recordLoginFailure(c, logger, db, int(user.AccountID))
+		if err != nil {
+			return responseData, http.StatusInternalServerError, err
+		}
 		return responseData, http.StatusBadRequest, fmt.Errorf("invalid login details")
 	}
 
+	if utility.NeedsRehash(user.Password) {
+		rehashPassword(logger, db, &user, req.Password)
+	}
+
+	err = clearLoginFailures(db, int(user.AccountID))
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	code, err = requireDeviceAuthorization(c, logger, db, user)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	userTotp := models.UserTotp{AccountID: int(user.AccountID)}
+	mfaEnabled, err := userTotp.IsEnabledForAccount(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	if mfaEnabled {
+		return mfaChallengeResponse(user)
+	}
+
 	TrackUserLogin(c, logger, db, int(user.AccountID))
 
-	return LoginResponse(logger, user, db, req)
+	return LoginResponse(c, logger, user, db, req)
 }
 
 func PhoneOtpLogin(c *gin.Context, logger *utility.Logger, phoneNumber string, db postgresql.Databases) (int, int, error) {
@@ -75,8 +114,11 @@ func PhoneOtpLogin(c *gin.Context, logger *utility.Logger, phoneNumber string, d
 		return accountID, http.StatusBadRequest, fmt.Errorf("this account has been banned")
 	}
 
-	otpReq := models.SendOtpTokenReq{AccountID: int(user.AccountID)}
-	SendOtpService(logger, otpReq, db)
+	otpReq := models.SendOtpTokenReq{AccountID: int(user.AccountID), Purpose: models.OtpPurposeLogin}
+	code, err = SendOtpService(logger, otpReq, db)
+	if err != nil {
+		return accountID, code, err
+	}
 
 	TrackUserLogin(c, logger, db, int(user.AccountID))
 	return accountID, http.StatusOK, nil
@@ -119,7 +161,7 @@ func TrackUserLogin(c *gin.Context, logger *utility.Logger, db postgresql.Databa
 	return nil
 }
 
-func LoginResponse(logger *utility.Logger, user models.User, db postgresql.Databases, req models.LoginUserRequestModel) (map[string]interface{}, int, error) {
+func LoginResponse(c *gin.Context, logger *utility.Logger, user models.User, db postgresql.Databases, req models.LoginUserRequestModel) (map[string]interface{}, int, error) {
 	var (
 		responseData = gin.H{}
 	)
@@ -129,14 +171,17 @@ func LoginResponse(logger *utility.Logger, user models.User, db postgresql.Datab
 		return responseData, http.StatusInternalServerError, fmt.Errorf("error creating token: " + err.Error())
 	}
 
-	user.LoginAccessToken = token.AccessToken
-	user.LoginAccessTokenExpiresIn = strconv.Itoa(int(token.AtExpiresTime.Unix()))
-	err = user.Update(db.Auth)
+	refreshToken, refreshTokenRecord, err := IssueRefreshToken(db, int(user.AccountID), "", token.AccessUuid)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, fmt.Errorf("error creating refresh token: " + err.Error())
+	}
+
+	_, err = CreateSession(c, db, int(user.AccountID), token, refreshTokenRecord)
 	if err != nil {
-		return responseData, http.StatusInternalServerError, fmt.Errorf("error saving token: " + err.Error())
+		return responseData, http.StatusInternalServerError, fmt.Errorf("error creating session: " + err.Error())
 	}
 
-	verifications, _ := verification.GetVerifications(logger, db.Auth, int(user.AccountID), user.LoginAccessToken)
+	verifications, _ := verification.GetVerifications(logger, db.Auth, int(user.AccountID), token.AccessToken)
 
 	tracking := models.UserTracking{AccountID: int(user.AccountID)}
 	trackings, err := tracking.GetAllByAccountID(db.Auth)
@@ -177,7 +222,7 @@ func LoginResponse(logger *utility.Logger, user models.User, db postgresql.Datab
 	}
 
 	if req.PhoneNumber != "" {
-		otpReq := models.SendOtpTokenReq{AccountID: int(user.AccountID)}
+		otpReq := models.SendOtpTokenReq{AccountID: int(user.AccountID), Purpose: models.OtpPurposeLogin}
 		SendOtpService(logger, otpReq, db)
 	}
 	permission := []string{}
@@ -186,12 +231,14 @@ func LoginResponse(logger *utility.Logger, user models.User, db postgresql.Datab
 	}
 
 	return gin.H{
-		"token_type":   "auth",
-		"expires_in":   token.AtExpiresTime,
-		"access_token": token.AccessToken,
-		"user":         user,
-		"login_count":  trackingCount,
-		"permission":   permission,
+		"token_type":               "auth",
+		"expires_in":               token.AtExpiresTime,
+		"access_token":             token.AccessToken,
+		"refresh_token":            refreshToken,
+		"refresh_token_expires_in": refreshTokenRecord.ExpiresAt,
+		"user":                     user,
+		"login_count":              trackingCount,
+		"permission":               permission,
 		"profile": gin.H{
 			"business":         businessProfile,
 			"user":             userProfile,
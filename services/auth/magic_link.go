@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/external/microservice/notification"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// RequestMagicLinkService emails a login link to the account with the email address. Unknown and banned
+// accounts, and accounts over the hourly send limit, are skipped silently so the response never reveals whether
+// an email address is registered.
+func RequestMagicLinkService(c *gin.Context, logger *utility.Logger, db postgresql.Databases, req models.RequestMagicLinkReq) (int, error) {
+	if config.GetConfig().MagicLink.LinkUrl == "" {
+		return http.StatusInternalServerError, fmt.Errorf("magic link login is not configured")
+	}
+
+	user := models.User{EmailAddress: req.EmailAddress}
+	code, err := user.GetUserByEmailAddress(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusOK, nil
+	}
+
+	bannedAccount := models.BannedAccount{AccountID: int(user.AccountID)}
+	status, err := bannedAccount.CheckByAccountID(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if status {
+		return http.StatusOK, nil
+	}
+
+	now := time.Now()
+	magicLink := models.MagicLink{AccountID: int(user.AccountID)}
+	err = magicLink.DeleteSpentBefore(db.Auth, now.Add(-time.Hour))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	sent, err := magicLink.CountSentSince(db.Auth, now.Add(-time.Hour))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if sent >= int64(magicLinkMaxSendsPerHour()) {
+		logger.Info("magic link send limit reached", user.AccountID)
+		return http.StatusOK, nil
+	}
+
+	err = magicLink.InvalidateAllByAccountID(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	token, err := utility.GenerateSecureToken(32)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	magicLink = models.MagicLink{
+		AccountID: int(user.AccountID),
+		TokenHash: utility.HashToken(token),
+		IpAddress: c.ClientIP(),
+		ExpiresAt: now.Add(magicLinkValidFor()),
+	}
+	err = magicLink.CreateMagicLink(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// sent in the background so the response time does not depend on whether the account exists
+	go notification.SendMagicLink(logger, db.Auth, int(user.AccountID), magicLinkUrl(token), magicLink.ExpiresAt)
+
+	return http.StatusOK, nil
+}
+
+// ConsumeMagicLinkService logs the user in with a magic link token, the link can only be used once
+func ConsumeMagicLinkService(c *gin.Context, logger *utility.Logger, db postgresql.Databases, req models.ConsumeMagicLinkReq) (map[string]interface{}, int, error) {
+	var (
+		responseData = gin.H{}
+	)
+
+	magicLink := models.MagicLink{TokenHash: utility.HashToken(req.Token)}
+	code, err := magicLink.GetByTokenHash(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return responseData, code, err
+		}
+		return responseData, http.StatusBadRequest, fmt.Errorf("invalid or expired login link")
+	}
+
+	if !magicLink.IsUsable() {
+		return responseData, http.StatusBadRequest, fmt.Errorf("invalid or expired login link")
+	}
+
+	used, err := magicLink.MarkUsed(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+	if !used {
+		return responseData, http.StatusBadRequest, fmt.Errorf("invalid or expired login link")
+	}
+
+	user := models.User{AccountID: uint(magicLink.AccountID)}
+	code, err = user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	bannedAccount := models.BannedAccount{AccountID: int(user.AccountID)}
+	status, err := bannedAccount.CheckByAccountID(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	if status {
+		return responseData, http.StatusBadRequest, fmt.Errorf("this account has been banned")
+	}
+
+	code, err = checkLoginAllowed(c, db, int(user.AccountID))
+	if err != nil {
+		return responseData, code, err
+	}
+
+	code, err = requireDeviceAuthorization(c, logger, db, user)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	userTotp := models.UserTotp{AccountID: int(user.AccountID)}
+	mfaEnabled, err := userTotp.IsEnabledForAccount(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	if mfaEnabled {
+		return mfaChallengeResponse(user)
+	}
+
+	TrackUserLogin(c, logger, db, int(user.AccountID))
+
+	return LoginResponse(c, logger, user, db, models.LoginUserRequestModel{})
+}
+
+func magicLinkValidFor() time.Duration {
+	validFor := time.Duration(config.GetConfig().MagicLink.ExpireDuration) * time.Minute
+	if validFor <= 0 {
+		validFor = 15 * time.Minute
+	}
+	return validFor
+}
+
+func magicLinkMaxSendsPerHour() int {
+	maxSends := config.GetConfig().MagicLink.MaxSendsPerHour
+	if maxSends <= 0 {
+		maxSends = 5
+	}
+	return maxSends
+}
+
+func magicLinkUrl(token string) string {
+	return fmt.Sprintf("%v?%v", config.GetConfig().MagicLink.LinkUrl, url.Values{"token": {token}}.Encode())
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -8,12 +9,22 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/vesicash/auth-ms/external/microservice/notification"
+	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/internal/models"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
 	"github.com/vesicash/auth-ms/utility"
 )
 
 func SendOtpService(logger *utility.Logger, req models.SendOtpTokenReq, db postgresql.Databases) (int, error) {
+	var (
+		otpConfig = config.GetConfig().Otp
+		now       = time.Now()
+	)
+
+	if req.Purpose == "" {
+		req.Purpose = models.OtpPurposeLogin
+	}
+
 	user := models.User{AccountID: uint(req.AccountID)}
 	code, err := user.GetUserByAccountID(db.Auth)
 	if err != nil {
@@ -21,22 +32,41 @@ func SendOtpService(logger *utility.Logger, req models.SendOtpTokenReq, db postg
 	}
 
 	otp := models.OtpVerification{AccountID: req.AccountID}
-	code, err = otp.GetLatestByAccountID(db.Auth)
+	err = otp.DeleteSpentBefore(db.Auth, now.Add(-time.Hour))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	otp = models.OtpVerification{AccountID: req.AccountID, Purpose: req.Purpose}
+	code, err = otp.GetLatestByAccountIDAndPurpose(db.Auth)
 	if err != nil {
 		if code == http.StatusInternalServerError {
 			return code, err
 		}
-	} else {
-		err := otp.Delete(db.Auth)
+	} else if otpConfig.ResendCooldown > 0 {
+		resendAt := otp.CreatedAt.Add(time.Duration(otpConfig.ResendCooldown) * time.Second)
+		if now.Before(resendAt) {
+			return http.StatusTooManyRequests, fmt.Errorf("otp already sent, request a new one in %v seconds", retryAfterSeconds(resendAt))
+		}
+	}
+
+	if otpConfig.MaxSendsPerHour > 0 {
+		otp = models.OtpVerification{AccountID: req.AccountID, Purpose: req.Purpose, Authenticated: req.Authenticated}
+		sent, err := otp.CountSentSince(db.Auth, now.Add(-time.Hour))
 		if err != nil {
 			return http.StatusInternalServerError, err
 		}
+		if sent >= int64(otpConfig.MaxSendsPerHour) {
+			return http.StatusTooManyRequests, fmt.Errorf("too many otp requests, try again later")
+		}
 	}
 
 	token := utility.GetRandomNumbersInRange(100000, 999999)
 	otp = models.OtpVerification{
-		AccountID: req.AccountID,
-		OtpToken:  strconv.Itoa(token),
+		AccountID:     req.AccountID,
+		Purpose:       req.Purpose,
+		Authenticated: req.Authenticated,
+		TokenHash:     models.HashOtp(req.AccountID, req.Purpose, strconv.Itoa(token)),
 	}
 	err = otp.Create(db.Auth)
 	if err != nil {
@@ -48,23 +78,63 @@ func SendOtpService(logger *utility.Logger, req models.SendOtpTokenReq, db postg
 	return http.StatusOK, nil
 }
 
-func ValidateOtpService(c *gin.Context, logger *utility.Logger, otp string, accountID int, db postgresql.Databases) (interface{}, int, error) {
-	var response interface{}
-	otpVerification := models.OtpVerification{AccountID: accountID}
-	code, err := otpVerification.GetLatestByAccountID(db.Auth)
+// VerifyOtp checks code against the latest otp sent to the account for purpose and consumes it on success.
+// Every miss counts against the otp, which stops being accepted once the configured attempts are used up.
+func VerifyOtp(db postgresql.Databases, accountID int, purpose, code string) (int, error) {
+	otpConfig := config.GetConfig().Otp
+
+	otp := models.OtpVerification{AccountID: accountID, Purpose: purpose}
+	status, err := otp.GetLatestByAccountIDAndPurpose(db.Auth)
 	if err != nil {
-		if code == http.StatusInternalServerError {
-			return response, code, err
+		if status == http.StatusInternalServerError {
+			return status, err
 		}
-		return response, code, fmt.Errorf("invalid otp")
+		return http.StatusBadRequest, fmt.Errorf("invalid otp")
 	}
 
-	if otpVerification.OtpToken != otp {
-		return response, http.StatusBadRequest, fmt.Errorf("invalid token")
+	if !otp.IsUsable(otpConfig.MaxAttempts) {
+		return http.StatusBadRequest, fmt.Errorf("otp is no longer valid, request a new one")
 	}
 
-	if time.Now().After(otpVerification.ExpiresAt) {
-		return response, http.StatusBadRequest, fmt.Errorf("token expired")
+	if time.Now().After(otp.ExpiresAt) {
+		return http.StatusBadRequest, fmt.Errorf("token expired")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(otp.TokenHash), []byte(models.HashOtp(accountID, purpose, code))) != 1 {
+		err = otp.IncrementAttempts(db.Auth)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusBadRequest, fmt.Errorf("invalid token")
+	}
+
+	used, err := otp.MarkUsed(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !used {
+		return http.StatusBadRequest, fmt.Errorf("otp is no longer valid, request a new one")
+	}
+	return http.StatusOK, nil
+}
+
+func ValidateOtpService(c *gin.Context, logger *utility.Logger, otp string, accountID int, db postgresql.Databases) (interface{}, int, error) {
+	var response interface{}
+
+	code, err := checkLoginAllowed(c, db, accountID)
+	if err != nil {
+		return response, code, err
+	}
+
+	code, err = VerifyOtp(db, accountID, models.OtpPurposeLogin, otp)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return response, code, err
+		}
+		if err := recordLoginFailure(c, logger, db, accountID); err != nil {
+			return response, http.StatusInternalServerError, err
+		}
+		return response, code, err
 	}
 
 	bannedAccount := models.BannedAccount{AccountID: accountID}
@@ -77,13 +147,33 @@ func ValidateOtpService(c *gin.Context, logger *utility.Logger, otp string, acco
 		return response, http.StatusBadRequest, fmt.Errorf("this account has been banned")
 	}
 
-	TrackUserLogin(c, logger, db, accountID)
-
 	user := models.User{AccountID: uint(accountID)}
 	code, err = user.GetUserByAccountID(db.Auth)
 	if err != nil {
 		return response, code, err
 	}
 
-	return LoginResponse(logger, user, db, models.LoginUserRequestModel{})
+	userTotp := models.UserTotp{AccountID: accountID}
+	mfaEnabled, err := userTotp.IsEnabledForAccount(db.Auth)
+	if err != nil {
+		return response, http.StatusInternalServerError, err
+	}
+
+	err = clearLoginFailures(db, accountID)
+	if err != nil {
+		return response, http.StatusInternalServerError, err
+	}
+
+	code, err = requireDeviceAuthorization(c, logger, db, user)
+	if err != nil {
+		return response, code, err
+	}
+
+	if mfaEnabled {
+		return mfaChallengeResponse(user)
+	}
+
+	TrackUserLogin(c, logger, db, accountID)
+
+	return LoginResponse(c, logger, user, db, models.LoginUserRequestModel{})
 }
@@ -1,12 +1,15 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/vesicash/auth-ms/external/microservice/notification"
+	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/internal/models"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
 	"github.com/vesicash/auth-ms/utility"
@@ -23,13 +26,12 @@ func UpdatePassword(db postgresql.Databases, accountID int, oldPassword, newPass
 		return http.StatusBadRequest, fmt.Errorf("incorrect password")
 	}
 
-	password, err := utility.Hash(newPassword)
+	code, err = checkPasswordPolicy(db, user, newPassword)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return code, err
 	}
 
-	user.Password = password
-	err = user.Update(db.Auth)
+	err = setPassword(db, &user, newPassword)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -48,19 +50,13 @@ func RequestPasswordResetService(logger *utility.Logger, db postgresql.Databases
 		return 0, code, err
 	}
 
-	token := utility.GetRandomNumbersInRange(100000000, 999999999)
-	resetToken := models.PasswordResetToken{
-		AccountID: int(user.AccountID),
-		Token:     strconv.Itoa(token),
-		ExpiresAt: strconv.Itoa(int(time.Now().Add(30 * time.Minute).Unix())),
-	}
-	err = resetToken.CreatePasswordResetToken(db.Auth)
+	token, linkToken, err := IssuePasswordResetToken(db, int(user.AccountID), passwordResetValidFor())
 	if err != nil {
 		return 0, http.StatusInternalServerError, err
 	}
 
 	if email != "" {
-		notification.SendEmailPasswordReset(logger, db.Auth, int(user.AccountID), token)
+		notification.SendEmailPasswordReset(logger, db.Auth, int(user.AccountID), token, PasswordResetLink(linkToken))
 	}
 
 	if phoneNumber != "" {
@@ -71,51 +67,87 @@ func RequestPasswordResetService(logger *utility.Logger, db postgresql.Databases
 
 }
 
-func UpdatePasswordWithTokenService(logger *utility.Logger, db postgresql.Databases, accountID int, token int, password string) (int, error) {
-	if password == "" {
-		return http.StatusBadRequest, fmt.Errorf("password is empty")
+func passwordResetValidFor() time.Duration {
+	validFor := time.Duration(config.GetConfig().PasswordReset.ExpireDuration) * time.Minute
+	if validFor <= 0 {
+		validFor = 30 * time.Minute
 	}
+	return validFor
+}
 
-	if token == 0 {
-		return http.StatusBadRequest, fmt.Errorf("invalid token")
+// IssuePasswordResetToken invalidates every earlier reset token of the account and issues a new one, it returns
+// the numeric code and the url-safe link token, only their hashes are stored
+func IssuePasswordResetToken(db postgresql.Databases, accountID int, validFor time.Duration) (int, string, error) {
+	resetToken := models.PasswordResetToken{AccountID: accountID}
+	err := resetToken.DeleteAllByAccountID(db.Auth)
+	if err != nil {
+		return 0, "", err
 	}
 
-	user := models.User{AccountID: uint(accountID)}
-	code, err := user.GetUserByAccountID(db.Auth)
+	linkToken, err := utility.GenerateSecureToken(32)
 	if err != nil {
-		return code, err
+		return 0, "", err
 	}
 
-	resetToken := models.PasswordResetToken{AccountID: int(user.AccountID), Token: strconv.Itoa(token)}
-	code, err = resetToken.GetLatestByAccountIDAndToken(db.Auth)
+	token := utility.GetRandomNumbersInRange(100000000, 999999999)
+	resetToken = models.PasswordResetToken{
+		AccountID:     accountID,
+		CodeHash:      models.HashOtp(accountID, models.OtpPurposePasswordReset, strconv.Itoa(token)),
+		LinkTokenHash: utility.HashToken(linkToken),
+		ExpiresAt:     time.Now().Add(validFor),
+	}
+	err = resetToken.CreatePasswordResetToken(db.Auth)
 	if err != nil {
-		if code == http.StatusInternalServerError {
-			return code, err
-		}
-		return code, fmt.Errorf("invalid token")
+		return 0, "", err
+	}
+	return token, linkToken, nil
+}
+
+func PasswordResetLink(linkToken string) string {
+	linkUrl := config.GetConfig().PasswordReset.LinkUrl
+	if linkUrl == "" {
+		return ""
+	}
+	return fmt.Sprintf("%v?%v", linkUrl, url.Values{"token": {linkToken}}.Encode())
+}
+
+// UpdatePasswordWithTokenService resets the password with either the numeric code sent to accountID or the
+// link token, the token is single use and every session of the account is ended afterwards
+func UpdatePasswordWithTokenService(logger *utility.Logger, db postgresql.Databases, accountID int, token int, linkToken string, password string) (int, error) {
+	if password == "" {
+		return http.StatusBadRequest, fmt.Errorf("password is empty")
 	}
 
-	if resetToken.ExpiresAt == "" {
-		return http.StatusBadRequest, fmt.Errorf("invalid token")
+	resetToken, code, err := getPasswordResetToken(db, accountID, token, linkToken)
+	if err != nil {
+		return code, err
 	}
 
-	parseInt, err := strconv.Atoi(resetToken.ExpiresAt)
+	user := models.User{AccountID: uint(resetToken.AccountID)}
+	code, err = user.GetUserByAccountID(db.Auth)
 	if err != nil {
-		return http.StatusBadRequest, err
+		return code, err
 	}
 
-	unixTimeUTC := time.Unix(int64(parseInt), 0)
-	if time.Now().After(unixTimeUTC) {
-		return http.StatusBadRequest, fmt.Errorf("expired token")
+	code, err = checkPasswordPolicy(db, user, password)
+	if err != nil {
+		return code, err
 	}
 
-	newPassword, err := utility.Hash(password)
+	used, err := resetToken.MarkUsed(db.Auth)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	if !used {
+		return http.StatusBadRequest, fmt.Errorf("token is no longer valid, request a new one")
+	}
 
-	user.Password = newPassword
-	err = user.Update(db.Auth)
+	err = setPassword(db, &user, password)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = RevokeAllAccountSessions(db, int(user.AccountID))
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -123,9 +155,57 @@ func UpdatePasswordWithTokenService(logger *utility.Logger, db postgresql.Databa
 	notification.SendEmailPasswordDoneReset(logger, db.Auth, int(user.AccountID))
 	notification.SendPhonePasswordDoneReset(logger, db.Auth, int(user.AccountID))
 
-	err = resetToken.DeletePasswordResetToken(db.Auth)
+	err = resetToken.DeleteAllByAccountID(db.Auth)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 	return http.StatusOK, err
 }
+
+// getPasswordResetToken finds the reset token matching the link token or, without one, the numeric code of the
+// account. Wrong codes count against the token, which is invalidated once the configured attempts are used up.
+func getPasswordResetToken(db postgresql.Databases, accountID int, token int, linkToken string) (models.PasswordResetToken, int, error) {
+	var (
+		maxAttempts = config.GetConfig().PasswordReset.MaxAttempts
+		resetToken  models.PasswordResetToken
+		code        int
+		err         error
+	)
+
+	if linkToken != "" {
+		resetToken = models.PasswordResetToken{LinkTokenHash: utility.HashToken(linkToken)}
+		code, err = resetToken.GetByLinkTokenHash(db.Auth)
+	} else if accountID != 0 && token != 0 {
+		resetToken = models.PasswordResetToken{AccountID: accountID}
+		code, err = resetToken.GetLatestByAccountID(db.Auth)
+	} else {
+		return resetToken, http.StatusBadRequest, fmt.Errorf("invalid token")
+	}
+
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return resetToken, code, err
+		}
+		return resetToken, http.StatusBadRequest, fmt.Errorf("invalid token")
+	}
+
+	if !resetToken.IsUsable(maxAttempts) {
+		return resetToken, http.StatusBadRequest, fmt.Errorf("token is no longer valid, request a new one")
+	}
+
+	if time.Now().After(resetToken.ExpiresAt) {
+		return resetToken, http.StatusBadRequest, fmt.Errorf("expired token")
+	}
+
+	if linkToken == "" {
+		codeHash := models.HashOtp(accountID, models.OtpPurposePasswordReset, strconv.Itoa(token))
+		if subtle.ConstantTimeCompare([]byte(codeHash), []byte(resetToken.CodeHash)) != 1 {
+			err = resetToken.IncrementAttempts(db.Auth)
+			if err != nil {
+				return resetToken, http.StatusInternalServerError, err
+			}
+			return resetToken, http.StatusBadRequest, fmt.Errorf("invalid token")
+		}
+	}
+	return resetToken, http.StatusOK, nil
+}
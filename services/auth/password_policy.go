@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/passwordpolicy"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// checkPasswordPolicy validates a new password of user against the policy of their account type and, for existing
+// accounts, against the passwords they used recently. Violations are returned as a *passwordpolicy.Error.
+func checkPasswordPolicy(db postgresql.Databases, user models.User, password string) (int, error) {
+	var (
+		policy         = config.GetConfig().PasswordPolicy.ForAccountType(user.AccountType)
+		emailLocalPart = strings.Split(user.EmailAddress, "@")[0]
+	)
+
+	violations := passwordpolicy.Check(policy, password, user.EmailAddress, emailLocalPart, user.Username)
+
+	if policy.HistorySize > 0 && user.Password != "" {
+		reused, err := isRecentPassword(db, user, password, policy.HistorySize)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if reused {
+			violations = append(violations, passwordpolicy.Violation{
+				Rule:    passwordpolicy.RuleHistory,
+				Message: fmt.Sprintf("password must differ from your last %v passwords", policy.HistorySize),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return http.StatusBadRequest, &passwordpolicy.Error{Violations: violations}
+	}
+	return http.StatusOK, nil
+}
+
+// setPassword saves password for user and keeps their previous password in the password history
+func setPassword(db postgresql.Databases, user *models.User, password string) error {
+	policy := config.GetConfig().PasswordPolicy.ForAccountType(user.AccountType)
+
+	hash, err := utility.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	history := models.PasswordHistory{AccountID: int(user.AccountID), PasswordHash: user.Password}
+	if policy.HistorySize > 1 && user.Password != "" {
+		err = history.CreatePasswordHistory(db.Auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	keep := policy.HistorySize - 1
+	if keep < 0 {
+		keep = 0
+	}
+	err = history.DeleteAllButRecent(db.Auth, keep)
+	if err != nil {
+		return err
+	}
+
+	user.Password = hash
+	return user.Update(db.Auth)
+}
+
+// isRecentPassword compares password with the current password of user and the ones before it, historySize
+// counts the current password
+func isRecentPassword(db postgresql.Databases, user models.User, password string, historySize int) (bool, error) {
+	if utility.CompareHash(password, user.Password) {
+		return true, nil
+	}
+
+	history := models.PasswordHistory{AccountID: int(user.AccountID)}
+	recent, err := history.GetRecentByAccountID(db.Auth, historySize-1)
+	if err != nil {
+		return false, err
+	}
+
+	for _, h := range recent {
+		if utility.CompareHash(password, h.PasswordHash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rehashPassword upgrades the stored hash of a verified password to the configured algorithm and parameters,
+// a failure is only logged since the login itself succeeded
+func rehashPassword(logger *utility.Logger, db postgresql.Databases, user *models.User, password string) {
+	hash, err := utility.Hash(password)
+	if err != nil {
+		logger.Error("password rehash", user.AccountID, err)
+		return
+	}
+
+	_, err = postgresql.UpdateFieldsWhere(db.Auth, &models.User{}, map[string]interface{}{"password": hash}, "account_id = ?", user.AccountID)
+	if err != nil {
+		logger.Error("password rehash", user.AccountID, err)
+		return
+	}
+	user.Password = hash
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// IssueRefreshToken creates a new refresh token in familyID, a new family is started when familyID is empty.
+// The plain token is only returned here, the database keeps its hash.
+func IssueRefreshToken(db postgresql.Databases, accountID int, familyID, accessUuid string) (string, models.RefreshToken, error) {
+	var (
+		refreshTokenExpireDuration = config.GetConfig().Server.RefreshTokenExpireDuration
+	)
+
+	if refreshTokenExpireDuration == 0 {
+		refreshTokenExpireDuration = 720
+	}
+
+	if familyID == "" {
+		familyUuid, err := uuid.NewV4()
+		if err != nil {
+			return "", models.RefreshToken{}, err
+		}
+		familyID = familyUuid.String()
+	}
+
+	plainToken, err := utility.GenerateSecureToken(48)
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+
+	refreshToken := models.RefreshToken{
+		AccountID:  accountID,
+		FamilyID:   familyID,
+		TokenHash:  utility.HashToken(plainToken),
+		AccessUuid: accessUuid,
+		ExpiresAt:  time.Now().Add(time.Hour * time.Duration(refreshTokenExpireDuration)),
+	}
+	err = refreshToken.CreateRefreshToken(db.Auth)
+	if err != nil {
+		return "", refreshToken, err
+	}
+
+	return plainToken, refreshToken, nil
+}
+
+func RefreshTokenService(c *gin.Context, logger *utility.Logger, plainToken string, db postgresql.Databases) (map[string]interface{}, int, error) {
+	var (
+		responseData = gin.H{}
+	)
+
+	refreshToken := models.RefreshToken{TokenHash: utility.HashToken(plainToken)}
+	code, err := refreshToken.GetByTokenHash(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return responseData, code, err
+		}
+		return responseData, http.StatusUnauthorized, fmt.Errorf("invalid refresh token")
+	}
+
+	if refreshToken.Revoked {
+		return responseData, http.StatusUnauthorized, fmt.Errorf("refresh token has been revoked")
+	}
+
+	if refreshToken.Used {
+		return responseData, http.StatusUnauthorized, revokeReusedRefreshToken(logger, refreshToken, db)
+	}
+
+	if time.Now().After(refreshToken.ExpiresAt) {
+		return responseData, http.StatusUnauthorized, fmt.Errorf("refresh token expired")
+	}
+
+	claimed, err := refreshToken.MarkAsUsed(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	if !claimed {
+		return responseData, http.StatusUnauthorized, revokeReusedRefreshToken(logger, refreshToken, db)
+	}
+
+	user := models.User{AccountID: uint(refreshToken.AccountID)}
+	code, err = user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	bannedAccount := models.BannedAccount{AccountID: int(user.AccountID)}
+	status, err := bannedAccount.CheckByAccountID(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	if status {
+		refreshToken.RevokeFamily(db.Auth)
+		return responseData, http.StatusBadRequest, fmt.Errorf("this account has been banned")
+	}
+
+	session := models.Session{FamilyID: refreshToken.FamilyID}
+	code, err = session.GetByFamilyID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return responseData, code, err
+		}
+		return responseData, http.StatusUnauthorized, fmt.Errorf("session not found, please login again")
+	}
+
+	if session.Revoked {
+		refreshToken.RevokeFamily(db.Auth)
+		return responseData, http.StatusUnauthorized, fmt.Errorf("session has been revoked, please login again")
+	}
+
+	token, err := middleware.CreateToken(user, false)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, fmt.Errorf("error creating token: " + err.Error())
+	}
+
+	newPlainToken, newRefreshToken, err := IssueRefreshToken(db, int(user.AccountID), refreshToken.FamilyID, token.AccessUuid)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, fmt.Errorf("error creating refresh token: " + err.Error())
+	}
+
+	// the access token issued with the previous refresh token is replaced by the new one
+	err = middleware.DenyAccessToken(db, session.AccountID, session.AccessUuid)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, fmt.Errorf("error revoking previous token: " + err.Error())
+	}
+
+	session.AccessUuid = token.AccessUuid
+	session.IpAddress = c.ClientIP()
+	session.UserAgent = c.Request.UserAgent()
+	session.Device = utility.DeviceFromUserAgent(session.UserAgent)
+	session.LastSeenAt = time.Now()
+	session.ExpiresAt = newRefreshToken.ExpiresAt
+	err = session.Update(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, fmt.Errorf("error updating session: " + err.Error())
+	}
+
+	return gin.H{
+		"token_type":               "auth",
+		"expires_in":               token.AtExpiresTime,
+		"access_token":             token.AccessToken,
+		"refresh_token":            newPlainToken,
+		"refresh_token_expires_in": newRefreshToken.ExpiresAt,
+	}, http.StatusOK, nil
+}
+
+func revokeReusedRefreshToken(logger *utility.Logger, refreshToken models.RefreshToken, db postgresql.Databases) error {
+	logger.Info("refresh token reuse detected", refreshToken.AccountID, refreshToken.FamilyID)
+	err := refreshToken.RevokeFamily(db.Auth)
+	if err != nil {
+		return err
+	}
+
+	session := models.Session{FamilyID: refreshToken.FamilyID}
+	if _, err := session.GetByFamilyID(db.Auth); err == nil {
+		err = middleware.DenySessions(db, session)
+		if err != nil {
+			return err
+		}
+		err = session.Revoke(db.Auth)
+		if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("refresh token has already been used, please login again")
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+)
+
+// GetSandboxWalletBalanceService answers wallet reads made with test keys in the shape of
+// GetUserWalletBalanceService, without reading or creating live wallets
+func GetSandboxWalletBalanceService(db postgresql.Databases, accountID int) (interface{}, int, error) {
+	userProfile := models.UserProfile{AccountID: accountID}
+	code, err := userProfile.GetByAccountID(db.Auth)
+	if err != nil {
+		return nil, code, err
+	}
+
+	currency := strings.ToUpper(userProfile.Currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	return gin.H{
+		"balance":     0,
+		"currency":    currency,
+		"country":     nil,
+		"wallets":     []models.WalletBalance{},
+		"environment": models.ApiKeyEnvironmentTest,
+	}, http.StatusOK, nil
+}
+
+// GetSandboxDisbursementsService answers disbursement reads made with test keys, live disbursements are never
+// requested from the payment service for them
+func GetSandboxDisbursementsService() ([]map[string]interface{}, int, error) {
+	return []map[string]interface{}{}, http.StatusOK, nil
+}
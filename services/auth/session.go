@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// CreateSession records the device a token was issued to, the session lives as long as its refresh token family
+func CreateSession(c *gin.Context, db postgresql.Databases, accountID int, token *middleware.TokenDetailsDTO, refreshToken models.RefreshToken) (models.Session, error) {
+	var (
+		userAgent = c.Request.UserAgent()
+	)
+
+	session := models.Session{
+		AccountID:  accountID,
+		AccessUuid: token.AccessUuid,
+		FamilyID:   refreshToken.FamilyID,
+		Device:     utility.DeviceFromUserAgent(userAgent),
+		IpAddress:  c.ClientIP(),
+		UserAgent:  userAgent,
+		LastSeenAt: time.Now(),
+		ExpiresAt:  refreshToken.ExpiresAt,
+	}
+	err := session.CreateSession(db.Auth)
+	if err != nil {
+		return session, err
+	}
+	return session, nil
+}
+
+func ListSessionsService(db postgresql.Databases, accountID int, currentAccessUuid string) ([]models.Session, int, error) {
+	session := models.Session{AccountID: accountID}
+	sessions, err := session.GetActiveByAccountID(db.Auth)
+	if err != nil {
+		return sessions, http.StatusInternalServerError, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].AccessUuid == currentAccessUuid
+	}
+	return sessions, http.StatusOK, nil
+}
+
+func RevokeSessionService(db postgresql.Databases, accountID int, sessionID uint) (int, error) {
+	session := models.Session{ID: sessionID, AccountID: accountID}
+	code, err := session.GetByIDAndAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusNotFound, fmt.Errorf("session not found")
+	}
+
+	if !session.IsActive() {
+		return http.StatusBadRequest, fmt.Errorf("session is no longer active")
+	}
+
+	err = middleware.RevokeSession(db, session)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// RevokeAllSessionsService signs the account out of every device except the session making the request
+func RevokeAllSessionsService(db postgresql.Databases, accountID int, currentAccessUuid string) (int, error) {
+	session := models.Session{AccountID: accountID}
+	sessions, err := session.GetActiveByAccountID(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	for _, s := range sessions {
+		if s.AccessUuid == currentAccessUuid {
+			continue
+		}
+		err := middleware.RevokeSession(db, s)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// RevokeSessionByAccessUuid ends the session an access token belongs to along with its refresh tokens
+func RevokeSessionByAccessUuid(db postgresql.Databases, accessUuid string) (int, error) {
+	if accessUuid == "" {
+		return http.StatusOK, nil
+	}
+
+	session := models.Session{AccessUuid: accessUuid}
+	code, err := session.GetByAccessUuid(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusOK, nil
+	}
+
+	err = middleware.RevokeSession(db, session)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// RevokeAllAccountSessions ends every session and refresh token of the account
+func RevokeAllAccountSessions(db postgresql.Databases, accountID int) error {
+	session := models.Session{AccountID: accountID}
+	sessions, err := session.GetActiveByAccountID(db.Auth)
+	if err != nil {
+		return err
+	}
+	err = middleware.DenySessions(db, sessions...)
+	if err != nil {
+		return err
+	}
+
+	err = session.RevokeAllByAccountID(db.Auth, "")
+	if err != nil {
+		return err
+	}
+	refreshToken := models.RefreshToken{AccountID: accountID}
+	return refreshToken.RevokeAllByAccountID(db.Auth)
+}
+
+// ForceLogoutService lets an admin sign an account out of every device
+func ForceLogoutService(db postgresql.Databases, req models.ForceLogoutReq) (int, error) {
+	user := models.User{AccountID: uint(req.AccountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusNotFound, fmt.Errorf("user not found")
+	}
+
+	err = RevokeAllAccountSessions(db, req.AccountID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vesicash/auth-ms/external/microservice/notification"
 	"github.com/vesicash/auth-ms/external/microservice/referral"
@@ -83,7 +84,21 @@ func SignupService(logger *utility.Logger, req models.CreateUserRequestModel, db
 	}
 	fmt.Println("ssss2")
 
-	password, err = utility.Hash(req.Password)
+	if password == "" {
+		// users created without a password set one through the welcome password reset, until then the
+		// account gets a random password nobody knows
+		password, err = utility.GenerateSecureToken(32)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	} else {
+		code, err = checkPasswordPolicy(db, models.User{AccountType: accountType, EmailAddress: emailAddress, Username: username}, password)
+		if err != nil {
+			return nil, code, err
+		}
+	}
+
+	password, err = utility.Hash(password)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
@@ -174,7 +189,11 @@ func SignupService(logger *utility.Logger, req models.CreateUserRequestModel, db
 		notification.SendWelcomeNotification(logger, db.Auth, int(user.AccountID))
 
 		if req.Password == "" {
-			notification.SendWelcomePasswordReset(logger, db.Auth, int(user.AccountID), utility.GetRandomNumbersInRange(100000000, 999999999))
+			token, linkToken, err := IssuePasswordResetToken(db, int(user.AccountID), 48*time.Hour)
+			if err != nil {
+				return nil, http.StatusInternalServerError, err
+			}
+			notification.SendWelcomePasswordReset(logger, db.Auth, int(user.AccountID), token, PasswordResetLink(linkToken))
 		}
 
 		verification.SendVerificationEmail(logger, db.Auth, int(user.AccountID))
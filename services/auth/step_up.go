@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// StepUpService re-authenticates the caller with their password, a step_up otp or an authenticator code and
+// marks the session as elevated for the routes guarded by middleware.RequireStepUp
+func StepUpService(c *gin.Context, logger *utility.Logger, db postgresql.Databases, accountID int, accessUuid string, req models.StepUpReq) (gin.H, int, error) {
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return nil, code, err
+	}
+
+	session := models.Session{AccessUuid: accessUuid}
+	code, err = session.GetByAccessUuid(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return nil, code, err
+		}
+		return nil, http.StatusUnauthorized, fmt.Errorf("session not found, please login again")
+	}
+
+	code, err = checkLoginAllowed(c, db, accountID)
+	if err != nil {
+		return nil, code, err
+	}
+
+	switch req.Method {
+	case middleware.StepUpMethodPassword:
+		if !utility.CompareHash(req.Password, user.Password) {
+			code, err = http.StatusBadRequest, fmt.Errorf("invalid password")
+		}
+	case middleware.StepUpMethodOtp:
+		code, err = VerifyOtp(db, accountID, models.OtpPurposeStepUp, req.Code)
+	case middleware.StepUpMethodTotp:
+		userTotp := models.UserTotp{AccountID: accountID}
+		enabled, totpErr := userTotp.IsEnabledForAccount(db.Auth)
+		if totpErr != nil {
+			return nil, http.StatusInternalServerError, totpErr
+		}
+		if !enabled {
+			return nil, http.StatusBadRequest, fmt.Errorf("authenticator app not enabled")
+		}
+		code, err = verifyTotpCode(db, &userTotp, req.Code)
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("unsupported step up method")
+	}
+
+	if err != nil {
+		if code != http.StatusInternalServerError {
+			recordLoginFailure(c, logger, db, accountID)
+		}
+		return nil, code, err
+	}
+
+	err = clearLoginFailures(db, accountID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	err = session.Elevate(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return gin.H{
+		"elevated_at":    session.ElevatedAt,
+		"elevated_until": session.ElevatedAt.Add(middleware.StepUpMaxAge()),
+	}, http.StatusOK, nil
+}
@@ -27,7 +27,9 @@ func UpgradeUserTierService(db postgresql.Databases, tier int, accountID int) (i
 	return http.StatusOK, nil
 }
 
-func GetUserRestrictionsService(logger *utility.Logger, db postgresql.Databases, accountID int) (map[string]interface{}, int, error) {
+// GetUserRestrictionsService checks the tier of the account, accessToken is the token of the caller and is passed
+// on to the verification service
+func GetUserRestrictionsService(logger *utility.Logger, db postgresql.Databases, accountID int, accessToken string) (map[string]interface{}, int, error) {
 	var (
 		tier         = 0
 		empty_fields = []string{}
@@ -44,7 +46,7 @@ func GetUserRestrictionsService(logger *utility.Logger, db postgresql.Databases,
 		return restrictions, code, err
 	}
 	tier = user.TierType
-	dataSlice, code, err := TierChecks(logger, tier, int(user.AccountID), db, user.LoginAccessToken)
+	dataSlice, code, err := TierChecks(logger, tier, int(user.AccountID), db, accessToken)
 	if err != nil {
 		return restrictions, code, err
 	}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+const totpIssuer = "Vesicash"
+
+func EnrollTotpService(db postgresql.Databases, accountID int) (map[string]interface{}, int, error) {
+	var (
+		responseData  = gin.H{}
+		encryptionKey = config.GetConfig().Server.EncryptionKey
+	)
+
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	userTotp := models.UserTotp{AccountID: accountID}
+	code, err = userTotp.GetByAccountID(db.Auth)
+	if err != nil && code == http.StatusInternalServerError {
+		return responseData, code, err
+	}
+	exists := err == nil
+
+	if exists && userTotp.Confirmed {
+		return responseData, http.StatusBadRequest, fmt.Errorf("authenticator app already enabled, disable it before enrolling again")
+	}
+
+	secret, err := utility.GenerateTotpSecret()
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	encryptedSecret, err := utility.Encrypt(secret, encryptionKey)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, fmt.Errorf("error encrypting secret: " + err.Error())
+	}
+
+	userTotp.Secret = encryptedSecret
+	userTotp.LastUsedStep = 0
+	if exists {
+		err = userTotp.Update(db.Auth)
+	} else {
+		err = userTotp.CreateUserTotp(db.Auth)
+	}
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	accountName := user.EmailAddress
+	if accountName == "" {
+		accountName = user.Username
+	}
+
+	return gin.H{
+		"secret": secret,
+		"uri":    utility.TotpURI(totpIssuer, accountName, secret),
+	}, http.StatusOK, nil
+}
+
+func ConfirmTotpService(db postgresql.Databases, accountID int, req models.ConfirmTotpReq) (int, error) {
+	userTotp := models.UserTotp{AccountID: accountID}
+	code, err := userTotp.GetByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusBadRequest, fmt.Errorf("authenticator app enrolment not started")
+	}
+
+	if userTotp.Confirmed {
+		return http.StatusBadRequest, fmt.Errorf("authenticator app already enabled")
+	}
+
+	code, err = verifyTotpCode(db, &userTotp, req.Code)
+	if err != nil {
+		return code, err
+	}
+
+	now := time.Now()
+	userTotp.Confirmed = true
+	userTotp.ConfirmedAt = &now
+	err = userTotp.Update(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// DisableTotpService removes the authenticator app, the caller must prove both their password and a current code
+func DisableTotpService(db postgresql.Databases, accountID int, req models.DisableTotpReq) (int, error) {
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return code, err
+	}
+
+	if !utility.CompareHash(req.Password, user.Password) {
+		return http.StatusBadRequest, fmt.Errorf("invalid password")
+	}
+
+	userTotp := models.UserTotp{AccountID: accountID}
+	enabled, err := userTotp.IsEnabledForAccount(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if !enabled {
+		return http.StatusBadRequest, fmt.Errorf("authenticator app not enabled")
+	}
+
+	code, err = verifyTotpCode(db, &userTotp, req.Code)
+	if err != nil {
+		return code, err
+	}
+
+	err = userTotp.Delete(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// LoginMfaService exchanges an mfa challenge token and a valid code for a session
+func LoginMfaService(c *gin.Context, logger *utility.Logger, req models.LoginMfaReq, db postgresql.Databases) (map[string]interface{}, int, error) {
+	var (
+		responseData = gin.H{}
+	)
+
+	accountID, err := middleware.ParseMfaToken(req.MfaToken)
+	if err != nil {
+		return responseData, http.StatusUnauthorized, err
+	}
+
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	bannedAccount := models.BannedAccount{AccountID: accountID}
+	status, err := bannedAccount.CheckByAccountID(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	if status {
+		return responseData, http.StatusBadRequest, fmt.Errorf("this account has been banned")
+	}
+
+	userTotp := models.UserTotp{AccountID: accountID}
+	enabled, err := userTotp.IsEnabledForAccount(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	if !enabled {
+		return responseData, http.StatusBadRequest, fmt.Errorf("authenticator app not enabled")
+	}
+
+	code, err = checkLoginAllowed(c, db, accountID)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	code, err = verifyTotpCode(db, &userTotp, req.Code)
+	if err != nil {
+		if code != http.StatusInternalServerError {
+			recordLoginFailure(c, logger, db, accountID)
+		}
+		return responseData, code, err
+	}
+
+	err = clearLoginFailures(db, accountID)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	TrackUserLogin(c, logger, db, accountID)
+
+	return LoginResponse(c, logger, user, db, models.LoginUserRequestModel{})
+}
+
+// mfaChallengeResponse is returned instead of a session when the account has an authenticator app enabled
+func mfaChallengeResponse(user models.User) (map[string]interface{}, int, error) {
+	mfaToken, expiresAt, err := middleware.CreateMfaToken(user)
+	if err != nil {
+		return gin.H{}, http.StatusInternalServerError, fmt.Errorf("error creating mfa token: " + err.Error())
+	}
+
+	return gin.H{
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+		"expires_in":   expiresAt,
+	}, http.StatusOK, nil
+}
+
+func verifyTotpCode(db postgresql.Databases, userTotp *models.UserTotp, code string) (int, error) {
+	secret, err := utility.Decrypt(userTotp.Secret, config.GetConfig().Server.EncryptionKey)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("error decrypting secret: " + err.Error())
+	}
+
+	step, ok := utility.ValidateTotp(secret, code, time.Now())
+	if !ok || step <= userTotp.LastUsedStep {
+		return http.StatusBadRequest, fmt.Errorf("invalid authenticator code")
+	}
+
+	userTotp.LastUsedStep = step
+	err = userTotp.Update(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
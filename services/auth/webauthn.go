@@ -0,0 +1,415 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+const webauthnChallengeExpireDuration = 5 * time.Minute
+
+// webauthnUser adapts a user and their stored credentials to the webauthn.User interface
+type webauthnUser struct {
+	user        models.User
+	credentials []models.WebauthnCredential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(int(u.user.AccountID)))
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	if u.user.EmailAddress != "" {
+		return u.user.EmailAddress
+	}
+	return u.user.Username
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	name := strings.TrimSpace(u.user.Firstname + " " + u.user.Lastname)
+	if name == "" {
+		return u.WebAuthnName()
+	}
+	return name
+}
+
+func (u *webauthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := []webauthn.Credential{}
+	for _, c := range u.credentials {
+		id, err := base64.RawURLEncoding.DecodeString(c.CredentialID)
+		if err != nil {
+			continue
+		}
+
+		transports := []protocol.AuthenticatorTransport{}
+		for _, t := range strings.Split(c.Transports, ",") {
+			if t != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(t))
+			}
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.Aaguid,
+				SignCount: uint32(c.SignCount),
+			},
+		})
+	}
+	return credentials
+}
+
+func BeginWebauthnRegistrationService(db postgresql.Databases, accountID int) (interface{}, int, error) {
+	wa, err := newWebauthn()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	user, code, err := getWebauthnUser(db, accountID)
+	if err != nil {
+		return nil, code, err
+	}
+
+	exclusions := []protocol.CredentialDescriptor{}
+	for _, c := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	options, sessionData, err := wa.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	code, err = saveWebauthnChallenge(db, accountID, models.WebauthnCeremonyRegistration, sessionData)
+	if err != nil {
+		return nil, code, err
+	}
+	return options, http.StatusOK, nil
+}
+
+func FinishWebauthnRegistrationService(db postgresql.Databases, accountID int, name string, body io.Reader) (models.WebauthnCredential, int, error) {
+	var (
+		credential = models.WebauthnCredential{}
+	)
+
+	wa, err := newWebauthn()
+	if err != nil {
+		return credential, http.StatusInternalServerError, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(body)
+	if err != nil {
+		return credential, http.StatusBadRequest, fmt.Errorf("invalid credential: %v", err.Error())
+	}
+
+	challenge, sessionData, code, err := consumeWebauthnChallenge(db, parsed.Response.CollectedClientData.Challenge, models.WebauthnCeremonyRegistration)
+	if err != nil {
+		return credential, code, err
+	}
+
+	if challenge.AccountID != accountID {
+		return credential, http.StatusBadRequest, fmt.Errorf("invalid or expired challenge")
+	}
+
+	user, code, err := getWebauthnUser(db, accountID)
+	if err != nil {
+		return credential, code, err
+	}
+
+	created, err := wa.CreateCredential(user, sessionData, parsed)
+	if err != nil {
+		return credential, http.StatusBadRequest, fmt.Errorf("credential verification failed: %v", err.Error())
+	}
+
+	transports := []string{}
+	for _, t := range created.Transport {
+		transports = append(transports, string(t))
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+
+	credential = models.WebauthnCredential{
+		AccountID:       accountID,
+		Name:            name,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(created.ID),
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		Aaguid:          created.Authenticator.AAGUID,
+		SignCount:       int64(created.Authenticator.SignCount),
+	}
+	err = credential.CreateWebauthnCredential(db.Auth)
+	if err != nil {
+		return credential, http.StatusInternalServerError, err
+	}
+	return credential, http.StatusOK, nil
+}
+
+func ListWebauthnCredentialsService(db postgresql.Databases, accountID int) ([]models.WebauthnCredential, int, error) {
+	credential := models.WebauthnCredential{AccountID: accountID}
+	credentials, err := credential.GetAllByAccountID(db.Auth)
+	if err != nil {
+		return credentials, http.StatusInternalServerError, err
+	}
+	return credentials, http.StatusOK, nil
+}
+
+func DeleteWebauthnCredentialService(db postgresql.Databases, accountID int, credentialID uint) (int, error) {
+	credential := models.WebauthnCredential{ID: credentialID, AccountID: accountID}
+	code, err := credential.GetByIDAndAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusNotFound, fmt.Errorf("credential not found")
+	}
+
+	err = credential.Delete(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// BeginWebauthnLoginService starts an assertion, without an identifier the browser is asked for a discoverable passkey
+func BeginWebauthnLoginService(db postgresql.Databases, req models.WebauthnLoginBeginReq) (interface{}, int, error) {
+	wa, err := newWebauthn()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	var (
+		accountID   int
+		options     *protocol.CredentialAssertion
+		sessionData *webauthn.SessionData
+	)
+
+	if req.Username == "" && req.EmailAddress == "" && req.PhoneNumber == "" {
+		options, sessionData, err = wa.BeginDiscoverableLogin()
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	} else {
+		user := models.User{Username: req.Username, EmailAddress: req.EmailAddress, PhoneNumber: req.PhoneNumber}
+		code, err := user.GetUserByUsernameEmailOrPhone(db.Auth)
+		if err != nil {
+			if code == http.StatusBadRequest {
+				return nil, code, fmt.Errorf("invalid login details")
+			}
+			return nil, code, err
+		}
+		accountID = int(user.AccountID)
+
+		waUser, code, err := getWebauthnUser(db, accountID)
+		if err != nil {
+			return nil, code, err
+		}
+
+		if len(waUser.credentials) == 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid login details")
+		}
+
+		options, sessionData, err = wa.BeginLogin(waUser)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+
+	code, err := saveWebauthnChallenge(db, accountID, models.WebauthnCeremonyLogin, sessionData)
+	if err != nil {
+		return nil, code, err
+	}
+	return options, http.StatusOK, nil
+}
+
+// FinishWebauthnLoginService verifies an assertion and ends in the same payload as a password login
+func FinishWebauthnLoginService(c *gin.Context, logger *utility.Logger, body io.Reader, db postgresql.Databases) (map[string]interface{}, int, error) {
+	var (
+		responseData = gin.H{}
+		waUser       *webauthnUser
+	)
+
+	wa, err := newWebauthn()
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(body)
+	if err != nil {
+		return responseData, http.StatusBadRequest, fmt.Errorf("invalid credential: %v", err.Error())
+	}
+
+	challenge, sessionData, code, err := consumeWebauthnChallenge(db, parsed.Response.CollectedClientData.Challenge, models.WebauthnCeremonyLogin)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	var validated *webauthn.Credential
+	if challenge.AccountID != 0 {
+		waUser, code, err = getWebauthnUser(db, challenge.AccountID)
+		if err != nil {
+			return responseData, code, err
+		}
+		validated, err = wa.ValidateLogin(waUser, sessionData, parsed)
+	} else {
+		validated, err = wa.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			accountID, err := strconv.Atoi(string(userHandle))
+			if err != nil {
+				return nil, err
+			}
+			waUser, _, err = getWebauthnUser(db, accountID)
+			return waUser, err
+		}, sessionData, parsed)
+	}
+	if err != nil || waUser == nil {
+		return responseData, http.StatusUnauthorized, fmt.Errorf("passkey verification failed")
+	}
+
+	if validated.Authenticator.CloneWarning {
+		return responseData, http.StatusUnauthorized, fmt.Errorf("passkey verification failed, the authenticator may have been cloned")
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(validated.ID)
+	for _, stored := range waUser.credentials {
+		if stored.CredentialID != credentialID {
+			continue
+		}
+		now := time.Now()
+		stored.SignCount = int64(validated.Authenticator.SignCount)
+		stored.LastUsedAt = &now
+		err = stored.Update(db.Auth)
+		if err != nil {
+			return responseData, http.StatusInternalServerError, err
+		}
+	}
+
+	user := waUser.user
+	bannedAccount := models.BannedAccount{AccountID: int(user.AccountID)}
+	status, err := bannedAccount.CheckByAccountID(db.Auth)
+	if err != nil {
+		return responseData, http.StatusInternalServerError, err
+	}
+
+	if status {
+		return responseData, http.StatusBadRequest, fmt.Errorf("this account has been banned")
+	}
+
+	code, err = checkLoginAllowed(c, db, int(user.AccountID))
+	if err != nil {
+		return responseData, code, err
+	}
+
+	code, err = requireDeviceAuthorization(c, logger, db, user)
+	if err != nil {
+		return responseData, code, err
+	}
+
+	TrackUserLogin(c, logger, db, int(user.AccountID))
+
+	return LoginResponse(c, logger, user, db, models.LoginUserRequestModel{})
+}
+
+func newWebauthn() (*webauthn.WebAuthn, error) {
+	config := config.GetConfig().WebAuthn
+	return webauthn.New(&webauthn.Config{
+		RPID:          config.RPID,
+		RPDisplayName: config.RPDisplayName,
+		RPOrigins:     config.RPOrigins,
+	})
+}
+
+func getWebauthnUser(db postgresql.Databases, accountID int) (*webauthnUser, int, error) {
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return nil, code, err
+	}
+
+	credential := models.WebauthnCredential{AccountID: accountID}
+	credentials, err := credential.GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return &webauthnUser{user: user, credentials: credentials}, http.StatusOK, nil
+}
+
+func saveWebauthnChallenge(db postgresql.Databases, accountID int, ceremony string, sessionData *webauthn.SessionData) (int, error) {
+	data, err := json.Marshal(sessionData)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// challenges of abandoned ceremonies are only dropped to keep the table small, a failure can wait for the next one
+	(&models.WebauthnChallenge{}).DeleteExpired(db.Auth)
+
+	challenge := models.WebauthnChallenge{
+		Challenge:   sessionData.Challenge,
+		AccountID:   accountID,
+		Ceremony:    ceremony,
+		SessionData: string(data),
+		ExpiresAt:   time.Now().Add(webauthnChallengeExpireDuration),
+	}
+	err = challenge.CreateWebauthnChallenge(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// consumeWebauthnChallenge loads and deletes a ceremony challenge so each one can only be answered once
+func consumeWebauthnChallenge(db postgresql.Databases, value, ceremony string) (models.WebauthnChallenge, webauthn.SessionData, int, error) {
+	var (
+		sessionData webauthn.SessionData
+	)
+
+	challenge := models.WebauthnChallenge{Challenge: value, Ceremony: ceremony}
+	code, err := challenge.GetByChallengeAndCeremony(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return challenge, sessionData, code, err
+		}
+		return challenge, sessionData, http.StatusBadRequest, fmt.Errorf("invalid or expired challenge")
+	}
+
+	consumed, err := challenge.Consume(db.Auth)
+	if err != nil {
+		return challenge, sessionData, http.StatusInternalServerError, err
+	}
+
+	if !consumed {
+		return challenge, sessionData, http.StatusBadRequest, fmt.Errorf("invalid or expired challenge")
+	}
+
+	if time.Now().After(challenge.ExpiresAt) {
+		return challenge, sessionData, http.StatusBadRequest, fmt.Errorf("invalid or expired challenge")
+	}
+
+	err = json.Unmarshal([]byte(challenge.SessionData), &sessionData)
+	if err != nil {
+		return challenge, sessionData, http.StatusInternalServerError, err
+	}
+	return challenge, sessionData, http.StatusOK, nil
+}
@@ -1,75 +1,66 @@
 package authorization
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
-	"time"
+	"strings"
 
-	"github.com/golang-jwt/jwt"
 	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/internal/models"
 	"github.com/vesicash/auth-ms/pkg/middleware"
 	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
-	"gorm.io/gorm"
 )
 
 func ValidateAuthorizationService(req models.ValidateAuthorizationReq, db postgresql.Databases) (interface{}, string, bool, int, error) {
 	switch req.Type {
 	case string(middleware.ApiType):
-		msg, status := validateApiType(db, req.VPrivateKey, req.VPublicKey)
-		return nil, msg, status, http.StatusOK, nil
+		data, msg, status := validateApiType(db, req.VPrivateKey, req.VPublicKey, req.AuthorizationToken, req.Scopes, req.IpAddress)
+		return data, msg, status, http.StatusOK, nil
 	case string(middleware.AppType):
 		msg, status := validateAppType(db, req.VApp)
 		return nil, msg, status, http.StatusOK, nil
 	case string(middleware.AuthType):
-		data, msg, status := validateAuthType(db, req.AuthorizationToken)
+		data, msg, status := validateAuthType(db, req.AuthorizationToken, req.Scopes, req.Method, req.Path)
 		return data, msg, status, http.StatusOK, nil
 	case string(middleware.BusinessAdmin):
-		msg, status := validateBusinessAdminType(db, req.VPrivateKey, req.VPublicKey)
+		msg, status := validateBusinessAdminType(db, req.VPrivateKey, req.VPublicKey, req.IpAddress)
 		return nil, msg, status, http.StatusOK, nil
 	case string(middleware.Business):
-		msg, status := validateBusinessType(db, req.VPrivateKey, req.VPublicKey)
-		return nil, msg, status, http.StatusOK, nil
+		data, msg, status := validateBusinessType(db, req.VPrivateKey, req.VPublicKey, req.AuthorizationToken, req.Scopes, req.IpAddress)
+		return data, msg, status, http.StatusOK, nil
 	default:
 		return nil, "not implemented", false, http.StatusBadRequest, fmt.Errorf("not implemented")
 	}
 }
 
-func validateAuthType(db postgresql.Databases, bearerToken string) (interface{}, string, bool) {
-	var invalidToken = "Your request was made with invalid credentials."
-	if bearerToken == "" {
-		return nil, invalidToken, false
-	}
-
-	token, err := middleware.TokenValid(bearerToken)
-	if err != nil {
-		return nil, invalidToken, false
-	}
-
-	claims := token.Claims.(jwt.MapClaims)
-	activeUserType, ok := claims["type"].(string) //convert the interface to string
-	if !ok {
-		return nil, invalidToken, false
-	}
-
-	activeUserAccountID, ok := claims["account_id"].(float64) //convert the interface to float
-	if !ok {
-		return nil, invalidToken, false
+// validateAuthType shares its checks with the introspection endpoint so the two never disagree about a token
+func validateAuthType(db postgresql.Databases, bearerToken string, scopes []string, method, path string) (interface{}, string, bool) {
+	introspection := middleware.IntrospectAccessToken(db, bearerToken)
+	if !introspection.Active {
+		return nil, introspection.Message, false
 	}
 
-	authoriseStatus, ok := claims["authorised"].(bool) //check if token is authorised for middleware
-	if !ok && !authoriseStatus {
-		return nil, invalidToken, false
+	// oauth access tokens are only valid for the scopes the calling service says the route needs
+	if introspection.ClientID != "" && !middleware.HasScopes(strings.Fields(introspection.Scope), scopes) {
+		return nil, "insufficient scope", false
 	}
 
-	myIdentity := models.UserIdentity{
-		AccountID: int(activeUserAccountID),
-		Type:      activeUserType,
+	// impersonation tokens are read-only, calling services that do not send the method of the request are refused
+	if introspection.Act != nil {
+		action := models.AuditActionImpersonationRequest
+		if !middleware.IsReadOnlyMethod(method) {
+			action = models.AuditActionImpersonationRefused
+		}
+		err := middleware.RecordImpersonation(db, introspection, action, strings.TrimSpace(fmt.Sprintf("%v %v", method, path)), "")
+		if err != nil {
+			return nil, err.Error(), false
+		}
+		if action == models.AuditActionImpersonationRefused {
+			return nil, middleware.ImpersonationReadOnly, false
+		}
 	}
 
-	user := models.User{AccountID: uint(myIdentity.AccountID)}
+	user := models.User{AccountID: uint(introspection.AccountID)}
 	code, err := user.GetUserByAccountID(db.Auth)
 	if err != nil {
 		if code == http.StatusInternalServerError {
@@ -78,30 +69,15 @@ func validateAuthType(db postgresql.Databases, bearerToken string) (interface{},
 		return nil, "user does not exist", false
 	}
 
-	if user.LoginAccessToken != bearerToken {
-		return nil, invalidToken, false
-	}
-
-	if user.LoginAccessTokenExpiresIn == "" {
-		return nil, invalidToken, false
-	}
-
-	parseInt, err := strconv.Atoi(user.LoginAccessTokenExpiresIn)
-	if err != nil {
-		return nil, invalidToken, false
-	}
-
-	unixTimeUTC := time.Unix(int64(parseInt), 0)
-	if time.Now().After(unixTimeUTC) {
-		return nil, "expired token", false
-	}
-
+	middleware.TouchSession(db, introspection.Jti)
 	return user, "authorized", true
 }
 
-func validateBusinessType(db postgresql.Databases, privateKey, publicKey string) (string, bool) {
-	_, msg, status := checkAccessTokens(db, privateKey, publicKey)
-	return msg, status
+func validateBusinessType(db postgresql.Databases, privateKey, publicKey, bearerToken string, scopes []string, ipAddress string) (interface{}, string, bool) {
+	if privateKey == "" && publicKey == "" && bearerToken != "" {
+		return liveEnvironment(validateClientToken(db, bearerToken, scopes))
+	}
+	return apiKeyEnvironment(checkAccessTokens(db, privateKey, publicKey, scopes, ipAddress))
 }
 
 func validateAppType(db postgresql.Databases, appKey string) (string, bool) {
@@ -117,50 +93,73 @@ func validateAppType(db postgresql.Databases, appKey string) (string, bool) {
 	return "authorized", true
 }
 
-func validateBusinessAdminType(db postgresql.Databases, privateKey, publicKey string) (string, bool) {
-	token, msg, status := checkAccessTokens(db, privateKey, publicKey)
-	if !status {
-		return msg, status
-	}
-
-	user := models.User{AccountID: uint(token.AccountID)}
-	_, err := user.GetUserByAccountID(db.Auth)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "access denied", false
-		} else {
-			return "server error", false
-		}
+func validateBusinessAdminType(db postgresql.Databases, privateKey, publicKey, ipAddress string) (string, bool) {
+	introspection := checkAccessTokens(db, privateKey, publicKey, nil, ipAddress)
+	if !introspection.Active {
+		return introspection.Message, false
 	}
-	if user.AccountType != "admin" {
+	if introspection.AccountType != "admin" {
 		return "access denied", false
 	}
 	return "authorized", true
 }
 
-func validateApiType(db postgresql.Databases, privateKey, publicKey string) (string, bool) {
-	_, msg, status := checkAccessTokens(db, privateKey, publicKey)
-	return msg, status
+func validateApiType(db postgresql.Databases, privateKey, publicKey, bearerToken string, scopes []string, ipAddress string) (interface{}, string, bool) {
+	if privateKey == "" && publicKey == "" && bearerToken != "" {
+		return liveEnvironment(validateClientToken(db, bearerToken, scopes))
+	}
+	return apiKeyEnvironment(checkAccessTokens(db, privateKey, publicKey, scopes, ipAddress))
 }
 
-func checkAccessTokens(db postgresql.Databases, privateKey, publicKey string) (models.AccessToken, string, bool) {
+// apiKeyEnvironment tells the calling service whether the key works on live or on sandbox data
+func apiKeyEnvironment(introspection middleware.Introspection) (interface{}, string, bool) {
+	if !introspection.Active {
+		return nil, introspection.Message, false
+	}
+	return map[string]interface{}{"environment": introspection.Environment}, introspection.Message, true
+}
 
+// liveEnvironment is the response for oauth client tokens, they always work on live data
+func liveEnvironment(msg string, status bool) (interface{}, string, bool) {
+	if !status {
+		return nil, msg, false
+	}
+	return map[string]interface{}{"environment": models.ApiKeyEnvironmentLive}, msg, true
+}
+
+func validateClientToken(db postgresql.Databases, bearerToken string, scopes []string) (string, bool) {
+	_, granted, msg, ok := middleware.ValidateOauthClientToken(db, bearerToken)
+	if !ok {
+		return msg, false
+	}
+	if !middleware.HasScopes(granted, scopes) {
+		return "insufficient scope", false
+	}
+	return "authorized", true
+}
+
+// checkAccessTokens refuses scoped keys unless the calling service says the route needs one of their scopes
+func checkAccessTokens(db postgresql.Databases, privateKey, publicKey string, scopes []string, ipAddress string) middleware.Introspection {
 	if privateKey == "" && publicKey == "" {
-		return models.AccessToken{}, "missing api keys", false
+		return middleware.Introspection{Message: "missing api keys"}
 	}
 
 	if privateKey == "" || publicKey == "" {
-		return models.AccessToken{}, "either public or private key is missing", false
+		return middleware.Introspection{Message: "either public or private key is missing"}
+	}
+
+	introspection := middleware.IntrospectApiKey(db, privateKey, publicKey)
+	if !introspection.Active {
+		return introspection
 	}
 
-	token := models.AccessToken{PublicKey: publicKey, PrivateKey: privateKey, IsLive: true}
-	_, err := token.LiveTokensWithPublicOrPrivateKey(db.Auth)
+	if introspection.AccessToken.IsScoped() && !middleware.HasScopes(introspection.AccessToken.ScopeList(), scopes) {
+		return middleware.Introspection{Message: "insufficient scope"}
+	}
+
+	err := middleware.TouchApiKey(db, &introspection.AccessToken, ipAddress)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return token, "invalid keys", false
-		} else {
-			return token, "server error", false
-		}
+		return middleware.Introspection{Message: "server error"}
 	}
-	return token, "authorized", true
+	return introspection
 }
@@ -0,0 +1,180 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+const (
+	AuthorizationCodeExpireDuration = 10 * time.Minute
+	CodeChallengeMethodS256         = "S256"
+)
+
+// AuthorizeService validates an authorization request and describes the consent screen the frontend renders
+func AuthorizeService(db postgresql.Databases, accountID int, req models.OauthAuthorizeReq) (gin.H, int, error) {
+	client, redirectUri, scopes, code, err := validateAuthorizeRequest(db, req)
+	if err != nil {
+		return nil, code, err
+	}
+
+	scopeDescriptions := []gin.H{}
+	for _, scope := range scopes {
+		scopeDescriptions = append(scopeDescriptions, gin.H{"scope": scope, "description": middleware.OauthScopes[scope]})
+	}
+
+	consent := models.OauthConsent{AccountID: accountID, ClientID: client.ClientID}
+	code, err = consent.GetByAccountIDAndClientID(db.Auth)
+	if err != nil && code == http.StatusInternalServerError {
+		return nil, code, err
+	}
+
+	return gin.H{
+		"client": gin.H{
+			"client_id": client.ClientID,
+			"name":      client.Name,
+		},
+		"redirect_uri":    redirectUri,
+		"state":           req.State,
+		"scopes":          scopeDescriptions,
+		"consent_granted": err == nil && consent.Covers(scopes),
+	}, http.StatusOK, nil
+}
+
+// ConsentService records the user's decision and returns where the frontend should send the user next, an
+// authorization code on approval or an access_denied error otherwise
+func ConsentService(db postgresql.Databases, accountID int, req models.OauthConsentReq) (gin.H, int, error) {
+	client, redirectUri, scopes, code, err := validateAuthorizeRequest(db, req.OauthAuthorizeReq)
+	if err != nil {
+		return nil, code, err
+	}
+
+	if !req.Approve {
+		return nil, http.StatusForbidden, &Error{Code: ErrAccessDenied, Description: "the user denied the request", RedirectUri: redirectUri, State: req.State}
+	}
+
+	consent := models.OauthConsent{AccountID: accountID, ClientID: client.ClientID}
+	code, err = consent.GetByAccountIDAndClientID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return nil, code, err
+		}
+		consent.Scope = strings.Join(scopes, " ")
+		err = consent.CreateOauthConsent(db.Auth)
+	} else if !consent.Covers(scopes) {
+		granted, _ := middleware.ParseScopes(consent.Scope + " " + strings.Join(scopes, " "))
+		consent.Scope = strings.Join(granted, " ")
+		err = consent.Update(db.Auth)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	authorizationCode, err := utility.GenerateSecureToken(32)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	codeRecord := models.OauthAuthorizationCode{
+		CodeHash:            utility.HashToken(authorizationCode),
+		ClientID:            client.ClientID,
+		AccountID:           accountID,
+		RedirectUri:         redirectUri,
+		RedirectUriSupplied: req.RedirectUri != "",
+		Scope:               strings.Join(scopes, " "),
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		ExpiresAt:           time.Now().Add(AuthorizationCodeExpireDuration),
+	}
+	err = codeRecord.CreateOauthAuthorizationCode(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	params := url.Values{"code": {authorizationCode}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return gin.H{"redirect_to": withQuery(redirectUri, params)}, http.StatusOK, nil
+}
+
+// validateAuthorizeRequest checks the client and redirect uri first, errors after that point are redirectable
+func validateAuthorizeRequest(db postgresql.Databases, req models.OauthAuthorizeReq) (models.OauthClient, string, []string, int, error) {
+	if req.ClientID == "" {
+		return models.OauthClient{}, "", nil, http.StatusBadRequest, newError(ErrInvalidRequest, "client_id is required")
+	}
+
+	client := models.OauthClient{ClientID: req.ClientID}
+	code, err := client.GetByClientID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return client, "", nil, code, newError(ErrServerError, err.Error())
+		}
+		return client, "", nil, http.StatusBadRequest, newError(ErrInvalidClient, "unknown client")
+	}
+
+	redirectUri := req.RedirectUri
+	registered := client.RedirectUriList()
+	if redirectUri == "" && len(registered) == 1 {
+		redirectUri = registered[0]
+	}
+	if redirectUri == "" || !client.AllowsRedirectUri(redirectUri) {
+		return client, "", nil, http.StatusBadRequest, newError(ErrInvalidRequest, "redirect_uri does not match a registered redirect uri")
+	}
+
+	redirectError := func(code, description string) (models.OauthClient, string, []string, int, error) {
+		return client, redirectUri, nil, http.StatusBadRequest, &Error{Code: code, Description: description, RedirectUri: redirectUri, State: req.State}
+	}
+
+	if req.ResponseType != "code" {
+		return redirectError(ErrUnsupportedResponseType, "response_type must be code")
+	}
+
+	scopes, err := clientScopes(client, req.Scope)
+	if err != nil {
+		return redirectError(ErrInvalidScope, err.Error())
+	}
+
+	if req.CodeChallenge == "" {
+		if client.Public {
+			return redirectError(ErrInvalidRequest, "code_challenge is required for public clients")
+		}
+	} else {
+		if req.CodeChallengeMethod != CodeChallengeMethodS256 {
+			return redirectError(ErrInvalidRequest, "code_challenge_method must be S256")
+		}
+		if len(req.CodeChallenge) < 43 || len(req.CodeChallenge) > 128 {
+			return redirectError(ErrInvalidRequest, "invalid code_challenge")
+		}
+	}
+
+	return client, redirectUri, scopes, http.StatusOK, nil
+}
+
+// clientScopes resolves the requested scope against the scopes registered for the client, an empty request
+// asks for all of them
+func clientScopes(client models.OauthClient, scope string) ([]string, error) {
+	if strings.TrimSpace(scope) == "" {
+		return client.ScopeList(), nil
+	}
+
+	scopes, err := middleware.ParseScopes(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := client.ScopeList()
+	for _, s := range scopes {
+		if !middleware.HasScopes(registered, []string{s}) {
+			return nil, newError(ErrInvalidScope, "scope not registered for this client: "+s)
+		}
+	}
+	return scopes, nil
+}
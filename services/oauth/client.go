@@ -0,0 +1,153 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// CreateClientService registers an oauth client for a business, the client secret is only returned here
+func CreateClientService(db postgresql.Databases, accountID int, req models.CreateOauthClientReq) (gin.H, int, error) {
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		return nil, code, err
+	}
+
+	if user.AccountType != "business" {
+		return nil, http.StatusForbidden, fmt.Errorf("only business accounts can register oauth clients")
+	}
+
+	scopes, err := middleware.ParseScopes(strings.Join(req.Scopes, " "))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if len(scopes) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("at least one scope is required")
+	}
+
+	for _, redirectUri := range req.RedirectUris {
+		err := validateRedirectUri(redirectUri)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+	if req.Public && len(req.RedirectUris) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("public clients need at least one redirect uri")
+	}
+
+	clientID, err := utility.GenerateSecureToken(18)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	client := models.OauthClient{
+		AccountID:    accountID,
+		ClientID:     clientID,
+		Name:         req.Name,
+		RedirectUris: strings.Join(req.RedirectUris, " "),
+		Scope:        strings.Join(scopes, " "),
+		Public:       req.Public,
+	}
+
+	clientSecret := ""
+	if !client.Public {
+		clientSecret, err = utility.GenerateSecureToken(32)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		client.ClientSecretHash = utility.HashToken(clientSecret)
+	}
+
+	err = client.CreateOauthClient(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	data := clientResponse(client)
+	if clientSecret != "" {
+		data["client_secret"] = clientSecret
+	}
+	return data, http.StatusCreated, nil
+}
+
+func ListClientsService(db postgresql.Databases, accountID int) ([]gin.H, int, error) {
+	client := models.OauthClient{AccountID: accountID}
+	clients, err := client.GetAllByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	data := []gin.H{}
+	for _, c := range clients {
+		data = append(data, clientResponse(c))
+	}
+	return data, http.StatusOK, nil
+}
+
+// DeleteClientService revokes a client and every session opened with its tokens
+func DeleteClientService(db postgresql.Databases, accountID int, clientID string) (int, error) {
+	client := models.OauthClient{ClientID: clientID, AccountID: accountID}
+	code, err := client.GetByClientIDAndAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return code, err
+		}
+		return http.StatusNotFound, fmt.Errorf("oauth client not found")
+	}
+
+	err = client.Revoke(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	session := models.Session{ClientID: client.ClientID}
+	sessions, err := session.GetActiveByClientID(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = middleware.DenySessions(db, sessions...)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = session.RevokeAllByClientID(db.Auth)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func clientResponse(client models.OauthClient) gin.H {
+	return gin.H{
+		"id":            client.ID,
+		"client_id":     client.ClientID,
+		"name":          client.Name,
+		"redirect_uris": client.RedirectUriList(),
+		"scopes":        client.ScopeList(),
+		"public":        client.Public,
+		"created_at":    client.CreatedAt,
+	}
+}
+
+// validateRedirectUri only allows absolute https uris, or http on localhost for development
+func validateRedirectUri(redirectUri string) error {
+	parsed, err := url.Parse(redirectUri)
+	if err != nil || !parsed.IsAbs() || parsed.Host == "" || strings.ContainsAny(redirectUri, " \t\n") {
+		return fmt.Errorf("invalid redirect uri: %v", redirectUri)
+	}
+	if parsed.Fragment != "" {
+		return fmt.Errorf("redirect uri must not contain a fragment: %v", redirectUri)
+	}
+	if parsed.Scheme != "https" && !(parsed.Scheme == "http" && parsed.Hostname() == "localhost") {
+		return fmt.Errorf("redirect uri must use https: %v", redirectUri)
+	}
+	return nil
+}
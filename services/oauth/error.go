@@ -0,0 +1,65 @@
+package oauth
+
+import (
+	"net/url"
+)
+
+const (
+	ErrInvalidRequest          = "invalid_request"
+	ErrInvalidClient           = "invalid_client"
+	ErrInvalidGrant            = "invalid_grant"
+	ErrInvalidScope            = "invalid_scope"
+	ErrUnauthorizedClient      = "unauthorized_client"
+	ErrUnsupportedGrantType    = "unsupported_grant_type"
+	ErrUnsupportedResponseType = "unsupported_response_type"
+	ErrAccessDenied            = "access_denied"
+	ErrServerError             = "server_error"
+)
+
+// Error is the RFC 6749 error response, RedirectUri is set when the error should be sent back to the client
+// through its redirect uri rather than shown to the user
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	RedirectUri string `json:"-"`
+	State       string `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Description
+}
+
+// Redirect returns the client redirect uri carrying the error, or an empty string when the error is not redirectable
+func (e *Error) Redirect() string {
+	if e.RedirectUri == "" {
+		return ""
+	}
+	params := url.Values{"error": {e.Code}}
+	if e.Description != "" {
+		params.Set("error_description", e.Description)
+	}
+	if e.State != "" {
+		params.Set("state", e.State)
+	}
+	return withQuery(e.RedirectUri, params)
+}
+
+func newError(code, description string) *Error {
+	return &Error{Code: code, Description: description}
+}
+
+func withQuery(redirectUri string, params url.Values) string {
+	parsed, err := url.Parse(redirectUri)
+	if err != nil {
+		return redirectUri
+	}
+	query := parsed.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
@@ -0,0 +1,102 @@
+package oauth
+
+import (
+	"net/http"
+
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// IntrospectService implements RFC 7662 token introspection. Vesicash services calling with the app key can
+// introspect any token, oauth clients only the tokens that were issued to them.
+func IntrospectService(db postgresql.Databases, req models.OauthIntrospectReq, trusted bool) (middleware.Introspection, int, error) {
+	client := models.OauthClient{}
+	if !trusted {
+		var (
+			code int
+			err  error
+		)
+		client, code, err = authenticateClient(db, req.ClientID, req.ClientSecret)
+		if err != nil {
+			return middleware.Introspection{}, code, err
+		}
+		if client.Public {
+			return middleware.Introspection{}, http.StatusUnauthorized, newError(ErrInvalidClient, "public clients cannot introspect tokens")
+		}
+	}
+
+	if req.Token == "" {
+		return middleware.Introspection{}, http.StatusBadRequest, newError(ErrInvalidRequest, "token is required")
+	}
+
+	introspection := middleware.IntrospectToken(db, req.Token, req.TokenTypeHint)
+	if !trusted && introspection.ClientID != client.ClientID {
+		return middleware.Introspection{}, http.StatusOK, nil
+	}
+	return introspection, http.StatusOK, nil
+}
+
+// RevokeService implements RFC 7009 token revocation. Tokens that are unknown or no longer active are not an
+// error, oauth clients can only revoke the tokens that were issued to them.
+func RevokeService(db postgresql.Databases, req models.OauthIntrospectReq, trusted bool) (int, error) {
+	client := models.OauthClient{}
+	if !trusted {
+		var (
+			code int
+			err  error
+		)
+		client, code, err = authenticateClient(db, req.ClientID, req.ClientSecret)
+		if err != nil {
+			return code, err
+		}
+	}
+
+	if req.Token == "" {
+		return http.StatusBadRequest, newError(ErrInvalidRequest, "token is required")
+	}
+
+	introspection := middleware.IntrospectToken(db, req.Token, req.TokenTypeHint)
+	if !introspection.Active {
+		return http.StatusOK, nil
+	}
+
+	if !trusted && introspection.ClientID != client.ClientID {
+		return http.StatusBadRequest, newError(ErrUnauthorizedClient, "the token was not issued to this client")
+	}
+
+	var err error
+	switch introspection.TokenUse {
+	case middleware.TokenTypeAccessToken:
+		err = middleware.DenyAccessToken(db, introspection.AccountID, introspection.Jti)
+		if err != nil {
+			break
+		}
+		session := models.Session{AccessUuid: introspection.Jti}
+		if _, lookupErr := session.GetByAccessUuid(db.Auth); lookupErr == nil {
+			err = middleware.RevokeSession(db, session)
+		}
+	case middleware.TokenTypeRefreshToken:
+		refreshToken := models.RefreshToken{TokenHash: utility.HashToken(req.Token)}
+		code, lookupErr := refreshToken.GetByTokenHash(db.Auth)
+		if lookupErr != nil {
+			if code == http.StatusInternalServerError {
+				return code, newError(ErrServerError, lookupErr.Error())
+			}
+			return http.StatusOK, nil
+		}
+		session := models.Session{FamilyID: refreshToken.FamilyID}
+		if _, lookupErr = session.GetByFamilyID(db.Auth); lookupErr == nil {
+			err = middleware.RevokeSession(db, session)
+		} else {
+			err = refreshToken.RevokeFamily(db.Auth)
+		}
+	case middleware.TokenTypeApiKey:
+		err = introspection.AccessToken.RevokeAccessToken(db.Auth)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, newError(ErrServerError, err.Error())
+	}
+	return http.StatusOK, nil
+}
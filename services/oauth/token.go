@@ -0,0 +1,173 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+// TokenService implements the token endpoint for the authorization code and client credentials grants.
+// Errors are always *Error so they can be returned in the RFC 6749 format.
+func TokenService(c *gin.Context, db postgresql.Databases, req models.OauthTokenReq) (gin.H, int, error) {
+	switch req.GrantType {
+	case middleware.OauthGrantAuthorizationCode, middleware.OauthGrantClientCredentials:
+	case "":
+		return nil, http.StatusBadRequest, newError(ErrInvalidRequest, "grant_type is required")
+	default:
+		return nil, http.StatusBadRequest, newError(ErrUnsupportedGrantType, "")
+	}
+
+	client, code, err := authenticateClient(db, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, code, err
+	}
+
+	if req.GrantType == middleware.OauthGrantClientCredentials {
+		return clientCredentialsGrant(c, db, client, req)
+	}
+	return authorizationCodeGrant(c, db, client, req)
+}
+
+// authenticateClient checks the client secret of confidential clients, public clients only identify themselves
+func authenticateClient(db postgresql.Databases, clientID, clientSecret string) (models.OauthClient, int, error) {
+	if clientID == "" {
+		return models.OauthClient{}, http.StatusUnauthorized, newError(ErrInvalidClient, "client authentication failed")
+	}
+
+	client := models.OauthClient{ClientID: clientID}
+	code, err := client.GetByClientID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return client, code, newError(ErrServerError, err.Error())
+		}
+		return client, http.StatusUnauthorized, newError(ErrInvalidClient, "client authentication failed")
+	}
+
+	if client.Public {
+		return client, http.StatusOK, nil
+	}
+
+	if clientSecret == "" || subtle.ConstantTimeCompare([]byte(utility.HashToken(clientSecret)), []byte(client.ClientSecretHash)) != 1 {
+		return client, http.StatusUnauthorized, newError(ErrInvalidClient, "client authentication failed")
+	}
+	return client, http.StatusOK, nil
+}
+
+func authorizationCodeGrant(c *gin.Context, db postgresql.Databases, client models.OauthClient, req models.OauthTokenReq) (gin.H, int, error) {
+	invalidGrant := newError(ErrInvalidGrant, "invalid or expired authorization code")
+	if req.Code == "" {
+		return nil, http.StatusBadRequest, newError(ErrInvalidRequest, "code is required")
+	}
+
+	codeRecord := models.OauthAuthorizationCode{CodeHash: utility.HashToken(req.Code)}
+	code, err := codeRecord.GetByCodeHash(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return nil, code, newError(ErrServerError, err.Error())
+		}
+		return nil, http.StatusBadRequest, invalidGrant
+	}
+
+	if codeRecord.ClientID != client.ClientID || codeRecord.Used || time.Now().After(codeRecord.ExpiresAt) {
+		return nil, http.StatusBadRequest, invalidGrant
+	}
+
+	// redirect_uri is only required here when the authorization request sent it (RFC 6749 4.1.3)
+	if (codeRecord.RedirectUriSupplied || req.RedirectUri != "") && req.RedirectUri != codeRecord.RedirectUri {
+		return nil, http.StatusBadRequest, newError(ErrInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+
+	if codeRecord.CodeChallenge != "" && !verifyCodeChallenge(req.CodeVerifier, codeRecord.CodeChallenge) {
+		return nil, http.StatusBadRequest, newError(ErrInvalidGrant, "invalid code_verifier")
+	}
+
+	consumed, err := codeRecord.MarkUsed(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, newError(ErrServerError, err.Error())
+	}
+	if !consumed {
+		return nil, http.StatusBadRequest, invalidGrant
+	}
+
+	return issueToken(c, db, client, codeRecord.AccountID, middleware.OauthGrantAuthorizationCode, strings.Fields(codeRecord.Scope))
+}
+
+func clientCredentialsGrant(c *gin.Context, db postgresql.Databases, client models.OauthClient, req models.OauthTokenReq) (gin.H, int, error) {
+	if client.Public {
+		return nil, http.StatusBadRequest, newError(ErrUnauthorizedClient, "public clients cannot use the client_credentials grant")
+	}
+
+	scopes, err := clientScopes(client, req.Scope)
+	if err != nil {
+		return nil, http.StatusBadRequest, newError(ErrInvalidScope, err.Error())
+	}
+
+	return issueToken(c, db, client, client.AccountID, middleware.OauthGrantClientCredentials, scopes)
+}
+
+// issueToken signs the access token and opens a session for it, so oauth tokens show up and can be revoked
+// alongside the user's other sessions
+func issueToken(c *gin.Context, db postgresql.Databases, client models.OauthClient, accountID int, grant string, scopes []string) (gin.H, int, error) {
+	user := models.User{AccountID: uint(accountID)}
+	code, err := user.GetUserByAccountID(db.Auth)
+	if err != nil {
+		if code == http.StatusInternalServerError {
+			return nil, code, newError(ErrServerError, err.Error())
+		}
+		return nil, http.StatusBadRequest, newError(ErrInvalidGrant, "user does not exist")
+	}
+
+	banned, err := (&models.BannedAccount{AccountID: accountID}).CheckByAccountID(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, newError(ErrServerError, err.Error())
+	}
+	if banned {
+		return nil, http.StatusBadRequest, newError(ErrInvalidGrant, "this account has been banned")
+	}
+
+	token, err := middleware.CreateOauthToken(user, client.ClientID, grant, scopes)
+	if err != nil {
+		return nil, http.StatusInternalServerError, newError(ErrServerError, err.Error())
+	}
+
+	session := models.Session{
+		AccountID:  accountID,
+		AccessUuid: token.AccessUuid,
+		ClientID:   client.ClientID,
+		Device:     client.Name,
+		IpAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		LastSeenAt: time.Now(),
+		ExpiresAt:  token.AtExpiresTime,
+	}
+	err = session.CreateSession(db.Auth)
+	if err != nil {
+		return nil, http.StatusInternalServerError, newError(ErrServerError, err.Error())
+	}
+
+	return gin.H{
+		"access_token": token.AccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(time.Until(token.AtExpiresTime).Seconds()),
+		"scope":        strings.Join(scopes, " "),
+	}, http.StatusOK, nil
+}
+
+// verifyCodeChallenge checks an RFC 7636 S256 code verifier
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -75,11 +75,13 @@ func SignupUser(t *testing.T, r *gin.Engine, auth auth.Controller, userSignUpDat
 }
 
 func GetLoginTokenAndAccountID(t *testing.T, r *gin.Engine, auth auth.Controller, loginData models.LoginUserRequestModel) (string, int) {
-	var (
-		loginPath = "/v2/login"
-		loginURI  = url.URL{Path: loginPath}
-	)
-	r.POST(loginPath, auth.Login)
+	r.POST("/v2/login", auth.Login)
+	return Login(t, r, loginData)
+}
+
+// Login logs in again on the login route GetLoginTokenAndAccountID registered on r, every login starts its own session
+func Login(t *testing.T, r *gin.Engine, loginData models.LoginUserRequestModel) (string, int) {
+	loginURI := url.URL{Path: "/v2/login"}
 	var b bytes.Buffer
 	json.NewEncoder(&b).Encode(loginData)
 	req, err := http.NewRequest(http.MethodPost, loginURI.String(), &b)
@@ -108,3 +110,28 @@ func GetAccessToken(accountID int, db *gorm.DB) models.AccessToken {
 	token.CreateAccessToken(db)
 	return token
 }
+
+func GetLoginData(t *testing.T, r *gin.Engine, auth auth.Controller, loginData models.LoginUserRequestModel) map[string]interface{} {
+	var (
+		loginPath = "/v2/login"
+		loginURI  = url.URL{Path: loginPath}
+	)
+	r.POST(loginPath, auth.Login)
+	var b bytes.Buffer
+	json.NewEncoder(&b).Encode(loginData)
+	req, err := http.NewRequest(http.MethodPost, loginURI.String(), &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		return map[string]interface{}{}
+	}
+
+	data := ParseResponse(rr)
+	return data["data"].(map[string]interface{})
+}
@@ -0,0 +1,242 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/internal/models/migrations"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestApiKeys(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "business",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		ok = func(c *gin.Context) { c.JSON(http.StatusOK, nil) }
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/user/api-keys", auth.CreateApiKey)
+		authTypeUrl.GET("/user/api-keys", auth.GetApiKeys)
+		authTypeUrl.PATCH("/user/api-keys/:key_id", auth.UpdateApiKey)
+		authTypeUrl.DELETE("/user/api-keys/:key_id", auth.DeleteApiKey)
+	}
+	r.POST("/v2/api/send_otp", middleware.Scoped(db, []string{middleware.ScopeOtpSend}, middleware.ApiType), ok)
+	r.GET("/v2/api/unscoped", middleware.Authorize(db, middleware.ApiType), ok)
+	r.GET("/v2/account/wallet", middleware.Scoped(db, []string{middleware.ScopeWalletRead}, middleware.AuthType, middleware.ApiType), auth.GetUserWalletBalance)
+
+	request := func(t *testing.T, method, path string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&b).Encode(body)
+		}
+		req, err := http.NewRequest(method, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	apiResponse := func(t *testing.T, method, path string, key models.AccessToken) (int, map[string]interface{}) {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("v-private-key", key.PrivateKey)
+		req.Header.Set("v-public-key", key.PublicKey)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	apiRequest := func(t *testing.T, method, path string, key models.AccessToken) int {
+		code, _ := apiResponse(t, method, path, key)
+		return code
+	}
+
+	createKey := func(t *testing.T, req models.CreateApiKeyReq) models.AccessToken {
+		code, data := request(t, http.MethodPost, "/v2/user/api-keys", req)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		created := data["data"].(map[string]interface{})
+		key := models.AccessToken{ID: uint(created["id"].(float64)), AccountID: accountID}
+		if _, err := key.GetByIDAndAccountID(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		key.PrivateKey = created["private_key"].(string)
+		return key
+	}
+
+	var storefront, backOffice models.AccessToken
+
+	t.Run("unknown scope", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/user/api-keys", models.CreateApiKeyReq{Label: "storefront", Scopes: []string{"everything"}})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK create keys", func(t *testing.T) {
+		storefront = createKey(t, models.CreateApiKeyReq{Label: "storefront", Scopes: []string{middleware.ScopeOtpSend}})
+		backOffice = createKey(t, models.CreateApiKeyReq{Label: "back office"})
+
+		code, _ := request(t, http.MethodPost, "/v2/user/api-keys", models.CreateApiKeyReq{Label: "Storefront"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, data := request(t, http.MethodGet, "/v2/user/api-keys", nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		if len(data["data"].([]interface{})) != 2 {
+			t.Errorf("expected 2 api keys, got %v", len(data["data"].([]interface{})))
+		}
+	})
+
+	t.Run("OK private key is stored hashed and shown once", func(t *testing.T) {
+		if storefront.PrivateKeyHash == storefront.PrivateKey || !storefront.VerifyPrivateKey(storefront.PrivateKey) {
+			t.Errorf("expected the private key to be stored hashed")
+		}
+		if !strings.HasPrefix(storefront.PrivateKey, storefront.PrivateKeyPrefix) {
+			t.Errorf("expected %q to start with %q", storefront.PrivateKey, storefront.PrivateKeyPrefix)
+		}
+
+		_, data := request(t, http.MethodGet, "/v2/user/api-keys", nil)
+		for _, key := range data["data"].([]interface{}) {
+			if _, ok := key.(map[string]interface{})["private_key"]; ok {
+				t.Errorf("expected listed keys to leave out the private key")
+			}
+		}
+	})
+
+	t.Run("OK scopes are enforced", func(t *testing.T) {
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodPost, "/v2/api/send_otp", storefront), http.StatusOK)
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodGet, "/v2/api/unscoped", storefront), http.StatusForbidden)
+
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodPost, "/v2/api/send_otp", backOffice), http.StatusOK)
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodGet, "/v2/api/unscoped", backOffice), http.StatusOK)
+	})
+
+	t.Run("OK last use is recorded", func(t *testing.T) {
+		key := models.AccessToken{ID: storefront.ID, AccountID: accountID}
+		if _, err := key.GetByIDAndAccountID(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		if key.LastUsedAt == nil || key.LastUsedIp == "" {
+			t.Errorf("expected the last use of the key to be recorded")
+		}
+	})
+
+	t.Run("OK update scopes", func(t *testing.T) {
+		noScopes := []string{}
+		code, _ := request(t, http.MethodPatch, fmt.Sprintf("/v2/user/api-keys/%v", storefront.ID), models.UpdateApiKeyReq{Scopes: &noScopes})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodGet, "/v2/api/unscoped", storefront), http.StatusOK)
+
+		code, _ = request(t, http.MethodPatch, fmt.Sprintf("/v2/user/api-keys/%v", storefront.ID), models.UpdateApiKeyReq{Label: backOffice.Label})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		staging := createKey(t, models.CreateApiKeyReq{Label: "staging"})
+		_, err := postgresql.UpdateFieldsWhere(db.Auth, &models.AccessToken{}, map[string]interface{}{"expires_at": time.Now().Add(-time.Minute)}, "id = ?", staging.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodGet, "/v2/api/unscoped", staging), http.StatusUnauthorized)
+
+		code, _ := request(t, http.MethodPost, "/v2/user/api-keys", models.CreateApiKeyReq{Label: "past", ExpiresAt: &time.Time{}})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK plaintext keys are hashed in place", func(t *testing.T) {
+		legacy := createKey(t, models.CreateApiKeyReq{Label: "legacy"})
+		_, err := postgresql.UpdateFieldsWhere(db.Auth, &models.AccessToken{}, map[string]interface{}{"private_key": legacy.PrivateKey}, "id = ?", legacy.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodGet, "/v2/api/unscoped", legacy), http.StatusUnauthorized)
+
+		hashed, err := migrations.HashApiKeys(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hashed < 1 {
+			t.Errorf("expected the plaintext key to be hashed")
+		}
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodGet, "/v2/api/unscoped", legacy), http.StatusOK)
+	})
+
+	t.Run("OK test keys read sandbox data", func(t *testing.T) {
+		testKey := createKey(t, models.CreateApiKeyReq{Label: "test", Environment: models.ApiKeyEnvironmentTest})
+		tst.AssertResponseMessage(t, testKey.Environment, models.ApiKeyEnvironmentTest)
+		tst.AssertBool(t, strings.HasPrefix(testKey.PrivateKey, "v_test_"), true)
+		tst.AssertBool(t, testKey.IsLive, false)
+
+		code, data := apiResponse(t, http.MethodGet, "/v2/account/wallet", testKey)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["data"].(map[string]interface{})["environment"].(string), models.ApiKeyEnvironmentTest)
+
+		tst.AssertBool(t, strings.HasPrefix(backOffice.PrivateKey, "v_live_"), true)
+		code, data = apiResponse(t, http.MethodGet, "/v2/account/wallet", backOffice)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		if _, sandboxed := data["data"].(map[string]interface{})["environment"]; sandboxed {
+			t.Errorf("expected live keys to read live wallets")
+		}
+
+		code, _ = request(t, http.MethodDelete, fmt.Sprintf("/v2/user/api-keys/%v", testKey.ID), nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		if _, err := testKey.GetByIDAndAccountID(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, testKey.IsRevoked(), true)
+		tst.AssertResponseMessage(t, testKey.Environment, models.ApiKeyEnvironmentTest)
+	})
+
+	t.Run("OK revoke key", func(t *testing.T) {
+		code, _ := request(t, http.MethodDelete, fmt.Sprintf("/v2/user/api-keys/%v", backOffice.ID), nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertStatusCode(t, apiRequest(t, http.MethodGet, "/v2/api/unscoped", backOffice), http.StatusUnauthorized)
+
+		code, _ = request(t, http.MethodDelete, fmt.Sprintf("/v2/user/api-keys/%v", backOffice.ID), nil)
+		tst.AssertStatusCode(t, code, http.StatusNotFound)
+	})
+}
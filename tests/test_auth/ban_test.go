@@ -0,0 +1,182 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestBanAccount(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		adminUuid, _   = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		adminSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testadmin%v@qa.team", adminUuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "admin",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", adminUuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		adminLoginData = models.LoginUserRequestModel{
+			Username: adminSignUpData.Username,
+			Password: adminSignUpData.Password,
+		}
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+
+	adminRouter := gin.Default()
+	tst.SignupUser(t, adminRouter, auth, adminSignUpData)
+	_, adminAccountID := tst.GetLoginTokenAndAccountID(t, adminRouter, auth, adminLoginData)
+	_, err := postgresql.UpdateFieldsWhere(db.Auth, &models.User{}, map[string]interface{}{"account_type": "admin"}, "account_id = ?", adminAccountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminKeys := tst.GetAccessToken(adminAccountID, db.Auth)
+
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+	userKeys := tst.GetAccessToken(accountID, db.Auth)
+
+	businessAdminUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.BusinessAdmin))
+	{
+		businessAdminUrl.POST("/users/ban", auth.BanAccount)
+		businessAdminUrl.POST("/users/unban", auth.UnbanAccount)
+		businessAdminUrl.GET("/users/bans", auth.GetBans)
+	}
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/validate-token", auth.ValidateToken)
+	}
+
+	request := func(t *testing.T, method, path string, headers map[string]string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&b).Encode(body)
+		}
+		req, err := http.NewRequest(method, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	adminHeaders := map[string]string{"v-private-key": adminKeys.PrivateKey, "v-public-key": adminKeys.PublicKey}
+
+	t.Run("admin routes refuse test keys", func(t *testing.T) {
+		testKeys := models.AccessToken{AccountID: adminAccountID, Environment: models.ApiKeyEnvironmentTest}
+		if err := testKeys.CreateAccessToken(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ := request(t, http.MethodGet, "/v2/users/bans", map[string]string{"v-private-key": testKeys.PrivateKey, "v-public-key": testKeys.PublicKey}, nil)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("ban with invalid reason or unknown account", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/users/ban", adminHeaders, models.BanAccountReq{AccountID: accountID, ReasonCode: "no reason"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, _ = request(t, http.MethodPost, "/v2/users/ban", adminHeaders, models.BanAccountReq{AccountID: -1, ReasonCode: models.BanReasonFraud})
+		tst.AssertStatusCode(t, code, http.StatusNotFound)
+	})
+
+	t.Run("OK ban revokes sessions and api keys", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/users/ban", adminHeaders, models.BanAccountReq{AccountID: accountID, ReasonCode: models.BanReasonFraud, Note: "card testing"})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		ban := data["data"].(map[string]interface{})
+		tst.AssertBool(t, int(ban["banned_by"].(float64)) == adminAccountID, true)
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", map[string]string{"Authorization": "Bearer " + token}, nil)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+
+		introspection := middleware.IntrospectApiKey(db, userKeys.PrivateKey, userKeys.PublicKey)
+		tst.AssertBool(t, introspection.Active, false)
+
+		code, data = request(t, http.MethodPost, "/v2/login", nil, loginData)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "this account has been banned")
+
+		code, _ = request(t, http.MethodPost, "/v2/users/ban", adminHeaders, models.BanAccountReq{AccountID: accountID, ReasonCode: models.BanReasonFraud})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK unban keeps the history", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/users/unban", adminHeaders, models.UnbanAccountReq{AccountID: accountID, Note: "appeal accepted"})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = request(t, http.MethodPost, "/v2/users/unban", adminHeaders, models.UnbanAccountReq{AccountID: accountID})
+		tst.AssertStatusCode(t, code, http.StatusNotFound)
+
+		code, _ = request(t, http.MethodPost, "/v2/login", nil, loginData)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, data := request(t, http.MethodGet, fmt.Sprintf("/v2/users/bans?account_id=%v", accountID), adminHeaders, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		bans := data["data"].([]interface{})
+		tst.AssertBool(t, len(bans) == 1, true)
+		ban := bans[0].(map[string]interface{})
+		tst.AssertBool(t, ban["active"].(bool), false)
+		tst.AssertResponseMessage(t, ban["lift_note"].(string), "appeal accepted")
+	})
+
+	t.Run("OK temporary ban expires", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Minute)
+		bannedAccount := models.BannedAccount{AccountID: accountID, ReasonCode: models.BanReasonOther, ExpiresAt: &expiresAt}
+		if err := bannedAccount.CreateBannedAccount(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		banned, err := (&models.BannedAccount{AccountID: accountID}).CheckByAccountID(db.Auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, banned, false)
+
+		code, _ := request(t, http.MethodPost, "/v2/users/ban", adminHeaders, models.BanAccountReq{AccountID: accountID, ReasonCode: models.BanReasonOther, ExpiresAt: &expiresAt})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+}
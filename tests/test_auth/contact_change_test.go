@@ -0,0 +1,190 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestContactChange(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		otherUuid, _ = uuid.NewV4()
+		otherSignUp  = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", otherUuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", otherUuid.String()),
+		}
+		newUuid, _ = uuid.NewV4()
+		newEmail   = fmt.Sprintf("testuser%v@qa.team", newUuid.String())
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	otherRouter := gin.Default()
+	tst.SignupUser(t, otherRouter, auth, otherSignUp)
+
+	r.POST("/v2/contact-change/undo", auth.UndoContactChange)
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/user/contact-change", auth.RequestContactChange)
+		authTypeUrl.POST("/user/contact-change/confirm", auth.ConfirmContactChange)
+		authTypeUrl.POST("/validate-token", auth.ValidateToken)
+	}
+
+	request := func(t *testing.T, path string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&b).Encode(body)
+		}
+		req, err := http.NewRequest(http.MethodPost, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	getUser := func(t *testing.T) models.User {
+		user := models.User{AccountID: uint(accountID)}
+		if _, err := user.GetUserByAccountID(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	t.Run("email used by another account", func(t *testing.T) {
+		code, _ := request(t, "/v2/user/contact-change", models.RequestContactChangeReq{Channel: models.ContactChannelEmail, Value: otherSignUp.EmailAddress})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("same email as the current one", func(t *testing.T) {
+		code, _ := request(t, "/v2/user/contact-change", models.RequestContactChangeReq{Channel: models.ContactChannelEmail, Value: userSignUpData.EmailAddress})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("confirm without a pending change", func(t *testing.T) {
+		code, data := request(t, "/v2/user/contact-change/confirm", models.ConfirmContactChangeReq{Code: "123456"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "no pending contact change")
+	})
+
+	t.Run("OK change email", func(t *testing.T) {
+		code, _ := request(t, "/v2/user/contact-change", models.RequestContactChangeReq{Channel: models.ContactChannelEmail, Value: newEmail})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		if getUser(t).EmailAddress != userSignUpData.EmailAddress {
+			t.Errorf("expected the email address to be kept until the change is confirmed")
+		}
+
+		otpCode := "123456"
+		otp := models.OtpVerification{AccountID: accountID, Purpose: models.OtpPurposeContactChange, TokenHash: models.HashOtp(accountID, models.OtpPurposeContactChange, otpCode)}
+		if err := otp.Create(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ = request(t, "/v2/user/contact-change/confirm", models.ConfirmContactChangeReq{Code: "000000"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, _ = request(t, "/v2/user/contact-change/confirm", models.ConfirmContactChangeReq{Code: otpCode})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, getUser(t).EmailAddress, newEmail)
+	})
+
+	t.Run("invalid undo token", func(t *testing.T) {
+		code, data := request(t, "/v2/contact-change/undo", models.UndoContactChangeReq{Token: "invalid"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid or expired undo link")
+	})
+
+	t.Run("OK undo change", func(t *testing.T) {
+		contactChange := models.ContactChange{}
+		err, nilErr := postgresql.SelectLatestFromDb(db.Auth, &contactChange, "account_id = ? and confirmed_at is not null", accountID)
+		if nilErr != nil {
+			t.Fatalf("expected a confirmed contact change: %v", nilErr)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		undoToken := utility.RandomString(32)
+		contactChange.UndoTokenHash = utility.HashToken(undoToken)
+		if err := contactChange.Update(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ := request(t, "/v2/contact-change/undo", models.UndoContactChangeReq{Token: undoToken})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, getUser(t).EmailAddress, userSignUpData.EmailAddress)
+
+		code, _ = request(t, "/v2/validate-token", nil)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+
+		code, _ = request(t, "/v2/contact-change/undo", models.UndoContactChangeReq{Token: undoToken})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("undo window has passed", func(t *testing.T) {
+		confirmedAt := time.Now().Add(-72 * time.Hour)
+		undoExpiresAt := time.Now().Add(-time.Hour)
+		undoToken := utility.RandomString(32)
+		contactChange := models.ContactChange{
+			AccountID:     accountID,
+			Channel:       models.ContactChannelEmail,
+			OldValue:      userSignUpData.EmailAddress,
+			NewValue:      newEmail,
+			ConfirmedAt:   &confirmedAt,
+			UndoTokenHash: utility.HashToken(undoToken),
+			UndoExpiresAt: &undoExpiresAt,
+		}
+		if err := contactChange.CreateContactChange(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ := request(t, "/v2/contact-change/undo", models.UndoContactChangeReq{Token: undoToken})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,215 @@
+package test_auth
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	authService "github.com/vesicash/auth-ms/services/auth"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestDataProtection(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.GET("/user/data-export", auth.ExportData)
+		authTypeUrl.POST("/user/erasure", auth.RequestErasure)
+		authTypeUrl.GET("/user/erasure", auth.GetErasure)
+		authTypeUrl.DELETE("/user/erasure", auth.CancelErasure)
+	}
+
+	request := func(t *testing.T, method, path string) (int, map[string]interface{}) {
+		req, err := http.NewRequest(method, path, &bytes.Buffer{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	wallet := models.WalletBalance{AccountID: accountID, Currency: "NGN", Available: 100}
+	if err := wallet.CreateWalletBalance(db.Auth); err != nil {
+		t.Fatal(err)
+	}
+	walletHistory := models.WalletHistory{AccountID: strconv.Itoa(accountID), Reference: muuid.String(), Amount: 100, Currency: "NGN", Type: "credit", AvailableBalance: 100}
+	if err := walletHistory.CreateWalletHistory(db.Auth); err != nil {
+		t.Fatal(err)
+	}
+
+	contactChange := models.ContactChange{AccountID: accountID, Channel: models.ContactChannelEmail, OldValue: userSignUpData.EmailAddress, NewValue: fmt.Sprintf("new%v@qa.team", muuid.String())}
+	if err := contactChange.CreateContactChange(db.Auth); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("OK export", func(t *testing.T) {
+		code, data := request(t, http.MethodGet, "/v2/user/data-export")
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		export := data["data"].(map[string]interface{})
+		user := export["user"].(map[string]interface{})
+		tst.AssertResponseMessage(t, user["email_address"].(string), userSignUpData.EmailAddress)
+		tst.AssertBool(t, user["password"] == nil, true)
+		tst.AssertBool(t, len(export["wallets"].([]interface{})) == 1, true)
+		tst.AssertBool(t, len(export["wallet_histories"].([]interface{})) == 1, true)
+		tst.AssertBool(t, len(export["sessions"].([]interface{})) > 0, true)
+		tst.AssertBool(t, len(export["contact_changes"].([]interface{})) == 1, true)
+	})
+
+	t.Run("erasure refused while wallets hold money", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/user/erasure")
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		wallet.Available = 0
+		if err := wallet.Update(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("OK request and cancel erasure", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/user/erasure")
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["data"].(map[string]interface{})["status"].(string), models.ErasureStatusPending)
+
+		code, _ = request(t, http.MethodPost, "/v2/user/erasure")
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, data = request(t, http.MethodDelete, "/v2/user/erasure")
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["data"].(map[string]interface{})["status"].(string), models.ErasureStatusCancelled)
+
+		code, _ = request(t, http.MethodDelete, "/v2/user/erasure")
+		tst.AssertStatusCode(t, code, http.StatusNotFound)
+	})
+
+	t.Run("OK erasure after the cooling-off period", func(t *testing.T) {
+		// a failing account is skipped without holding back the others, it is due before this one
+		failing := models.ErasureRequest{AccountID: -accountID, Status: models.ErasureStatusPending, ScheduledFor: time.Now().Add(-time.Hour)}
+		if err := failing.CreateErasureRequest(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			failing.Status = models.ErasureStatusCancelled
+			failing.Update(db.Auth)
+		}()
+
+		code, _ := request(t, http.MethodPost, "/v2/user/erasure")
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		_, err := postgresql.UpdateFieldsWhere(db.Auth, &models.ErasureRequest{}, map[string]interface{}{"scheduled_for": time.Now().Add(-time.Minute)}, "account_id = ? and status = ?", accountID, models.ErasureStatusPending)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ipAddress := "203.0.113.19"
+		tracking := models.UserTracking{AccountID: accountID, IpAddress: ipAddress}
+		if err := tracking.CreateUserTracking(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		attempt := models.LoginAttempt{Key: models.LoginAttemptIpKey(ipAddress), Failures: 1}
+		if err := attempt.CreateLoginAttempt(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		auditLog := models.AuditLog{AccountID: accountID, ActorAccountID: accountID, Action: "test", IpAddress: ipAddress}
+		if err := auditLog.CreateAuditLog(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		client := models.OauthClient{AccountID: accountID, ClientID: muuid.String(), Name: "erased client"}
+		if err := client.CreateOauthClient(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		tst.AssertBool(t, authService.ProcessDueErasures(logger, db) != nil, true)
+
+		user := models.User{AccountID: uint(accountID)}
+		if _, err := user.GetUserByAccountID(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, user.EmailAddress != userSignUpData.EmailAddress, true)
+		tst.AssertBool(t, user.Username != userSignUpData.Username, true)
+		tst.AssertBool(t, user.PhoneNumber == "", true)
+
+		histories, err := (&models.WalletHistory{AccountID: strconv.Itoa(accountID)}).GetAllByAccountID(db.Auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, len(histories) == 1, true)
+
+		sessions, err := (&models.Session{AccountID: accountID}).GetAllByAccountID(db.Auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, len(sessions) == 0, true)
+
+		changes, err := (&models.ContactChange{AccountID: accountID}).GetAllByAccountID(db.Auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, len(changes) == 0, true)
+
+		code, _ = attempt.GetByKey(db.Auth)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, _ = client.GetByClientID(db.Auth)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		auditLogs, err := (&models.AuditLog{AccountID: accountID}).GetAllByAccountID(db.Auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, auditLog := range auditLogs {
+			tst.AssertBool(t, auditLog.IpAddress == "", true)
+		}
+
+		code, _ = request(t, http.MethodGet, "/v2/user/erasure")
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+
+		erasureRequest := models.ErasureRequest{AccountID: accountID}
+		if _, err := erasureRequest.GetLatestByAccountID(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertResponseMessage(t, erasureRequest.Status, models.ErasureStatusCompleted)
+	})
+}
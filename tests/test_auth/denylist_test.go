@@ -0,0 +1,128 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestAccessTokenDenylist(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	firstToken, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	login := func(t *testing.T) string {
+		token, _ := tst.Login(t, r, loginData)
+		if token == "" {
+			t.Fatal("expected to log in again")
+		}
+		return token
+	}
+	secondToken := login(t)
+	thirdToken := login(t)
+
+	r.POST("/v2/users/logout", auth.ForceLogout)
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/logout", auth.Logout)
+		authTypeUrl.POST("/validate-token", auth.ValidateToken)
+	}
+
+	request := func(t *testing.T, path, token string, body interface{}) int {
+		var b bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&b).Encode(body)
+		}
+		req, err := http.NewRequest(http.MethodPost, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("OK logout denies the token", func(t *testing.T) {
+		tst.AssertStatusCode(t, request(t, "/v2/validate-token", firstToken, nil), http.StatusOK)
+		tst.AssertStatusCode(t, request(t, "/v2/logout", firstToken, nil), http.StatusOK)
+		tst.AssertStatusCode(t, request(t, "/v2/validate-token", firstToken, nil), http.StatusUnauthorized)
+		tst.AssertStatusCode(t, request(t, "/v2/validate-token", secondToken, nil), http.StatusOK)
+	})
+
+	t.Run("OK denylist entry is persisted", func(t *testing.T) {
+		introspection := middleware.IntrospectAccessToken(db, secondToken)
+		tst.AssertBool(t, introspection.Active, true)
+
+		err := middleware.DenyAccessToken(db, accountID, introspection.Jti)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revokedToken := models.RevokedToken{Jti: introspection.Jti}
+		_, err = revokedToken.GetByJti(db.Auth)
+		if err != nil {
+			t.Fatalf("expected the jti to be stored in the denylist: %v", err)
+		}
+
+		denied, err := middleware.IsAccessTokenDenied(db, introspection.Jti)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, denied, true)
+
+		err = middleware.DenyAccessToken(db, accountID, introspection.Jti)
+		if err != nil {
+			t.Errorf("denying a token twice should not fail: %v", err)
+		}
+	})
+
+	t.Run("OK admin force logout", func(t *testing.T) {
+		tst.AssertStatusCode(t, request(t, "/v2/validate-token", thirdToken, nil), http.StatusOK)
+		tst.AssertStatusCode(t, request(t, "/v2/users/logout", "", models.ForceLogoutReq{AccountID: accountID}), http.StatusOK)
+		tst.AssertStatusCode(t, request(t, "/v2/validate-token", thirdToken, nil), http.StatusUnauthorized)
+	})
+
+	t.Run("force logout unknown account", func(t *testing.T) {
+		tst.AssertStatusCode(t, request(t, "/v2/users/logout", "", models.ForceLogoutReq{AccountID: -1}), http.StatusNotFound)
+	})
+}
@@ -0,0 +1,204 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestDeviceAuthorization(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0 Safari/537.36"
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	r.POST("/v2/login/authorize-device", auth.AuthorizeDevice)
+	r.POST("/v2/is_otp_valid", auth.ValidateOtp)
+	r.POST("/v2/login/webauthn/begin", auth.BeginWebauthnLogin)
+	r.POST("/v2/login/webauthn/finish", auth.FinishWebauthnLogin)
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.GET("/user/devices", auth.GetDevices)
+		authTypeUrl.DELETE("/user/devices/:device_id", auth.ForgetDevice)
+		authTypeUrl.POST("/user/security/webauthn/register/begin", auth.BeginWebauthnRegistration)
+		authTypeUrl.POST("/user/security/webauthn/register/finish", auth.FinishWebauthnRegistration)
+	}
+
+	_, err := postgresql.UpdateFieldsWhere(db.Auth, &models.User{}, map[string]interface{}{"authorization_required": true}, "account_id = ?", accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawRequest := func(t *testing.T, method, path string, body []byte) (int, map[string]interface{}) {
+		req, err := http.NewRequest(method, path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	request := func(t *testing.T, method, path string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&b).Encode(body)
+		}
+		req, err := http.NewRequest(method, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	// setPendingToken replaces the emailed token of the pending request with a known one
+	setPendingToken := func(t *testing.T) string {
+		pending := models.Authorize{}
+		err, nilErr := postgresql.SelectLatestFromDb(db.Auth, &pending, "account_id = ? and authorized = ?", accountID, false)
+		if nilErr != nil {
+			t.Fatalf("expected a pending authorization request: %v", nilErr)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		authorizationToken := utility.RandomString(32)
+		pending.Token = utility.HashToken(authorizationToken)
+		if err := pending.Update(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		return authorizationToken
+	}
+
+	t.Run("new device is held back", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/login", loginData)
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+	})
+
+	t.Run("invalid authorization token", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/login/authorize-device", models.AuthorizeDeviceReq{Token: "invalid"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid or expired authorization link")
+	})
+
+	t.Run("OK authorize device", func(t *testing.T) {
+		authorizationToken := setPendingToken(t)
+
+		code, _ := request(t, http.MethodPost, "/v2/login/authorize-device", models.AuthorizeDeviceReq{Token: authorizationToken})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = request(t, http.MethodPost, "/v2/login/authorize-device", models.AuthorizeDeviceReq{Token: authorizationToken})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, _ = request(t, http.MethodPost, "/v2/login", loginData)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+
+	t.Run("OK browser update keeps the device approved", func(t *testing.T) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(loginData)
+		req, err := http.NewRequest(http.MethodPost, "/v2/login", &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", strings.Replace(userAgent, "Chrome/118.0", "Chrome/119.0", 1)+" "+strings.Repeat("x", 300))
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+	})
+
+	t.Run("OK list and forget devices", func(t *testing.T) {
+		code, data := request(t, http.MethodGet, "/v2/user/devices", nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		devices := data["data"].([]interface{})
+		tst.AssertBool(t, len(devices) == 1, true)
+
+		deviceID := devices[0].(map[string]interface{})["id"].(float64)
+		code, _ = request(t, http.MethodDelete, fmt.Sprintf("/v2/user/devices/%v", deviceID), nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = request(t, http.MethodPost, "/v2/login", loginData)
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+	})
+
+	t.Run("new device is held back on otp login", func(t *testing.T) {
+		otpCode := "123456"
+		otp := models.OtpVerification{AccountID: accountID, Purpose: models.OtpPurposeLogin, TokenHash: models.HashOtp(accountID, models.OtpPurposeLogin, otpCode)}
+		if err := otp.Create(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ := request(t, http.MethodPost, "/v2/is_otp_valid", map[string]interface{}{"account_id": accountID, "otp_token": otpCode})
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+	})
+
+	t.Run("new device is held back on passkey login", func(t *testing.T) {
+		webAuthnConfig := config.GetConfig().WebAuthn
+		authenticator := tst.NewSoftwareAuthenticator(t, webAuthnConfig.RPID, webAuthnConfig.RPOrigins[0])
+
+		code, data := rawRequest(t, http.MethodPost, "/v2/user/security/webauthn/register/begin", nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		code, _ = rawRequest(t, http.MethodPost, "/v2/user/security/webauthn/register/finish", authenticator.CreateCredential(t, data["data"].(map[string]interface{})))
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, data = request(t, http.MethodPost, "/v2/login/webauthn/begin", models.WebauthnLoginBeginReq{Username: userSignUpData.Username})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		code, _ = rawRequest(t, http.MethodPost, "/v2/login/webauthn/finish", authenticator.GetAssertion(t, data["data"].(map[string]interface{})))
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+	})
+
+	t.Run("forget unknown device", func(t *testing.T) {
+		code, _ := request(t, http.MethodDelete, "/v2/user/devices/0", nil)
+		tst.AssertStatusCode(t, code, http.StatusNotFound)
+	})
+}
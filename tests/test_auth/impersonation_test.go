@@ -0,0 +1,164 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestImpersonation(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		adminUuid, _   = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		adminSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testadmin%v@qa.team", adminUuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "admin",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", adminUuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		adminLoginData = models.LoginUserRequestModel{
+			Username: adminSignUpData.Username,
+			Password: adminSignUpData.Password,
+		}
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+
+	adminRouter := gin.Default()
+	tst.SignupUser(t, adminRouter, auth, adminSignUpData)
+	_, adminAccountID := tst.GetLoginTokenAndAccountID(t, adminRouter, auth, adminLoginData)
+	_, err := postgresql.UpdateFieldsWhere(db.Auth, &models.User{}, map[string]interface{}{"account_type": "admin"}, "account_id = ?", adminAccountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminKeys := tst.GetAccessToken(adminAccountID, db.Auth)
+
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	businessAdminUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.BusinessAdmin))
+	{
+		businessAdminUrl.POST("/users/impersonate", auth.Impersonate)
+	}
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/validate-token", auth.ValidateToken)
+		authTypeUrl.GET("/user/sessions", auth.GetSessions)
+		authTypeUrl.GET("/user/audit-logs", auth.GetAuditLogs)
+		authTypeUrl.POST("/user/security/update_password", auth.UpdatePassword)
+		authTypeUrl.GET("/user/security/get_access_token", middleware.RefuseImpersonation(db), auth.GetAccessToken)
+	}
+
+	request := func(t *testing.T, method, path string, headers map[string]string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&b).Encode(body)
+		}
+		req, err := http.NewRequest(method, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	var (
+		adminHeaders = map[string]string{"v-private-key": adminKeys.PrivateKey, "v-public-key": adminKeys.PublicKey}
+		userHeaders  = map[string]string{"Authorization": "Bearer " + token}
+	)
+
+	t.Run("impersonate an admin or unknown account", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/users/impersonate", adminHeaders, models.ImpersonateReq{AccountID: adminAccountID, Reason: "support ticket"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, _ = request(t, http.MethodPost, "/v2/users/impersonate", adminHeaders, models.ImpersonateReq{AccountID: -1, Reason: "support ticket"})
+		tst.AssertStatusCode(t, code, http.StatusNotFound)
+
+		code, _ = request(t, http.MethodPost, "/v2/users/impersonate", adminHeaders, models.ImpersonateReq{AccountID: accountID})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("impersonate without admin keys", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/users/impersonate", userHeaders, models.ImpersonateReq{AccountID: accountID, Reason: "support ticket"})
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("OK impersonation token is read-only and audited", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/users/impersonate", adminHeaders, models.ImpersonateReq{AccountID: accountID, Reason: "support ticket"})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		impersonationToken := data["data"].(map[string]interface{})["access_token"].(string)
+		impersonationHeaders := map[string]string{"Authorization": "Bearer " + impersonationToken}
+
+		introspection := middleware.IntrospectAccessToken(db, impersonationToken)
+		tst.AssertBool(t, introspection.Active, true)
+		tst.AssertBool(t, introspection.Act != nil && introspection.Act.Sub == strconv.Itoa(adminAccountID), true)
+
+		code, _ = request(t, http.MethodGet, "/v2/user/sessions", impersonationHeaders, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", impersonationHeaders, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, data = request(t, http.MethodPost, "/v2/user/security/update_password", impersonationHeaders, gin.H{"old_password": "password", "new_password": "password1"})
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+		tst.AssertResponseMessage(t, data["message"].(string), middleware.ImpersonationReadOnly)
+
+		code, _ = request(t, http.MethodGet, "/v2/user/security/get_access_token", impersonationHeaders, nil)
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+
+		code, data = request(t, http.MethodGet, "/v2/user/audit-logs", userHeaders, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		actions := map[string]int{}
+		for _, entry := range data["data"].([]interface{}) {
+			auditLog := entry.(map[string]interface{})
+			tst.AssertBool(t, int(auditLog["actor_account_id"].(float64)) == adminAccountID, true)
+			actions[auditLog["action"].(string)]++
+		}
+		tst.AssertBool(t, actions[models.AuditActionImpersonationStarted] == 1, true)
+		tst.AssertBool(t, actions[models.AuditActionImpersonationRequest] == 3, true)
+		tst.AssertBool(t, actions[models.AuditActionImpersonationRefused] == 2, true)
+	})
+}
@@ -0,0 +1,201 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/controller/auth_model"
+	"github.com/vesicash/auth-ms/pkg/controller/oauth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestIntrospection(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		appKey         = config.GetConfig().App.Key
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "business",
+			BusinessName: "test business",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	authModel := auth_model.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	oauthController := oauth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	r.POST("/v2/oauth/token", oauthController.Token)
+	r.POST("/v2/oauth/introspect", oauthController.Introspect)
+	r.POST("/v2/oauth/revoke", oauthController.Revoke)
+	r.POST("/v2/oauth/clients", middleware.Authorize(db, middleware.AuthType), oauthController.CreateClient)
+	r.POST("/v2/validate_authorization", middleware.Authorize(db, middleware.AppType), authModel.ValidateAuthorization)
+
+	request := func(t *testing.T, path string, headers map[string]string, form url.Values) (int, map[string]interface{}) {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	validateAuthorization := func(t *testing.T, body models.ValidateAuthorizationReq) bool {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		req, err := http.NewRequest(http.MethodPost, "/v2/validate_authorization", &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("v-app", appKey)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		return tst.ParseResponse(rr)["data"].(map[string]interface{})["status"].(bool)
+	}
+
+	var clientID, clientSecret, clientToken string
+
+	t.Run("OK client credentials token", func(t *testing.T) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(models.CreateOauthClientReq{Name: "resource server", Scopes: []string{middleware.ScopeProfile}})
+		req, err := http.NewRequest(http.MethodPost, "/v2/oauth/clients", &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		tst.AssertStatusCode(t, rr.Code, http.StatusCreated)
+		client := tst.ParseResponse(rr)["data"].(map[string]interface{})
+		clientID, _ = client["client_id"].(string)
+		clientSecret, _ = client["client_secret"].(string)
+
+		code, data := request(t, "/v2/oauth/token", nil, url.Values{
+			"grant_type":    {middleware.OauthGrantClientCredentials},
+			"scope":         {middleware.ScopeProfile},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+		})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		clientToken = data["access_token"].(string)
+	})
+
+	t.Run("OK introspect login token with app key", func(t *testing.T) {
+		code, data := request(t, "/v2/oauth/introspect", map[string]string{"v-app": appKey}, url.Values{"token": {token}})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertBool(t, data["active"].(bool), true)
+		tst.AssertResponseMessage(t, data["sub"].(string), strconv.Itoa(accountID))
+		tst.AssertResponseMessage(t, data["token_use"].(string), middleware.TokenTypeAccessToken)
+		tst.AssertResponseMessage(t, data["account_type"].(string), "business")
+		if _, ok := data["exp"].(float64); !ok {
+			t.Error("expected an exp claim")
+		}
+		tst.AssertBool(t, validateAuthorization(t, models.ValidateAuthorizationReq{Type: string(middleware.AuthType), AuthorizationToken: token}), true)
+	})
+
+	t.Run("introspect without caller authentication", func(t *testing.T) {
+		code, data := request(t, "/v2/oauth/introspect", nil, url.Values{"token": {token}})
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+		tst.AssertResponseMessage(t, data["error"].(string), "invalid_client")
+
+		code, _ = request(t, "/v2/oauth/introspect", map[string]string{"v-app": "wrong key"}, url.Values{"token": {token}})
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("OK clients only see their own tokens", func(t *testing.T) {
+		form := url.Values{"token": {clientToken}, "client_id": {clientID}, "client_secret": {clientSecret}}
+		code, data := request(t, "/v2/oauth/introspect", nil, form)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertBool(t, data["active"].(bool), true)
+		tst.AssertResponseMessage(t, data["client_id"].(string), clientID)
+		tst.AssertResponseMessage(t, data["scope"].(string), middleware.ScopeProfile)
+
+		form.Set("token", token)
+		code, data = request(t, "/v2/oauth/introspect", nil, form)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertBool(t, data["active"].(bool), false)
+		if len(data) != 1 {
+			t.Errorf("inactive tokens should only report active, got %v", data)
+		}
+
+		code, data = request(t, "/v2/oauth/revoke", nil, form)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["error"].(string), "unauthorized_client")
+	})
+
+	t.Run("OK introspect api key", func(t *testing.T) {
+		accessToken := models.AccessToken{AccountID: accountID}
+		err := accessToken.CreateAccessToken(db.Auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, data := request(t, "/v2/oauth/introspect", map[string]string{"v-app": appKey}, url.Values{"token": {accessToken.PrivateKey}, "token_type_hint": {middleware.TokenTypeApiKey}})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertBool(t, data["active"].(bool), true)
+		tst.AssertResponseMessage(t, data["token_use"].(string), middleware.TokenTypeApiKey)
+
+		code, _ = request(t, "/v2/oauth/revoke", map[string]string{"v-app": appKey}, url.Values{"token": {accessToken.PrivateKey}})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertBool(t, validateAuthorization(t, models.ValidateAuthorizationReq{Type: string(middleware.ApiType), VPrivateKey: accessToken.PrivateKey, VPublicKey: accessToken.PublicKey}), false)
+	})
+
+	t.Run("OK revoke", func(t *testing.T) {
+		form := url.Values{"token": {clientToken}, "client_id": {clientID}, "client_secret": {clientSecret}}
+		code, _ := request(t, "/v2/oauth/revoke", nil, form)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, data := request(t, "/v2/oauth/introspect", nil, form)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertBool(t, data["active"].(bool), false)
+
+		code, _ = request(t, "/v2/oauth/revoke", map[string]string{"v-app": appKey}, url.Values{"token": {token}, "token_type_hint": {middleware.TokenTypeAccessToken}})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertBool(t, validateAuthorization(t, models.ValidateAuthorizationReq{Type: string(middleware.AuthType), AuthorizationToken: token}), false)
+
+		code, _ = request(t, "/v2/oauth/revoke", map[string]string{"v-app": appKey}, url.Values{"token": {"unknown token"}})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+}
@@ -0,0 +1,184 @@
+package test_auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/controller/jwks"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestJwks(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		serverConfig = &config.GetConfig().Server
+		keyDirectory = t.TempDir()
+	)
+
+	writeKey := func(t *testing.T, createdAt time.Time) string {
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kid := fmt.Sprintf("%d-test", createdAt.Unix())
+		err = os.WriteFile(filepath.Join(keyDirectory, kid+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kid
+	}
+
+	previousKid := writeKey(t, time.Now().AddDate(0, 0, -40))
+	expiredKid := writeKey(t, time.Now().AddDate(0, 0, -80))
+
+	originalDirectory, originalAlgorithm, originalAcceptedUntil := serverConfig.JwtKeyDirectory, serverConfig.JwtAlgorithm, serverConfig.HS256AcceptedUntil
+	serverConfig.JwtKeyDirectory = keyDirectory
+	serverConfig.JwtAlgorithm = middleware.SigningAlgorithmRS256
+	serverConfig.HS256AcceptedUntil = ""
+	defer func() {
+		serverConfig.JwtKeyDirectory, serverConfig.JwtAlgorithm, serverConfig.HS256AcceptedUntil = originalDirectory, originalAlgorithm, originalAcceptedUntil
+	}()
+
+	err := middleware.LoadSigningKeys(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	jwksController := jwks.Controller{Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, _ := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	r.GET("/.well-known/jwks.json", jwksController.Get)
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/validate-token", auth.ValidateToken)
+	}
+
+	validate := func(t *testing.T, token string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v2/validate-token", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	var signingKid string
+
+	t.Run("OK keys published", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+		data := tst.ParseResponse(rr)
+		kids := map[string]map[string]interface{}{}
+		for _, k := range data["keys"].([]interface{}) {
+			key := k.(map[string]interface{})
+			kids[key["kid"].(string)] = key
+		}
+
+		if len(kids) != 2 {
+			t.Fatalf("expected the new and the previous key to be published, got %d keys", len(kids))
+		}
+		if _, ok := kids[previousKid]; !ok {
+			t.Error("previous key should stay published while its tokens can be valid")
+		}
+		if _, ok := kids[expiredKid]; ok {
+			t.Error("expired key should not be published")
+		}
+		for kid, key := range kids {
+			if kid != previousKid {
+				signingKid = kid
+				tst.AssertResponseMessage(t, key["kty"].(string), "RSA")
+			}
+		}
+	})
+
+	t.Run("OK token signed with newest key", func(t *testing.T) {
+		parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertResponseMessage(t, parsed.Header["alg"].(string), "RS256")
+		tst.AssertResponseMessage(t, parsed.Header["kid"].(string), signingKid)
+		tst.AssertStatusCode(t, validate(t, token), http.StatusOK)
+	})
+
+	t.Run("HS256 token outside migration window", func(t *testing.T) {
+		parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hsToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, parsed.Claims).SignedString([]byte(serverConfig.Secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		serverConfig.HS256AcceptedUntil = time.Now().Add(-time.Hour).Format(time.RFC3339)
+		tst.AssertStatusCode(t, validate(t, hsToken), http.StatusUnauthorized)
+
+		serverConfig.HS256AcceptedUntil = time.Now().Add(time.Hour).Format(time.RFC3339)
+		tst.AssertStatusCode(t, validate(t, hsToken), http.StatusOK)
+		serverConfig.HS256AcceptedUntil = ""
+	})
+
+	t.Run("OK HS256 token within the default migration window", func(t *testing.T) {
+		parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hsToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, parsed.Claims).SignedString([]byte(serverConfig.Secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertStatusCode(t, validate(t, hsToken), http.StatusOK)
+	})
+}
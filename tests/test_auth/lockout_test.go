@@ -0,0 +1,208 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestLockout(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		wrongLoginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: "wrong password",
+		}
+		lockout = &config.GetConfig().Lockout
+	)
+
+	original := *lockout
+	lockout.MaxAccountAttempts = 3
+	lockout.MaxIpAttempts = 100
+	lockout.AttemptWindow = 15
+	lockout.Duration = 30
+	lockout.BaseDelay = 0
+	defer func() {
+		*lockout = original
+	}()
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	_, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+	r.POST("/v2/users/unlock", auth.UnlockAccount)
+
+	request := func(t *testing.T, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		req, err := http.NewRequest(http.MethodPost, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr, tst.ParseResponse(rr)
+	}
+
+	unlock := models.UnlockAccountReq{AccountID: accountID, IpAddress: "192.0.2.1"}
+
+	t.Run("back-off after failed login", func(t *testing.T) {
+		lockout.BaseDelay = 60
+		defer func() {
+			lockout.BaseDelay = 0
+		}()
+
+		rr, _ := request(t, "/v2/login", wrongLoginData)
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+
+		rr, _ = request(t, "/v2/login", loginData)
+		tst.AssertStatusCode(t, rr.Code, http.StatusTooManyRequests)
+		if rr.Header().Get("Retry-After") == "" {
+			t.Error("expected a Retry-After header")
+		}
+
+		rr, _ = request(t, "/v2/users/unlock", unlock)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+	})
+
+	t.Run("account locked after repeated failures", func(t *testing.T) {
+		for i := 0; i < lockout.MaxAccountAttempts; i++ {
+			rr, data := request(t, "/v2/login", wrongLoginData)
+			tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+			tst.AssertResponseMessage(t, data["message"].(string), "invalid login details")
+		}
+
+		rr, data := request(t, "/v2/login", loginData)
+		tst.AssertStatusCode(t, rr.Code, http.StatusLocked)
+		tst.AssertResponseMessage(t, data["message"].(string), "account temporarily locked after too many failed login attempts")
+	})
+
+	t.Run("OK admin unlock", func(t *testing.T) {
+		rr, data := request(t, "/v2/users/unlock", unlock)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "account unlocked")
+
+		rr, _ = request(t, "/v2/login", loginData)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+	})
+
+	t.Run("locked ip gets the same answer for known and unknown users", func(t *testing.T) {
+		lockedUntil := time.Now().Add(time.Minute)
+		ipAttempt := models.LoginAttempt{Key: models.LoginAttemptIpKey(unlock.IpAddress), LockedUntil: &lockedUntil}
+		ipAttempt.DeleteByKey(db.Auth)
+		if err := ipAttempt.CreateLoginAttempt(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		loginFromIp := func(t *testing.T, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+			var b bytes.Buffer
+			json.NewEncoder(&b).Encode(body)
+			req, err := http.NewRequest(http.MethodPost, "/v2/login", &b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = unlock.IpAddress + ":1234"
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			return rr, tst.ParseResponse(rr)
+		}
+
+		rr, known := loginFromIp(t, wrongLoginData)
+		tst.AssertStatusCode(t, rr.Code, http.StatusTooManyRequests)
+		rr, unknown := loginFromIp(t, models.LoginUserRequestModel{Username: "unknown" + muuid.String(), Password: "wrong password"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusTooManyRequests)
+		tst.AssertResponseMessage(t, unknown["message"].(string), known["message"].(string))
+
+		rr, _ = request(t, "/v2/users/unlock", unlock)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+	})
+
+	t.Run("OK concurrent failures are all counted", func(t *testing.T) {
+		var (
+			key         = fmt.Sprintf("test:%v", muuid.String())
+			now         = time.Now()
+			lockedUntil = now.Add(time.Minute)
+			wg          sync.WaitGroup
+			locks       int32
+		)
+		defer (&models.LoginAttempt{Key: key}).DeleteByKey(db.Auth)
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				attempt := models.LoginAttempt{Key: key, LastFailedAt: now}
+				if err := attempt.RegisterFailure(db.Auth, now.Add(-time.Minute)); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		attempt := models.LoginAttempt{Key: key}
+		if _, err := attempt.GetByKey(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, attempt.Failures == 10, true)
+
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				attempt := attempt
+				locked, err := attempt.Lock(db.Auth, 10, lockedUntil)
+				if err != nil {
+					t.Error(err)
+				}
+				if locked {
+					atomic.AddInt32(&locks, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		tst.AssertBool(t, locks == 1, true)
+	})
+
+	t.Run("unlock unknown account", func(t *testing.T) {
+		rr, _ := request(t, "/v2/users/unlock", models.UnlockAccountReq{AccountID: -1})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+	})
+}
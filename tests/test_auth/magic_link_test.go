@@ -0,0 +1,179 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestMagicLink(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		anonymousMessage = "if the email address is registered, a login link has been sent to it"
+		magicLinkConfig  = &config.GetConfig().MagicLink
+	)
+
+	original := *magicLinkConfig
+	defer func() {
+		*magicLinkConfig = original
+	}()
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	_, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+	r.POST("/v2/login/magic-link", auth.RequestMagicLink)
+	r.POST("/v2/login/magic-link/consume", auth.ConsumeMagicLink)
+
+	request := func(t *testing.T, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		req, err := http.NewRequest(http.MethodPost, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr, tst.ParseResponse(rr)
+	}
+
+	// createMagicLink stores a link with a known token, as the real one only goes out by email
+	createMagicLink := func(t *testing.T, expiresAt time.Time) string {
+		token := utility.RandomString(32)
+		magicLink := models.MagicLink{AccountID: accountID, TokenHash: utility.HashToken(token), ExpiresAt: expiresAt}
+		if err := magicLink.CreateMagicLink(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	setAccountFlag := func(t *testing.T, column string, value bool) {
+		_, err := postgresql.UpdateFieldsWhere(db.Auth, &models.User{}, map[string]interface{}{column: value}, "account_id = ?", accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("same response for known and unknown email", func(t *testing.T) {
+		rr, data := request(t, "/v2/login/magic-link", models.RequestMagicLinkReq{EmailAddress: userSignUpData.EmailAddress})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), anonymousMessage)
+
+		rr, data = request(t, "/v2/login/magic-link", models.RequestMagicLinkReq{EmailAddress: "unknown" + userSignUpData.EmailAddress})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), anonymousMessage)
+	})
+
+	t.Run("OK sends are capped per hour", func(t *testing.T) {
+		magicLink := models.MagicLink{AccountID: accountID}
+		sent, err := magicLink.CountSentSince(db.Auth, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		magicLinkConfig.MaxSendsPerHour = int(sent) + 1
+
+		for i := 0; i < 2; i++ {
+			rr, data := request(t, "/v2/login/magic-link", models.RequestMagicLinkReq{EmailAddress: userSignUpData.EmailAddress})
+			tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+			tst.AssertResponseMessage(t, data["message"].(string), anonymousMessage)
+		}
+
+		after, err := magicLink.CountSentSince(db.Auth, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, after == sent+1, true)
+	})
+
+	t.Run("refused without a link url", func(t *testing.T) {
+		magicLinkConfig.LinkUrl = ""
+		defer func() {
+			magicLinkConfig.LinkUrl = original.LinkUrl
+		}()
+
+		rr, _ := request(t, "/v2/login/magic-link", models.RequestMagicLinkReq{EmailAddress: userSignUpData.EmailAddress})
+		tst.AssertStatusCode(t, rr.Code, http.StatusInternalServerError)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		rr, _ := request(t, "/v2/login/magic-link", models.RequestMagicLinkReq{EmailAddress: "not-an-email"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+	})
+
+	t.Run("OK link is single use", func(t *testing.T) {
+		token := createMagicLink(t, time.Now().Add(10*time.Minute))
+
+		rr, data := request(t, "/v2/login/magic-link/consume", models.ConsumeMagicLinkReq{Token: token})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "login successful")
+		dataM := data["data"].(map[string]interface{})
+		tst.AssertBool(t, dataM["access_token"] != nil, true)
+
+		rr, data = request(t, "/v2/login/magic-link/consume", models.ConsumeMagicLinkReq{Token: token})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid or expired login link")
+	})
+
+	t.Run("expired link", func(t *testing.T) {
+		token := createMagicLink(t, time.Now().Add(-time.Minute))
+
+		rr, data := request(t, "/v2/login/magic-link/consume", models.ConsumeMagicLinkReq{Token: token})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid or expired login link")
+	})
+
+	t.Run("new device needs authorization", func(t *testing.T) {
+		setAccountFlag(t, "authorization_required", true)
+		defer setAccountFlag(t, "authorization_required", false)
+
+		token := createMagicLink(t, time.Now().Add(10*time.Minute))
+		rr, _ := request(t, "/v2/login/magic-link/consume", models.ConsumeMagicLinkReq{Token: token})
+		tst.AssertStatusCode(t, rr.Code, http.StatusForbidden)
+	})
+
+	t.Run("banned account", func(t *testing.T) {
+		token := createMagicLink(t, time.Now().Add(10*time.Minute))
+		banned := models.BannedAccount{AccountID: accountID}
+		if err := postgresql.CreateOneRecord(db.Auth, &banned); err != nil {
+			t.Fatal(err)
+		}
+
+		rr, data := request(t, "/v2/login/magic-link/consume", models.ConsumeMagicLinkReq{Token: token})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "this account has been banned")
+	})
+}
@@ -0,0 +1,358 @@
+package test_auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/controller/oauth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	authService "github.com/vesicash/auth-ms/services/auth"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestOauth(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "business",
+			BusinessName: "test business",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		redirectUri  = "https://merchant.example/callback"
+		codeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk-test-verifier"
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	oauthController := oauth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	r.POST("/v2/oauth/token", oauthController.Token)
+	r.GET("/v2/api/client", middleware.Authorize(db, middleware.ApiType), func(c *gin.Context) { c.JSON(http.StatusOK, nil) })
+	r.GET("/v2/account/wallet", middleware.Scoped(db, []string{middleware.ScopeWalletRead}, middleware.AuthType), auth.GetUserWalletBalance)
+	r.GET("/v2/user/disbursements", middleware.Scoped(db, []string{middleware.ScopeDisbursementsRead}, middleware.AuthType), auth.GetDisbursements)
+
+	oauthAuthUrl := r.Group(fmt.Sprintf("%v/oauth", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		oauthAuthUrl.GET("/authorize", oauthController.Authorize)
+		oauthAuthUrl.POST("/authorize", oauthController.Consent)
+		oauthAuthUrl.POST("/clients", oauthController.CreateClient)
+		oauthAuthUrl.DELETE("/clients/:client_id", oauthController.DeleteClient)
+	}
+
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/validate-token", auth.ValidateToken)
+	}
+
+	request := func(t *testing.T, method, path, token string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&b).Encode(body)
+		}
+		req, err := http.NewRequest(method, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	tokenRequest := func(t *testing.T, clientID, clientSecret string, form url.Values) (int, map[string]interface{}) {
+		req, err := http.NewRequest(http.MethodPost, "/v2/oauth/token", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, clientSecret)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	authorizeQuery := func(clientID, scope string) url.Values {
+		challenge := sha256.Sum256([]byte(codeVerifier))
+		return url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"redirect_uri":          {redirectUri},
+			"scope":                 {scope},
+			"state":                 {"xyz"},
+			"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+			"code_challenge_method": {"S256"},
+		}
+	}
+
+	var clientID, clientSecret, publicClientID, accessToken string
+
+	t.Run("OK register clients", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/oauth/clients", token, models.CreateOauthClientReq{
+			Name:         "merchant app",
+			RedirectUris: []string{redirectUri},
+			Scopes:       []string{middleware.ScopeWalletRead, middleware.ScopeProfile},
+		})
+		tst.AssertStatusCode(t, code, http.StatusCreated)
+		client := data["data"].(map[string]interface{})
+		clientID, _ = client["client_id"].(string)
+		clientSecret, _ = client["client_secret"].(string)
+		if clientID == "" || clientSecret == "" {
+			t.Fatal("expected a client id and secret")
+		}
+
+		code, data = request(t, http.MethodPost, "/v2/oauth/clients", token, models.CreateOauthClientReq{
+			Name:         "merchant mobile app",
+			RedirectUris: []string{redirectUri},
+			Scopes:       []string{middleware.ScopeWalletRead},
+			Public:       true,
+		})
+		tst.AssertStatusCode(t, code, http.StatusCreated)
+		client = data["data"].(map[string]interface{})
+		publicClientID, _ = client["client_id"].(string)
+		if _, ok := client["client_secret"]; ok {
+			t.Error("public clients should not get a secret")
+		}
+	})
+
+	t.Run("invalid client registration", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/oauth/clients", token, models.CreateOauthClientReq{
+			Name:         "merchant app",
+			RedirectUris: []string{"http://merchant.example/callback"},
+			Scopes:       []string{middleware.ScopeWalletRead},
+		})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, _ = request(t, http.MethodPost, "/v2/oauth/clients", token, models.CreateOauthClientReq{
+			Name:   "merchant app",
+			Scopes: []string{"everything"},
+		})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK consent screen", func(t *testing.T) {
+		code, data := request(t, http.MethodGet, "/v2/oauth/authorize?"+authorizeQuery(clientID, middleware.ScopeWalletRead).Encode(), token, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		consent := data["data"].(map[string]interface{})
+		tst.AssertBool(t, consent["consent_granted"].(bool), false)
+		if scopes := consent["scopes"].([]interface{}); len(scopes) != 1 {
+			t.Errorf("expected 1 scope on the consent screen, got %d", len(scopes))
+		}
+	})
+
+	t.Run("authorize with unregistered scope", func(t *testing.T) {
+		code, data := request(t, http.MethodGet, "/v2/oauth/authorize?"+authorizeQuery(clientID, middleware.ScopeOtpSend).Encode(), token, nil)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		redirect := data["data"].(map[string]interface{})["redirect_to"].(string)
+		if !strings.Contains(redirect, "error=invalid_scope") || !strings.Contains(redirect, "state=xyz") {
+			t.Errorf("expected an invalid_scope redirect, got %v", redirect)
+		}
+	})
+
+	t.Run("authorize with unregistered redirect uri", func(t *testing.T) {
+		query := authorizeQuery(clientID, middleware.ScopeWalletRead)
+		query.Set("redirect_uri", "https://attacker.example/callback")
+		code, data := request(t, http.MethodGet, "/v2/oauth/authorize?"+query.Encode(), token, nil)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		if data["data"] != nil {
+			t.Error("errors about the redirect uri must not redirect")
+		}
+	})
+
+	t.Run("public client without pkce", func(t *testing.T) {
+		query := authorizeQuery(publicClientID, middleware.ScopeWalletRead)
+		query.Del("code_challenge")
+		query.Del("code_challenge_method")
+		code, _ := request(t, http.MethodGet, "/v2/oauth/authorize?"+query.Encode(), token, nil)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK authorization code grant", func(t *testing.T) {
+		query := authorizeQuery(clientID, middleware.ScopeWalletRead)
+		code, data := request(t, http.MethodPost, "/v2/oauth/authorize", token, map[string]interface{}{
+			"response_type":         query.Get("response_type"),
+			"client_id":             query.Get("client_id"),
+			"redirect_uri":          query.Get("redirect_uri"),
+			"scope":                 query.Get("scope"),
+			"state":                 query.Get("state"),
+			"code_challenge":        query.Get("code_challenge"),
+			"code_challenge_method": query.Get("code_challenge_method"),
+			"approve":               true,
+		})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		redirect, err := url.Parse(data["data"].(map[string]interface{})["redirect_to"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertResponseMessage(t, redirect.Query().Get("state"), "xyz")
+		authorizationCode := redirect.Query().Get("code")
+
+		exchange := url.Values{
+			"grant_type":    {middleware.OauthGrantAuthorizationCode},
+			"code":          {authorizationCode},
+			"redirect_uri":  {redirectUri},
+			"code_verifier": {strings.Repeat("x", 43)},
+		}
+		code, data = tokenRequest(t, clientID, clientSecret, exchange)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["error"].(string), "invalid_grant")
+
+		exchange.Set("code_verifier", codeVerifier)
+		code, data = tokenRequest(t, clientID, clientSecret, exchange)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["token_type"].(string), "Bearer")
+		tst.AssertResponseMessage(t, data["scope"].(string), middleware.ScopeWalletRead)
+		accessToken = data["access_token"].(string)
+
+		code, data = tokenRequest(t, clientID, clientSecret, exchange)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["error"].(string), "invalid_grant")
+	})
+
+	t.Run("OK authorization code grant without redirect uri", func(t *testing.T) {
+		query := authorizeQuery(clientID, middleware.ScopeWalletRead)
+		code, data := request(t, http.MethodPost, "/v2/oauth/authorize", token, map[string]interface{}{
+			"response_type":         query.Get("response_type"),
+			"client_id":             query.Get("client_id"),
+			"scope":                 query.Get("scope"),
+			"code_challenge":        query.Get("code_challenge"),
+			"code_challenge_method": query.Get("code_challenge_method"),
+			"approve":               true,
+		})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		redirect, err := url.Parse(data["data"].(map[string]interface{})["redirect_to"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ = tokenRequest(t, clientID, clientSecret, url.Values{
+			"grant_type":    {middleware.OauthGrantAuthorizationCode},
+			"code":          {redirect.Query().Get("code")},
+			"code_verifier": {codeVerifier},
+		})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+
+	t.Run("OK scopes enforced", func(t *testing.T) {
+		code, _ := request(t, http.MethodGet, "/v2/account/wallet", accessToken, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = request(t, http.MethodGet, "/v2/user/disbursements", accessToken, nil)
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", accessToken, nil)
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", token, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+
+	t.Run("OK client credentials grant", func(t *testing.T) {
+		form := url.Values{"grant_type": {middleware.OauthGrantClientCredentials}, "scope": {middleware.ScopeProfile}}
+		code, data := tokenRequest(t, clientID, "wrong secret", form)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+		tst.AssertResponseMessage(t, data["error"].(string), "invalid_client")
+
+		code, data = tokenRequest(t, publicClientID, "", form)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["error"].(string), "unauthorized_client")
+
+		code, data = tokenRequest(t, clientID, clientSecret, form)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["scope"].(string), middleware.ScopeProfile)
+	})
+
+	t.Run("banned account cannot use client tokens", func(t *testing.T) {
+		form := url.Values{"grant_type": {middleware.OauthGrantClientCredentials}, "scope": {middleware.ScopeProfile}}
+		code, data := tokenRequest(t, clientID, clientSecret, form)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		clientToken := data["access_token"].(string)
+		code, _ = request(t, http.MethodGet, "/v2/api/client", clientToken, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		ban := models.BannedAccount{AccountID: accountID, ReasonCode: models.BanReasonFraud}
+		if err := ban.CreateBannedAccount(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		code, data = tokenRequest(t, clientID, clientSecret, form)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["error"].(string), "invalid_grant")
+
+		code, _ = request(t, http.MethodGet, "/v2/api/client", clientToken, nil)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+
+		liftedAt := time.Now()
+		ban.LiftedAt = &liftedAt
+		if err := ban.Update(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("OK deleting client revokes its tokens", func(t *testing.T) {
+		code, _ := request(t, http.MethodDelete, "/v2/oauth/clients/"+clientID, token, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = request(t, http.MethodGet, "/v2/account/wallet", accessToken, nil)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("OK banning revokes oauth clients", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/oauth/clients", token, models.CreateOauthClientReq{
+			Name:         "merchant backend",
+			RedirectUris: []string{redirectUri},
+			Scopes:       []string{middleware.ScopeProfile},
+		})
+		tst.AssertStatusCode(t, code, http.StatusCreated)
+		client := data["data"].(map[string]interface{})
+
+		_, code, err := authService.BanAccountService(db, accountID, models.BanAccountReq{AccountID: accountID, ReasonCode: models.BanReasonFraud})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		form := url.Values{"grant_type": {middleware.OauthGrantClientCredentials}, "scope": {middleware.ScopeProfile}}
+		code, data = tokenRequest(t, client["client_id"].(string), client["client_secret"].(string), form)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+		tst.AssertResponseMessage(t, data["error"].(string), "invalid_client")
+	})
+}
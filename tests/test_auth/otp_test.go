@@ -8,10 +8,12 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/internal/models"
 	"github.com/vesicash/auth-ms/pkg/controller/auth"
 	"github.com/vesicash/auth-ms/pkg/middleware"
@@ -43,7 +45,16 @@ func TestSendOTP(t *testing.T) {
 			PhoneNumber:  userSignUpData.PhoneNumber,
 			Password:     userSignUpData.Password,
 		}
+		otpConfig = &config.GetConfig().Otp
 	)
+
+	original := *otpConfig
+	otpConfig.ResendCooldown = 0
+	otpConfig.MaxSendsPerHour = 0
+	defer func() {
+		*otpConfig = original
+	}()
+
 	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
 	r := gin.Default()
 	tst.SignupUser(t, r, auth, userSignUpData)
@@ -182,3 +193,168 @@ func TestSendOTP(t *testing.T) {
 	}
 
 }
+
+func TestOtpLifecycle(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		otpConfig = &config.GetConfig().Otp
+		lockout   = &config.GetConfig().Lockout
+	)
+
+	originalOtp, originalLockout := *otpConfig, *lockout
+	otpConfig.ResendCooldown = 60
+	otpConfig.MaxSendsPerHour = 5
+	otpConfig.MaxAttempts = 3
+	lockout.BaseDelay = 0
+	lockout.MaxAccountAttempts = 100
+	defer func() {
+		*otpConfig = originalOtp
+		*lockout = originalLockout
+	}()
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+	r.POST("/v2/otp/send_otp", auth.SendOTPAPI)
+	r.POST("/v2/otp/validate", auth.ValidateOtp)
+	r.POST("/v2/send_otp", middleware.Authorize(db, middleware.AuthType), auth.SendOTP)
+
+	request := func(t *testing.T, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		req, err := http.NewRequest(http.MethodPost, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if path == "/v2/send_otp" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr, tst.ParseResponse(rr)
+	}
+
+	// issueOtp stores an otp with a known code, as the real one only goes out by sms and email
+	issueOtp := func(t *testing.T, purpose, code string) {
+		otp := models.OtpVerification{AccountID: accountID, Purpose: purpose, TokenHash: models.HashOtp(accountID, purpose, code)}
+		if err := otp.Create(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	validate := func(code string) map[string]interface{} {
+		return map[string]interface{}{"account_id": accountID, "otp_token": code}
+	}
+
+	t.Run("resend cooldown", func(t *testing.T) {
+		rr, _ := request(t, "/v2/otp/send_otp", models.SendOtpTokenReq{AccountID: accountID})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+		rr, _ = request(t, "/v2/otp/send_otp", models.SendOtpTokenReq{AccountID: accountID})
+		tst.AssertStatusCode(t, rr.Code, http.StatusTooManyRequests)
+
+		rr, _ = request(t, "/v2/otp/send_otp", models.SendOtpTokenReq{AccountID: accountID, Purpose: models.OtpPurposePasswordReset})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+	})
+
+	t.Run("invalid purpose", func(t *testing.T) {
+		rr, _ := request(t, "/v2/otp/send_otp", models.SendOtpTokenReq{AccountID: accountID, Purpose: "unknown"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+
+		rr, _ = request(t, "/v2/otp/send_otp", models.SendOtpTokenReq{AccountID: accountID, Purpose: "phone_verification"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+	})
+
+	t.Run("hourly send limit", func(t *testing.T) {
+		otpConfig.ResendCooldown = 0
+		defer func() {
+			otpConfig.ResendCooldown = 60
+		}()
+
+		otp := models.OtpVerification{AccountID: accountID, Purpose: models.OtpPurposeStepUp}
+		sent, err := otp.CountSentSince(db.Auth, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := int(sent); i < otpConfig.MaxSendsPerHour; i++ {
+			rr, _ := request(t, "/v2/otp/send_otp", models.SendOtpTokenReq{AccountID: accountID, Purpose: models.OtpPurposeStepUp})
+			tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		}
+
+		rr, data := request(t, "/v2/otp/send_otp", models.SendOtpTokenReq{AccountID: accountID, Purpose: models.OtpPurposeStepUp})
+		tst.AssertStatusCode(t, rr.Code, http.StatusTooManyRequests)
+		tst.AssertResponseMessage(t, data["message"].(string), "too many otp requests, try again later")
+
+		// the limit is kept per purpose and anonymous sends do not use up the one of the logged in user
+		rr, _ = request(t, "/v2/otp/send_otp", models.SendOtpTokenReq{AccountID: accountID, Purpose: models.OtpPurposeLogin})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+		rr, _ = request(t, "/v2/send_otp", map[string]interface{}{"purpose": models.OtpPurposeStepUp})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+	})
+
+	t.Run("stored hashed", func(t *testing.T) {
+		issueOtp(t, models.OtpPurposeLogin, "123456")
+		otp := models.OtpVerification{AccountID: accountID, Purpose: models.OtpPurposeLogin}
+		if _, err := otp.GetLatestByAccountIDAndPurpose(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, otp.TokenHash == "123456", false)
+	})
+
+	t.Run("OK otp is single use", func(t *testing.T) {
+		issueOtp(t, models.OtpPurposeLogin, "234567")
+
+		rr, _ := request(t, "/v2/otp/validate", validate("234567"))
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+		rr, data := request(t, "/v2/otp/validate", validate("234567"))
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "otp is no longer valid, request a new one")
+	})
+
+	t.Run("invalidated after too many attempts", func(t *testing.T) {
+		issueOtp(t, models.OtpPurposeLogin, "345678")
+
+		for i := 0; i < otpConfig.MaxAttempts; i++ {
+			rr, data := request(t, "/v2/otp/validate", validate("000000"))
+			tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+			tst.AssertResponseMessage(t, data["message"].(string), "invalid token")
+		}
+
+		rr, data := request(t, "/v2/otp/validate", validate("345678"))
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "otp is no longer valid, request a new one")
+	})
+
+	t.Run("otp for another purpose", func(t *testing.T) {
+		issueOtp(t, models.OtpPurposeLogin, "456789")
+		issueOtp(t, models.OtpPurposePasswordReset, "567890")
+
+		rr, data := request(t, "/v2/otp/validate", validate("567890"))
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid token")
+	})
+}
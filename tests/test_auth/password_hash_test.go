@@ -0,0 +1,129 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordRehashOnLogin(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		original = utility.GetPasswordHashParams()
+	)
+
+	utility.SetPasswordHashParams(utility.PasswordHashParams{Algorithm: utility.PasswordHashArgon2id, Argon2Memory: 8 * 1024, Argon2Iterations: 1, Argon2Parallelism: 1})
+	defer utility.SetPasswordHashParams(original)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	_, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	storedHash := func(t *testing.T) string {
+		user := models.User{AccountID: uint(accountID)}
+		if _, err := user.GetUserByAccountID(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		return user.Password
+	}
+
+	setHash := func(t *testing.T, hash string) {
+		_, err := postgresql.UpdateFieldsWhere(db.Auth, &models.User{}, map[string]interface{}{"password": hash}, "account_id = ?", accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	login := func(t *testing.T) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(loginData)
+		req, err := http.NewRequest(http.MethodPost, "/v2/login", &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+	}
+
+	t.Run("new passwords hashed with argon2id", func(t *testing.T) {
+		hash := storedHash(t)
+		tst.AssertBool(t, strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$"), true)
+		tst.AssertBool(t, utility.NeedsRehash(hash), false)
+	})
+
+	t.Run("bcrypt hash upgraded on login", func(t *testing.T) {
+		bcryptHash, err := bcrypt.GenerateFromPassword([]byte(loginData.Password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setHash(t, string(bcryptHash))
+
+		login(t)
+		hash := storedHash(t)
+		tst.AssertBool(t, strings.HasPrefix(hash, "$argon2id$"), true)
+		tst.AssertBool(t, utility.CompareHash(loginData.Password, hash), true)
+	})
+
+	t.Run("weaker argon2id hash upgraded on login", func(t *testing.T) {
+		utility.SetPasswordHashParams(utility.PasswordHashParams{Algorithm: utility.PasswordHashArgon2id, Argon2Memory: 16 * 1024, Argon2Iterations: 2, Argon2Parallelism: 1})
+
+		tst.AssertBool(t, utility.NeedsRehash(storedHash(t)), true)
+		login(t)
+		tst.AssertBool(t, strings.HasPrefix(storedHash(t), "$argon2id$v=19$m=16384,t=2,p=1$"), true)
+	})
+
+	t.Run("calibrate argon2id parameters", func(t *testing.T) {
+		params, elapsed := utility.CalibrateArgon2id(5*time.Millisecond, 8*1024, 1)
+		tst.AssertBool(t, params.Argon2Iterations >= 1, true)
+		tst.AssertBool(t, elapsed > 0, true)
+	})
+}
+
+// BenchmarkPasswordHash measures a single password hash with the configured parameters, run it on production
+// hardware with go test -bench PasswordHash and pick parameters with utility.CalibrateArgon2id
+func BenchmarkPasswordHash(b *testing.B) {
+	params := utility.GetPasswordHashParams()
+	b.Logf("algorithm=%v m=%v t=%v p=%v bcrypt_cost=%v", params.Algorithm, params.Argon2Memory, params.Argon2Iterations, params.Argon2Parallelism, params.BcryptCost)
+	for i := 0; i < b.N; i++ {
+		if _, err := utility.Hash("benchmark password"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
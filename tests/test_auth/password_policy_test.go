@@ -0,0 +1,176 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/passwordpolicy"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		policies = &config.GetConfig().PasswordPolicy
+	)
+
+	original := *policies
+	policies.Default = config.PasswordPolicy{MinLength: 8, HistorySize: 3}
+	policies.AccountTypes = map[string]config.PasswordPolicy{
+		"business": {MinLength: 12, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true, HistorySize: 3},
+	}
+	defer func() {
+		*policies = original
+		passwordpolicy.SetBreachedPasswords(nil)
+	}()
+
+	breached := passwordpolicy.NewBloomFilter(10, 0.001)
+	breached.Add("breached-password-1")
+	passwordpolicy.SetBreachedPasswords(breached)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, _ := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+	r.POST("/v2/user/security/update_password", middleware.Authorize(db, middleware.AuthType), auth.UpdatePassword)
+
+	request := func(t *testing.T, path string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		req, err := http.NewRequest(http.MethodPost, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr, tst.ParseResponse(rr)
+	}
+
+	// violatedRules returns the rules listed in the structured validation error of a rejected password
+	violatedRules := func(data map[string]interface{}) map[string]bool {
+		rules := map[string]bool{}
+		errs, _ := data["error"].(map[string]interface{})
+		violations, _ := errs["password"].([]interface{})
+		for _, v := range violations {
+			rules[v.(map[string]interface{})["rule"].(string)] = true
+		}
+		return rules
+	}
+
+	signup := func(accountType, password string) models.CreateUserRequestModel {
+		muuid, _ := uuid.NewV4()
+		return models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			AccountType:  accountType,
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     password,
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+	}
+
+	t.Run("signup with short password", func(t *testing.T) {
+		rr, data := request(t, "/v2/signup", signup("individual", "short"))
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "password does not meet the password policy")
+		tst.AssertBool(t, violatedRules(data)[passwordpolicy.RuleMinLength], true)
+	})
+
+	t.Run("configured min length below the built-in minimum", func(t *testing.T) {
+		policies.Default.MinLength = 0
+		defer func() {
+			policies.Default.MinLength = 8
+		}()
+
+		rr, data := request(t, "/v2/signup", signup("individual", "short"))
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertBool(t, violatedRules(data)[passwordpolicy.RuleMinLength], true)
+	})
+
+	t.Run("stricter policy for account type", func(t *testing.T) {
+		rr, data := request(t, "/v2/signup", signup("business", "longenough"))
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		rules := violatedRules(data)
+		tst.AssertBool(t, rules[passwordpolicy.RuleMinLength], true)
+		tst.AssertBool(t, rules[passwordpolicy.RuleUpper], true)
+		tst.AssertBool(t, rules[passwordpolicy.RuleDigit], true)
+		tst.AssertBool(t, rules[passwordpolicy.RuleSymbol], true)
+
+		rr, _ = request(t, "/v2/signup", signup("business", "Long-enough-42"))
+		tst.AssertStatusCode(t, rr.Code, http.StatusCreated)
+	})
+
+	t.Run("password containing username", func(t *testing.T) {
+		data := signup("individual", "")
+		data.Password = data.Username + "1"
+		rr, resp := request(t, "/v2/signup", data)
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertBool(t, violatedRules(resp)[passwordpolicy.RulePersonalInfo], true)
+	})
+
+	t.Run("breached password", func(t *testing.T) {
+		rr, data := request(t, "/v2/user/security/update_password", map[string]string{"old_password": "password", "new_password": "breached-password-1"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertBool(t, violatedRules(data)[passwordpolicy.RuleBreached], true)
+	})
+
+	t.Run("recent password reused", func(t *testing.T) {
+		rr, _ := request(t, "/v2/user/security/update_password", map[string]string{"old_password": "password", "new_password": "second_password"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+		rr, _ = request(t, "/v2/user/security/update_password", map[string]string{"old_password": "second_password", "new_password": "third_password"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+		rr, data := request(t, "/v2/user/security/update_password", map[string]string{"old_password": "third_password", "new_password": "password"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertBool(t, violatedRules(data)[passwordpolicy.RuleHistory], true)
+	})
+
+	t.Run("bloom filter round trip", func(t *testing.T) {
+		var b bytes.Buffer
+		if _, err := breached.WriteTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		filter, err := passwordpolicy.ReadBloomFilter(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, filter.Test("breached-password-1"), true)
+		tst.AssertBool(t, filter.Test("not-breached-password"), false)
+	})
+}
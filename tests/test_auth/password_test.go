@@ -9,10 +9,12 @@ import (
 	"net/url"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
 	"github.com/vesicash/auth-ms/internal/models"
 	"github.com/vesicash/auth-ms/pkg/controller/auth"
 	"github.com/vesicash/auth-ms/pkg/middleware"
@@ -273,10 +275,10 @@ func TestUpdatePasswordWithToken(t *testing.T) {
 
 				rr := httptest.NewRecorder()
 				r.ServeHTTP(rr, req)
-				prToken := models.PasswordResetToken{AccountID: accountID}
-				prToken.GetLatestByAccountID(db.Auth)
-				tokenInt, _ := strconv.Atoi(prToken.Token)
-				token = tokenInt
+				tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+				token = utility.GetRandomNumbersInRange(100000000, 999999999)
+				createResetToken(t, db, accountID, token, "")
 
 			}
 			requestB := test.RequestBody
@@ -466,3 +468,139 @@ func TestUpdatePassword(t *testing.T) {
 	}
 
 }
+
+// createResetToken stores a reset token with a known code and link token, as the real ones only go out by sms and email
+func createResetToken(t *testing.T, db postgresql.Databases, accountID, token int, linkToken string) {
+	if linkToken == "" {
+		linkToken = utility.RandomString(32)
+	}
+	resetToken := models.PasswordResetToken{
+		AccountID:     accountID,
+		CodeHash:      models.HashOtp(accountID, models.OtpPurposePasswordReset, strconv.Itoa(token)),
+		LinkTokenHash: utility.HashToken(linkToken),
+		ExpiresAt:     time.Now().Add(30 * time.Minute),
+	}
+	if err := resetToken.CreatePasswordResetToken(db.Auth); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPasswordResetTokenLifecycle(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		resetConfig = &config.GetConfig().PasswordReset
+	)
+
+	original := *resetConfig
+	resetConfig.MaxAttempts = 3
+	defer func() {
+		*resetConfig = original
+	}()
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+	r.POST("/v2/reset-password", auth.RequestPasswordReset)
+	r.POST("/v2/reset-password/change-password", auth.UpdatePasswordWithToken)
+	r.GET("/v2/user/sessions", middleware.Authorize(db, middleware.AuthType), auth.GetSessions)
+
+	request := func(t *testing.T, method, path string, body interface{}, headers map[string]string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		req, err := http.NewRequest(method, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for i, v := range headers {
+			req.Header.Set(i, v)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr, tst.ParseResponse(rr)
+	}
+
+	changePassword := func(t *testing.T, body map[string]interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		body["password"] = "new_password"
+		return request(t, http.MethodPost, "/v2/reset-password/change-password", body, nil)
+	}
+
+	t.Run("stored hashed", func(t *testing.T) {
+		// tokens stay valid for the default duration when none is configured
+		resetConfig.ExpireDuration = 0
+		rr, _ := request(t, http.MethodPost, "/v2/reset-password", map[string]string{"email_address": userSignUpData.EmailAddress}, nil)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+		resetToken := models.PasswordResetToken{AccountID: accountID}
+		if _, err := resetToken.GetLatestByAccountID(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, len(resetToken.CodeHash) == 64, true)
+		tst.AssertBool(t, resetToken.ExpiresAt.After(time.Now()), true)
+	})
+
+	t.Run("earlier token invalidated by a new request", func(t *testing.T) {
+		createResetToken(t, db, accountID, 111111111, "")
+		rr, _ := request(t, http.MethodPost, "/v2/reset-password", map[string]string{"email_address": userSignUpData.EmailAddress}, nil)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+
+		rr, data := changePassword(t, map[string]interface{}{"account_id": accountID, "token": 111111111})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid token")
+	})
+
+	t.Run("invalidated after too many attempts", func(t *testing.T) {
+		createResetToken(t, db, accountID, 222222222, "")
+
+		for i := 0; i < resetConfig.MaxAttempts; i++ {
+			rr, data := changePassword(t, map[string]interface{}{"account_id": accountID, "token": 999999999})
+			tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+			tst.AssertResponseMessage(t, data["message"].(string), "invalid token")
+		}
+
+		rr, data := changePassword(t, map[string]interface{}{"account_id": accountID, "token": 222222222})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "token is no longer valid, request a new one")
+	})
+
+	t.Run("invalid link token", func(t *testing.T) {
+		rr, data := changePassword(t, map[string]interface{}{"reset_token": "unknown"})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid token")
+	})
+
+	t.Run("OK reset with link token ends sessions", func(t *testing.T) {
+		linkToken := utility.RandomString(32)
+		createResetToken(t, db, accountID, 333333333, linkToken)
+
+		rr, data := changePassword(t, map[string]interface{}{"reset_token": linkToken})
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "Password Updated")
+
+		rr, _ = request(t, http.MethodGet, "/v2/user/sessions", nil, map[string]string{"Authorization": "Bearer " + token})
+		tst.AssertStatusCode(t, rr.Code, http.StatusUnauthorized)
+
+		rr, _ = changePassword(t, map[string]interface{}{"reset_token": linkToken})
+		tst.AssertStatusCode(t, rr.Code, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,185 @@
+package test_auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/ratelimit"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestRateLimit(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "business",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		rateLimitConfig = config.GetConfig().RateLimit
+		ok              = func(c *gin.Context) { c.JSON(http.StatusOK, nil) }
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	r.Use(middleware.RateLimit(db))
+	tst.SignupUser(t, r, auth, userSignUpData)
+	_, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	r.GET("/v2/rate-limit", middleware.LimitAnonymous(), ok)
+	r.GET("/v2/api/rate-limit", middleware.Authorize(db, middleware.ApiType), ok)
+
+	request := func(t *testing.T, path string, key *models.AccessToken) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != nil {
+			req.Header.Set("v-private-key", key.PrivateKey)
+			req.Header.Set("v-public-key", key.PublicKey)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	header := func(t *testing.T, rr *httptest.ResponseRecorder, name string) int64 {
+		value, err := strconv.ParseInt(rr.Header().Get(name), 10, 64)
+		if err != nil {
+			t.Fatalf("expected a numeric %v header, got %q", name, rr.Header().Get(name))
+		}
+		return value
+	}
+
+	t.Run("OK api key requests are counted against the key plan", func(t *testing.T) {
+		key := tst.GetAccessToken(accountID, db.Auth)
+		plan := rateLimitConfig.ForAccountType(userSignUpData.AccountType)
+
+		rr := request(t, "/v2/api/rate-limit", &key)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		if got := header(t, rr, "RateLimit-Limit"); got != plan.Limit {
+			t.Errorf("expected a limit of %v, got %v", plan.Limit, got)
+		}
+		if got := header(t, rr, "RateLimit-Remaining"); got != plan.Limit-1 {
+			t.Errorf("expected %v requests remaining, got %v", plan.Limit-1, got)
+		}
+		header(t, rr, "RateLimit-Reset")
+
+		// more requests than the ip is allowed, they are all counted against the key instead
+		for i := int64(0); i <= rateLimitConfig.Anonymous.Limit; i++ {
+			tst.AssertStatusCode(t, request(t, "/v2/api/rate-limit", &key).Code, http.StatusOK)
+		}
+	})
+
+	t.Run("OK monthly quota resets with the month", func(t *testing.T) {
+		limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+		now := time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC)
+
+		for i := 0; i < 2; i++ {
+			result, err := limiter.Take("quota:account:1", ratelimit.Monthly(2), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tst.AssertBool(t, result.Allowed, true)
+		}
+
+		result, _ := limiter.Take("quota:account:1", ratelimit.Monthly(2), now)
+		tst.AssertBool(t, result.Allowed, false)
+		if result.Remaining != 0 || result.ResetAfter(now) != 3600 {
+			t.Errorf("expected the quota to be used up until the end of the month, got %+v", result)
+		}
+
+		result, _ = limiter.Take("quota:account:1", ratelimit.Monthly(2), now.Add(time.Hour))
+		tst.AssertBool(t, result.Allowed, true)
+	})
+
+	t.Run("OK postgres store shares counters", func(t *testing.T) {
+		var (
+			first  = ratelimit.NewLimiter(ratelimit.NewPostgresStore(db.Auth))
+			second = ratelimit.NewLimiter(ratelimit.NewPostgresStore(db.Auth))
+			key    = fmt.Sprintf("test:%v", muuid.String())
+			policy = ratelimit.Policy{Limit: 2, Window: time.Hour}
+			now    = time.Now()
+		)
+
+		result, err := first.Take(key, policy, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, result.Allowed, true)
+
+		result, _ = second.Take(key, policy, now)
+		tst.AssertBool(t, result.Allowed, true)
+		if result.Remaining != 0 {
+			t.Errorf("expected no requests remaining, got %v", result.Remaining)
+		}
+
+		if err := second.Refund(key, policy, now); err != nil {
+			t.Fatal(err)
+		}
+		result, _ = first.Take(key, policy, now)
+		tst.AssertBool(t, result.Allowed, true)
+
+		result, _ = first.Take(key, policy, now)
+		tst.AssertBool(t, result.Allowed, false)
+
+		if err := first.Store.DeleteExpired(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// runs last, the ip stays limited for the rest of the window
+	t.Run("anonymous requests are limited per ip", func(t *testing.T) {
+		var limited *httptest.ResponseRecorder
+		for i := int64(0); i <= 2*rateLimitConfig.Anonymous.Limit; i++ {
+			rr := request(t, "/v2/rate-limit", nil)
+			if rr.Code == http.StatusTooManyRequests {
+				limited = rr
+				break
+			}
+			tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+			if got := header(t, rr, "RateLimit-Limit"); got != rateLimitConfig.Anonymous.Limit {
+				t.Errorf("expected a limit of %v, got %v", rateLimitConfig.Anonymous.Limit, got)
+			}
+		}
+		if limited == nil {
+			t.Fatalf("expected the ip to be rate limited")
+		}
+		if got := header(t, limited, "RateLimit-Remaining"); got != 0 {
+			t.Errorf("expected no requests remaining, got %v", got)
+		}
+		header(t, limited, "Retry-After")
+
+		// requests authorize finds no principal for are held to the ip limit, principals behind the same ip are not
+		tst.AssertStatusCode(t, request(t, "/v2/api/rate-limit", nil).Code, http.StatusTooManyRequests)
+		key := tst.GetAccessToken(accountID, db.Auth)
+		tst.AssertStatusCode(t, request(t, "/v2/api/rate-limit", &key).Code, http.StatusOK)
+	})
+}
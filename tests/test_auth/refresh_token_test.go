@@ -0,0 +1,121 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestRefreshToken(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+	)
+
+	type requestBody struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	loginResponse := tst.GetLoginData(t, r, auth, loginData)
+	refreshToken, _ := loginResponse["refresh_token"].(string)
+	if refreshToken == "" {
+		t.Fatal("login did not return a refresh token")
+	}
+
+	authUrl := r.Group(fmt.Sprintf("%v", "v2"))
+	{
+		authUrl.POST("/token/refresh", auth.RefreshToken)
+	}
+
+	refresh := func(t *testing.T, body requestBody) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		URI := url.URL{Path: "/v2/token/refresh"}
+
+		req, err := http.NewRequest(http.MethodPost, URI.String(), &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	var rotatedToken string
+
+	t.Run("OK refresh token rotated", func(t *testing.T) {
+		code, data := refresh(t, requestBody{RefreshToken: refreshToken})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "token refreshed")
+
+		dataM := data["data"].(map[string]interface{})
+		rotatedToken, _ = dataM["refresh_token"].(string)
+		if rotatedToken == "" || rotatedToken == refreshToken {
+			t.Errorf("expected a new refresh token, got %q", rotatedToken)
+		}
+		if accessToken, _ := dataM["access_token"].(string); accessToken == "" {
+			t.Errorf("expected a new access token")
+		}
+
+		user := models.User{Username: userSignUpData.Username}
+		if _, err := user.GetUserByUsernameEmailOrPhone(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, user.LoginAccessToken == "", true)
+	})
+
+	t.Run("reused refresh token rejected", func(t *testing.T) {
+		code, _ := refresh(t, requestBody{RefreshToken: refreshToken})
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("token family revoked after reuse", func(t *testing.T) {
+		code, _ := refresh(t, requestBody{RefreshToken: rotatedToken})
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("invalid refresh token", func(t *testing.T) {
+		code, _ := refresh(t, requestBody{RefreshToken: muuid.String()})
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("refresh token not provided", func(t *testing.T) {
+		code, _ := refresh(t, requestBody{})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,172 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	"github.com/vesicash/auth-ms/pkg/signing"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestRequestSigning(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "business",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		body = map[string]interface{}{"account_id": 1}
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	_, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+	keys := tst.GetAccessToken(accountID, db.Auth)
+
+	// echoes the body to show handlers can still read it after the signature was checked
+	r.POST("/v2/api/echo", middleware.Authorize(db, middleware.ApiType), func(c *gin.Context) {
+		b, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", b)
+	})
+
+	newRequest := func(t *testing.T, body interface{}) *http.Request {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		req, err := http.NewRequest(http.MethodPost, "/v2/api/echo?mode=test", &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("OK signed request", func(t *testing.T) {
+		req := newRequest(t, body)
+		if err := signing.SignRequest(req, keys.PublicKey, keys.PrivateKey); err != nil {
+			t.Fatal(err)
+		}
+		rr := send(req)
+		tst.AssertStatusCode(t, rr.Code, http.StatusOK)
+		tst.AssertBool(t, int(tst.ParseResponse(rr)["account_id"].(float64)) == 1, true)
+	})
+
+	t.Run("replayed request", func(t *testing.T) {
+		req := newRequest(t, body)
+		if err := signing.SignRequest(req, keys.PublicKey, keys.PrivateKey); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertStatusCode(t, send(req).Code, http.StatusOK)
+
+		replay := newRequest(t, body)
+		replay.Header = req.Header.Clone()
+		tst.AssertStatusCode(t, send(replay).Code, http.StatusUnauthorized)
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		req := newRequest(t, body)
+		if err := signing.SignRequest(req, keys.PublicKey, keys.PrivateKey); err != nil {
+			t.Fatal(err)
+		}
+		tampered := newRequest(t, map[string]interface{}{"account_id": 2})
+		tampered.Header = req.Header.Clone()
+		tst.AssertStatusCode(t, send(tampered).Code, http.StatusUnauthorized)
+	})
+
+	t.Run("wrong private key", func(t *testing.T) {
+		req := newRequest(t, body)
+		if err := signing.SignRequest(req, keys.PublicKey, "wrong private key"); err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertStatusCode(t, send(req).Code, http.StatusUnauthorized)
+	})
+
+	t.Run("timestamp outside the window", func(t *testing.T) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		var (
+			timestamp = strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+			nonce     = utility.RandomString(32)
+			signature = signing.Sign(signing.SigningKey(keys.PrivateKey), signing.StringToSign(http.MethodPost, "/v2/api/echo?mode=test", b.Bytes(), timestamp, nonce))
+		)
+		req := newRequest(t, body)
+		req.Header.Set(signing.HeaderKeyID, keys.PublicKey)
+		req.Header.Set(signing.HeaderTimestamp, timestamp)
+		req.Header.Set(signing.HeaderNonce, nonce)
+		req.Header.Set(signing.HeaderSignature, signature)
+		tst.AssertStatusCode(t, send(req).Code, http.StatusUnauthorized)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		req := newRequest(t, map[string]interface{}{"padding": strings.Repeat("a", 11<<20)})
+		if err := signing.SignRequest(req, keys.PublicKey, keys.PrivateKey); err != nil {
+			t.Fatal(err)
+		}
+		rr := send(req)
+		tst.AssertStatusCode(t, rr.Code, http.StatusUnauthorized)
+		tst.AssertResponseMessage(t, tst.ParseResponse(rr)["message"].(string), "request body is too large")
+	})
+
+	t.Run("OK nonces are shared through postgres", func(t *testing.T) {
+		nonce := models.SignatureNonce{Nonce: fmt.Sprintf("%v:%v", keys.PublicKey, utility.RandomString(32)), ExpiresAt: time.Now().Add(time.Minute)}
+		fresh, err := nonce.Use(db.Auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tst.AssertBool(t, fresh, true)
+
+		fresh, _ = nonce.Use(db.Auth)
+		tst.AssertBool(t, fresh, false)
+
+		expired := models.SignatureNonce{Nonce: fmt.Sprintf("%v:%v", keys.PublicKey, utility.RandomString(32)), ExpiresAt: time.Now().Add(-time.Minute)}
+		expired.Use(db.Auth)
+		fresh, _ = expired.Use(db.Auth)
+		tst.AssertBool(t, fresh, true)
+
+		if err := expired.DeleteExpired(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("missing signature headers", func(t *testing.T) {
+		req := newRequest(t, body)
+		req.Header.Set(signing.HeaderSignature, "signature")
+		tst.AssertStatusCode(t, send(req).Code, http.StatusUnauthorized)
+	})
+}
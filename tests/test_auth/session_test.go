@@ -0,0 +1,161 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestSessions(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		webUserAgent    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0 Safari/537.36"
+		mobileUserAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+
+	authUrl := r.Group(fmt.Sprintf("%v", "v2"))
+	{
+		authUrl.POST("/login", auth.Login)
+	}
+
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/validate-token", auth.ValidateToken)
+		authTypeUrl.GET("/user/sessions", auth.GetSessions)
+		authTypeUrl.DELETE("/user/sessions/:session_id", auth.RevokeSession)
+		authTypeUrl.DELETE("/user/sessions", auth.RevokeAllSessions)
+	}
+
+	request := func(t *testing.T, method, path, token, userAgent string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		URI := url.URL{Path: path}
+
+		req, err := http.NewRequest(method, URI.String(), &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	login := func(t *testing.T, userAgent string) string {
+		code, data := request(t, http.MethodPost, "/v2/login", "", userAgent, loginData)
+		if code != http.StatusOK {
+			t.Fatalf("login failed with status %d", code)
+		}
+		dataM := data["data"].(map[string]interface{})
+		return dataM["access_token"].(string)
+	}
+
+	webToken := login(t, webUserAgent)
+	mobileToken := login(t, mobileUserAgent)
+
+	var mobileSessionID float64
+
+	t.Run("OK sessions live side by side", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/validate-token", webToken, webUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", mobileToken, mobileUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+
+	t.Run("OK list sessions", func(t *testing.T) {
+		code, data := request(t, http.MethodGet, "/v2/user/sessions", webToken, webUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "sessions retrieved")
+
+		sessions := data["data"].([]interface{})
+		if len(sessions) != 2 {
+			t.Fatalf("expected 2 sessions, got %d", len(sessions))
+		}
+
+		for _, s := range sessions {
+			session := s.(map[string]interface{})
+			current := session["current"].(bool)
+			if session["user_agent"] == webUserAgent {
+				tst.AssertBool(t, current, true)
+			} else {
+				tst.AssertBool(t, current, false)
+				mobileSessionID = session["id"].(float64)
+			}
+		}
+	})
+
+	t.Run("invalid session id", func(t *testing.T) {
+		code, _ := request(t, http.MethodDelete, "/v2/user/sessions/abc", webToken, webUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK revoke one session", func(t *testing.T) {
+		code, data := request(t, http.MethodDelete, fmt.Sprintf("/v2/user/sessions/%d", int(mobileSessionID)), webToken, webUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "session revoked")
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", mobileToken, mobileUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", webToken, webUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+
+	t.Run("OK revoke all other sessions", func(t *testing.T) {
+		otherToken := login(t, mobileUserAgent)
+
+		code, data := request(t, http.MethodDelete, "/v2/user/sessions", webToken, webUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "other sessions revoked")
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", otherToken, mobileUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+
+		code, _ = request(t, http.MethodPost, "/v2/validate-token", webToken, webUserAgent, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+}
@@ -0,0 +1,139 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestStepUp(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/auth/step-up", auth.StepUp)
+		authTypeUrl.POST("/send_otp", auth.SendOTP)
+		authTypeUrl.GET("/user/security/get_access_token", middleware.RequireStepUp(db, time.Minute), auth.GetAccessToken)
+		authTypeUrl.POST("/toggle-mor-status", middleware.RequireStepUp(db, time.Nanosecond), auth.ToggleMorStatus)
+	}
+
+	request := func(t *testing.T, method, path string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&b).Encode(body)
+		}
+		req, err := http.NewRequest(method, path, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	t.Run("step up required", func(t *testing.T) {
+		code, data := request(t, http.MethodGet, "/v2/user/security/get_access_token", nil)
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+		tst.AssertResponseMessage(t, data["message"].(string), middleware.StepUpRequired)
+		tst.AssertResponseMessage(t, data["data"].(map[string]interface{})["error"].(string), middleware.StepUpRequired)
+	})
+
+	t.Run("step up with wrong password", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/auth/step-up", models.StepUpReq{Method: middleware.StepUpMethodPassword, Password: "wrong password"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, _ = request(t, http.MethodPost, "/v2/auth/step-up", models.StepUpReq{Method: middleware.StepUpMethodPassword})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK step up with password", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/auth/step-up", models.StepUpReq{Method: middleware.StepUpMethodPassword, Password: userSignUpData.Password})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = request(t, http.MethodGet, "/v2/user/security/get_access_token", nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+
+	t.Run("elevation older than the route allows", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/toggle-mor-status", models.EnableMORReq{})
+		tst.AssertStatusCode(t, code, http.StatusForbidden)
+	})
+
+	t.Run("OK step up with otp", func(t *testing.T) {
+		otpCode := "123456"
+		otp := models.OtpVerification{AccountID: accountID, Purpose: models.OtpPurposeStepUp, TokenHash: models.HashOtp(accountID, models.OtpPurposeStepUp, otpCode)}
+		if err := otp.Create(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ := request(t, http.MethodPost, "/v2/auth/step-up", models.StepUpReq{Method: middleware.StepUpMethodOtp, Code: "000000"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+
+		code, data := request(t, http.MethodPost, "/v2/auth/step-up", models.StepUpReq{Method: middleware.StepUpMethodOtp, Code: otpCode})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "session elevated")
+
+		code, _ = request(t, http.MethodPost, "/v2/auth/step-up", models.StepUpReq{Method: middleware.StepUpMethodOtp, Code: otpCode})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK logged in user can ask for a step up otp", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/send_otp", models.SendOwnOtpReq{Purpose: models.OtpPurposeStepUp})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		otp := models.OtpVerification{AccountID: accountID, Purpose: models.OtpPurposeStepUp}
+		if _, err := otp.GetLatestByAccountIDAndPurpose(db.Auth); err != nil {
+			t.Errorf("expected a step up otp to be sent: %v", err)
+		}
+
+		code, _ = request(t, http.MethodPost, "/v2/send_otp", models.SendOwnOtpReq{Purpose: models.OtpPurposePasswordReset})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("step up with totp when not enrolled", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v2/auth/step-up", models.StepUpReq{Method: middleware.StepUpMethodTotp, Code: "123456"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+}
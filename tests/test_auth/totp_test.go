@@ -0,0 +1,175 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestTotp(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+
+	authUrl := r.Group(fmt.Sprintf("%v", "v2"))
+	{
+		authUrl.POST("/login", auth.Login)
+		authUrl.POST("/login/mfa", auth.LoginMfa)
+	}
+
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/validate-token", auth.ValidateToken)
+		authTypeUrl.POST("/user/security/totp/enroll", auth.EnrollTotp)
+		authTypeUrl.POST("/user/security/totp/confirm", auth.ConfirmTotp)
+		authTypeUrl.POST("/user/security/totp/disable", auth.DisableTotp)
+	}
+
+	request := func(t *testing.T, path, token string, body interface{}) (int, map[string]interface{}) {
+		var b bytes.Buffer
+		json.NewEncoder(&b).Encode(body)
+		URI := url.URL{Path: path}
+
+		req, err := http.NewRequest(http.MethodPost, URI.String(), &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	codeAt := func(t *testing.T, secret string, offset int64) string {
+		code, err := utility.TotpCode(secret, utility.TotpStep(time.Now())+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	code, data := request(t, "/v2/login", "", loginData)
+	if code != http.StatusOK {
+		t.Fatalf("login failed with status %d", code)
+	}
+	token := data["data"].(map[string]interface{})["access_token"].(string)
+
+	var (
+		secret   string
+		mfaToken string
+	)
+
+	t.Run("OK enroll authenticator app", func(t *testing.T) {
+		code, data := request(t, "/v2/user/security/totp/enroll", token, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		dataM := data["data"].(map[string]interface{})
+		secret = dataM["secret"].(string)
+		uri := dataM["uri"].(string)
+		if secret == "" || uri == "" {
+			t.Fatal("expected a secret and an otpauth uri")
+		}
+	})
+
+	t.Run("confirm with invalid code", func(t *testing.T) {
+		code, _ := request(t, "/v2/user/security/totp/confirm", token, models.ConfirmTotpReq{Code: "000000x"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK confirm authenticator app", func(t *testing.T) {
+		code, data := request(t, "/v2/user/security/totp/confirm", token, models.ConfirmTotpReq{Code: codeAt(t, secret, -1)})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "authenticator app enabled")
+	})
+
+	t.Run("OK login returns mfa challenge", func(t *testing.T) {
+		code, data := request(t, "/v2/login", "", loginData)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "mfa required")
+
+		dataM := data["data"].(map[string]interface{})
+		tst.AssertBool(t, dataM["mfa_required"].(bool), true)
+		if dataM["access_token"] != nil {
+			t.Error("access token should not be issued before the second factor")
+		}
+		mfaToken = dataM["mfa_token"].(string)
+	})
+
+	t.Run("mfa token cannot authorize requests", func(t *testing.T) {
+		code, _ := request(t, "/v2/validate-token", mfaToken, nil)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("mfa login with invalid code", func(t *testing.T) {
+		code, _ := request(t, "/v2/login/mfa", "", models.LoginMfaReq{MfaToken: mfaToken, Code: "123"})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("mfa login with invalid token", func(t *testing.T) {
+		code, _ := request(t, "/v2/login/mfa", "", models.LoginMfaReq{MfaToken: token, Code: codeAt(t, secret, 0)})
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+
+	t.Run("OK mfa login", func(t *testing.T) {
+		code, data := request(t, "/v2/login/mfa", "", models.LoginMfaReq{MfaToken: mfaToken, Code: codeAt(t, secret, 0)})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "login successful")
+	})
+
+	t.Run("mfa code cannot be replayed", func(t *testing.T) {
+		code, _ := request(t, "/v2/login/mfa", "", models.LoginMfaReq{MfaToken: mfaToken, Code: codeAt(t, secret, 0)})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("disable with wrong password", func(t *testing.T) {
+		code, _ := request(t, "/v2/user/security/totp/disable", token, models.DisableTotpReq{Password: "wrong", Code: codeAt(t, secret, 1)})
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK disable authenticator app", func(t *testing.T) {
+		code, data := request(t, "/v2/user/security/totp/disable", token, models.DisableTotpReq{Password: userSignUpData.Password, Code: codeAt(t, secret, 1)})
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "authenticator app disabled")
+	})
+}
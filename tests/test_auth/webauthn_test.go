@@ -0,0 +1,200 @@
+package test_auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/vesicash/auth-ms/internal/config"
+	"github.com/vesicash/auth-ms/internal/models"
+	"github.com/vesicash/auth-ms/pkg/controller/auth"
+	"github.com/vesicash/auth-ms/pkg/middleware"
+	"github.com/vesicash/auth-ms/pkg/repository/storage/postgresql"
+	tst "github.com/vesicash/auth-ms/tests"
+	"github.com/vesicash/auth-ms/utility"
+)
+
+func TestWebauthn(t *testing.T) {
+	logger := tst.Setup()
+	gin.SetMode(gin.TestMode)
+	validatorRef := validator.New()
+	db := postgresql.Connection()
+	var (
+		muuid, _       = uuid.NewV4()
+		userSignUpData = models.CreateUserRequestModel{
+			EmailAddress: fmt.Sprintf("testuser%v@qa.team", muuid.String()),
+			PhoneNumber:  fmt.Sprintf("+234%v", utility.GetRandomNumbersInRange(7000000000, 9099999999)),
+			AccountType:  "individual",
+			Firstname:    "test",
+			Lastname:     "user",
+			Password:     "password",
+			Country:      "nigeria",
+			Username:     fmt.Sprintf("test_username%v", muuid.String()),
+		}
+		loginData = models.LoginUserRequestModel{
+			Username: userSignUpData.Username,
+			Password: userSignUpData.Password,
+		}
+		webAuthnConfig = config.GetConfig().WebAuthn
+	)
+
+	auth := auth.Controller{Db: db, Validator: validatorRef, Logger: logger}
+	r := gin.Default()
+	tst.SignupUser(t, r, auth, userSignUpData)
+	token, accountID := tst.GetLoginTokenAndAccountID(t, r, auth, loginData)
+
+	authUrl := r.Group(fmt.Sprintf("%v", "v2"))
+	{
+		authUrl.POST("/login/webauthn/begin", auth.BeginWebauthnLogin)
+		authUrl.POST("/login/webauthn/finish", auth.FinishWebauthnLogin)
+	}
+
+	authTypeUrl := r.Group(fmt.Sprintf("%v", "v2"), middleware.Authorize(db, middleware.AuthType))
+	{
+		authTypeUrl.POST("/user/security/webauthn/register/begin", auth.BeginWebauthnRegistration)
+		authTypeUrl.POST("/user/security/webauthn/register/finish", auth.FinishWebauthnRegistration)
+		authTypeUrl.GET("/user/security/webauthn/credentials", auth.GetWebauthnCredentials)
+	}
+
+	request := func(t *testing.T, method, path, token string, body []byte) (int, map[string]interface{}) {
+		URI := url.URL{Path: path}
+		req, err := http.NewRequest(method, URI.String(), bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code, tst.ParseResponse(rr)
+	}
+
+	encode := func(t *testing.T, v interface{}) []byte {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	authenticator := tst.NewSoftwareAuthenticator(t, webAuthnConfig.RPID, webAuthnConfig.RPOrigins[0])
+
+	t.Run("login without registered passkey", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/login/webauthn/begin", "", encode(t, models.WebauthnLoginBeginReq{Username: userSignUpData.Username}))
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid login details")
+
+		code, data = request(t, http.MethodPost, "/v2/login/webauthn/begin", "", encode(t, models.WebauthnLoginBeginReq{Username: "unknown" + userSignUpData.Username}))
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+		tst.AssertResponseMessage(t, data["message"].(string), "invalid login details")
+	})
+
+	t.Run("OK expired challenges are dropped", func(t *testing.T) {
+		expired := models.WebauthnChallenge{
+			Challenge:   "expired" + muuid.String(),
+			Ceremony:    models.WebauthnCeremonyLogin,
+			SessionData: "{}",
+			ExpiresAt:   time.Now().Add(-time.Minute),
+		}
+		if err := expired.CreateWebauthnChallenge(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ := request(t, http.MethodPost, "/v2/login/webauthn/begin", "", nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		code, _ = expired.GetByChallengeAndCeremony(db.Auth)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK register passkey", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/user/security/webauthn/register/begin", token, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		credential := authenticator.CreateCredential(t, data["data"].(map[string]interface{}))
+		code, data = request(t, http.MethodPost, "/v2/user/security/webauthn/register/finish?name=laptop", token, credential)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "passkey registered")
+
+		code, _ = request(t, http.MethodPost, "/v2/user/security/webauthn/register/finish", token, credential)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK list passkeys", func(t *testing.T) {
+		code, data := request(t, http.MethodGet, "/v2/user/security/webauthn/credentials", token, nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		credentials := data["data"].([]interface{})
+		if len(credentials) != 1 {
+			t.Fatalf("expected 1 passkey, got %d", len(credentials))
+		}
+	})
+
+	t.Run("OK login with passkey", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/login/webauthn/begin", "", encode(t, models.WebauthnLoginBeginReq{Username: userSignUpData.Username}))
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		assertion := authenticator.GetAssertion(t, data["data"].(map[string]interface{}))
+		code, data = request(t, http.MethodPost, "/v2/login/webauthn/finish", "", assertion)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+		tst.AssertResponseMessage(t, data["message"].(string), "login successful")
+
+		dataM := data["data"].(map[string]interface{})
+		if accessToken, _ := dataM["access_token"].(string); accessToken == "" {
+			t.Error("expected an access token")
+		}
+		if _, ok := dataM["user"]; !ok {
+			t.Error("expected the login response payload")
+		}
+
+		code, _ = request(t, http.MethodPost, "/v2/login/webauthn/finish", "", assertion)
+		tst.AssertStatusCode(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("OK login with discoverable passkey", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/login/webauthn/begin", "", nil)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		assertion := authenticator.GetAssertion(t, data["data"].(map[string]interface{}))
+		code, _ = request(t, http.MethodPost, "/v2/login/webauthn/finish", "", assertion)
+		tst.AssertStatusCode(t, code, http.StatusOK)
+	})
+
+	t.Run("login with passkey while locked out", func(t *testing.T) {
+		lockedUntil := time.Now().Add(time.Hour)
+		attempt := models.LoginAttempt{Key: models.LoginAttemptAccountKey(accountID), LockedUntil: &lockedUntil}
+		if err := attempt.CreateLoginAttempt(db.Auth); err != nil {
+			t.Fatal(err)
+		}
+		defer attempt.DeleteByKey(db.Auth)
+
+		code, data := request(t, http.MethodPost, "/v2/login/webauthn/begin", "", encode(t, models.WebauthnLoginBeginReq{Username: userSignUpData.Username}))
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		assertion := authenticator.GetAssertion(t, data["data"].(map[string]interface{}))
+		code, _ = request(t, http.MethodPost, "/v2/login/webauthn/finish", "", assertion)
+		tst.AssertStatusCode(t, code, http.StatusLocked)
+	})
+
+	t.Run("login with unknown authenticator", func(t *testing.T) {
+		code, data := request(t, http.MethodPost, "/v2/login/webauthn/begin", "", encode(t, models.WebauthnLoginBeginReq{Username: userSignUpData.Username}))
+		tst.AssertStatusCode(t, code, http.StatusOK)
+
+		other := tst.NewSoftwareAuthenticator(t, webAuthnConfig.RPID, webAuthnConfig.RPOrigins[0])
+		other.UserHandle = authenticator.UserHandle
+		assertion := other.GetAssertion(t, data["data"].(map[string]interface{}))
+		code, _ = request(t, http.MethodPost, "/v2/login/webauthn/finish", "", assertion)
+		tst.AssertStatusCode(t, code, http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// SoftwareAuthenticator is a minimal platform authenticator producing "none" attestations and ES256 assertions
+type SoftwareAuthenticator struct {
+	RPID         string
+	Origin       string
+	CredentialID []byte
+	UserHandle   []byte
+	privateKey   *ecdsa.PrivateKey
+	signCount    uint32
+}
+
+func NewSoftwareAuthenticator(t *testing.T, rpID, origin string) *SoftwareAuthenticator {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 32)
+	rand.Read(credentialID)
+
+	return &SoftwareAuthenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		CredentialID: credentialID,
+		privateKey:   privateKey,
+	}
+}
+
+// CreateCredential answers registration options the way navigator.credentials.create would
+func (a *SoftwareAuthenticator) CreateCredential(t *testing.T, options map[string]interface{}) []byte {
+	publicKey := options["publicKey"].(map[string]interface{})
+	challenge := publicKey["challenge"].(string)
+	user := publicKey["user"].(map[string]interface{})
+	userHandle, err := base64.StdEncoding.DecodeString(user["id"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.UserHandle = userHandle
+
+	clientData := a.clientData(t, "webauthn.create", challenge)
+
+	coseKey, err := cbor.Marshal(map[int]interface{}{
+		1:  2,
+		3:  -7,
+		-1: 1,
+		-2: a.privateKey.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.privateKey.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(0x45)
+	authData = append(authData, make([]byte, 16)...)
+	credentialIDLength := make([]byte, 2)
+	binary.BigEndian.PutUint16(credentialIDLength, uint16(len(a.CredentialID)))
+	authData = append(authData, credentialIDLength...)
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, coseKey...)
+
+	attestationObject, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.marshal(t, map[string]interface{}{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+	})
+}
+
+// GetAssertion answers login options the way navigator.credentials.get would
+func (a *SoftwareAuthenticator) GetAssertion(t *testing.T, options map[string]interface{}) []byte {
+	publicKey := options["publicKey"].(map[string]interface{})
+	challenge := publicKey["challenge"].(string)
+
+	clientData := a.clientData(t, "webauthn.get", challenge)
+	a.signCount++
+	authData := a.authData(0x05)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.privateKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.marshal(t, map[string]interface{}{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.UserHandle),
+	})
+}
+
+func (a *SoftwareAuthenticator) clientData(t *testing.T, ceremony, challenge string) []byte {
+	clientData, err := json.Marshal(map[string]interface{}{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    a.Origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientData
+}
+
+func (a *SoftwareAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	authData := append([]byte{}, rpIDHash[:]...)
+	authData = append(authData, flags)
+	signCount := make([]byte, 4)
+	binary.BigEndian.PutUint32(signCount, a.signCount)
+	return append(authData, signCount...)
+}
+
+func (a *SoftwareAuthenticator) marshal(t *testing.T, response map[string]interface{}) []byte {
+	id := base64.RawURLEncoding.EncodeToString(a.CredentialID)
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
@@ -0,0 +1,50 @@
+package utility
+
+import "strings"
+
+// DeviceFromUserAgent returns a short human readable description of the device behind a user agent, e.g. "Chrome on Windows"
+func DeviceFromUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	ua := strings.ToLower(userAgent)
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "firefox/") || strings.Contains(ua, "fxios/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "okhttp"):
+		browser = "Android app"
+	case strings.Contains(ua, "cfnetwork") || strings.Contains(ua, "darwin"):
+		browser = "iOS app"
+	case strings.Contains(ua, "postman"):
+		browser = "Postman"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
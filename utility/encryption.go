@@ -0,0 +1,64 @@
+package utility
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// Encrypt seals plainText with AES-256-GCM using a key derived from secret, the nonce is prepended to the cipher text
+func Encrypt(plainText, secret string) (string, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plainText), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt
+func Decrypt(cipherText, secret string) (string, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("cipher text too short")
+	}
+
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plainText, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plainText), nil
+}
+
+func newGCM(secret string) (cipher.AEAD, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("encryption key not configured")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
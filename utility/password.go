@@ -1,14 +1,182 @@
 package utility
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+// PasswordHashParams selects the algorithm new password hashes are created with and its cost. Argon2Memory is in KiB.
+type PasswordHashParams struct {
+	Algorithm         string
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+var DefaultPasswordHashParams = PasswordHashParams{
+	Algorithm:         PasswordHashArgon2id,
+	Argon2Memory:      64 * 1024,
+	Argon2Iterations:  3,
+	Argon2Parallelism: 2,
+	BcryptCost:        bcrypt.DefaultCost,
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var passwordHashing = struct {
+	mu     sync.RWMutex
+	params PasswordHashParams
+}{params: DefaultPasswordHashParams}
+
+// SetPasswordHashParams replaces the hashing parameters, zero values keep their defaults
+func SetPasswordHashParams(params PasswordHashParams) {
+	if params.Algorithm == "" {
+		params.Algorithm = DefaultPasswordHashParams.Algorithm
+	}
+	if params.Argon2Memory == 0 {
+		params.Argon2Memory = DefaultPasswordHashParams.Argon2Memory
+	}
+	if params.Argon2Iterations == 0 {
+		params.Argon2Iterations = DefaultPasswordHashParams.Argon2Iterations
+	}
+	if params.Argon2Parallelism == 0 {
+		params.Argon2Parallelism = DefaultPasswordHashParams.Argon2Parallelism
+	}
+	if params.BcryptCost == 0 {
+		params.BcryptCost = DefaultPasswordHashParams.BcryptCost
+	}
+
+	passwordHashing.mu.Lock()
+	defer passwordHashing.mu.Unlock()
+	passwordHashing.params = params
+}
+
+func GetPasswordHashParams() PasswordHashParams {
+	passwordHashing.mu.RLock()
+	defer passwordHashing.mu.RUnlock()
+	return passwordHashing.params
+}
+
+// Hash hashes a password with the configured algorithm, argon2id hashes are encoded in the PHC string format
 func Hash(str string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(str), bcrypt.DefaultCost)
-	return string(hashed), err
+	params := GetPasswordHashParams()
+	if params.Algorithm == PasswordHashBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(str), params.BcryptCost)
+		return string(hashed), err
+	}
+	return hashArgon2id(str, params)
 }
 
+// CompareHash verifies a password against a hash made by any supported algorithm
 func CompareHash(str string, hashed string) bool {
+	if strings.HasPrefix(hashed, "$argon2id$") {
+		return compareArgon2id(str, hashed)
+	}
 	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(str)) == nil
 }
+
+// NeedsRehash reports whether hashed was made with another algorithm or weaker parameters than the configured ones
+func NeedsRehash(hashed string) bool {
+	params := GetPasswordHashParams()
+
+	if params.Algorithm == PasswordHashBcrypt {
+		cost, err := bcrypt.Cost([]byte(hashed))
+		return err != nil || cost < params.BcryptCost
+	}
+
+	memory, iterations, parallelism, _, key, err := decodeArgon2id(hashed)
+	if err != nil {
+		return true
+	}
+	return memory < params.Argon2Memory || iterations < params.Argon2Iterations ||
+		parallelism < params.Argon2Parallelism || len(key) < argon2KeyLength
+}
+
+// CalibrateArgon2id raises the iterations until hashing a password takes at least target with the given memory
+// (KiB) and parallelism, it returns the parameters to configure and the time one hash took with them
+func CalibrateArgon2id(target time.Duration, memory uint32, parallelism uint8) (PasswordHashParams, time.Duration) {
+	params := PasswordHashParams{
+		Algorithm:         PasswordHashArgon2id,
+		Argon2Memory:      memory,
+		Argon2Parallelism: parallelism,
+		BcryptCost:        bcrypt.DefaultCost,
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	for params.Argon2Iterations = 1; ; params.Argon2Iterations++ {
+		start := time.Now()
+		argon2.IDKey([]byte("calibration password"), salt, params.Argon2Iterations, memory, parallelism, argon2KeyLength)
+		elapsed := time.Since(start)
+		if elapsed >= target || params.Argon2Iterations >= 64 {
+			return params, elapsed
+		}
+	}
+}
+
+func hashArgon2id(password string, params PasswordHashParams) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Argon2Iterations, params.Argon2Memory, params.Argon2Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Argon2Memory, params.Argon2Iterations, params.Argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func compareArgon2id(password, hashed string) bool {
+	memory, iterations, parallelism, salt, key, err := decodeArgon2id(hashed)
+	if err != nil {
+		return false
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1
+}
+
+func decodeArgon2id(hashed string) (memory, iterations uint32, parallelism uint8, salt, key []byte, err error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[1] != PasswordHashArgon2id {
+		return 0, 0, 0, nil, nil, fmt.Errorf("not an argon2id hash")
+	}
+
+	var version int
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return 0, 0, 0, nil, nil, fmt.Errorf("unsupported argon2id version")
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism)
+	if err != nil {
+		return 0, 0, 0, nil, nil, fmt.Errorf("invalid argon2id parameters")
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return 0, 0, 0, nil, nil, err
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return 0, 0, 0, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+	return memory, iterations, parallelism, salt, key, nil
+}
@@ -0,0 +1,93 @@
+package utility
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TotpDigits = 6
+	TotpPeriod = 30
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTotpSecret returns a random base32 secret suitable for authenticator apps
+func GenerateTotpSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TotpURI builds the otpauth:// uri authenticator apps read from a qr code
+func TotpURI(issuer, accountName, secret string) string {
+	label := url.PathEscape(issuer + ":" + accountName)
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(TotpDigits))
+	values.Set("period", fmt.Sprint(TotpPeriod))
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// TotpStep returns the RFC 6238 time step t falls in
+func TotpStep(t time.Time) int64 {
+	return t.Unix() / TotpPeriod
+}
+
+// TotpCode computes the code for secret at the given time step (RFC 6238, HMAC-SHA1)
+func TotpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TotpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TotpDigits, value%mod), nil
+}
+
+// ValidateTotp checks code against the current step and one step either side to allow for clock drift.
+// It returns the matched step so callers can reject replays of the same code.
+func ValidateTotp(secret, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TotpDigits {
+		return 0, false
+	}
+
+	current := TotpStep(t)
+	for _, step := range []int64{current, current - 1, current + 1} {
+		expected, err := TotpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}